@@ -0,0 +1,201 @@
+// Package bootstrap builds what every command starts from: its configuration,
+// logger, database and model, validated the same way and torn down in order.
+package bootstrap
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	_ "time/tzdata" // time zones are found on machines without a zone database too
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+	"github.com/omarabdelaz1z/go-monitor/cmd/helper"
+	"github.com/omarabdelaz1z/go-monitor/cmd/provider"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// Flags registers the database and log flags shared by every command on the
+// flag set.
+func Flags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.Db.Driver, "driver", os.Getenv("DB_DRIVER"), "database driver")
+	fs.StringVar(&cfg.Db.Dsn, "dsn", os.Getenv("DB_DSN"), "database dsn")
+	fs.IntVar(&cfg.Db.MaxIdleConns, "max-idle-conns", 5, "max idle connections")
+	fs.IntVar(&cfg.Db.MaxOpenConns, "max-open-conns", 10, "max open connections")
+	fs.IntVar(&cfg.Db.MaxIdleTime, "max-idle-time", 2, "max idle time")
+	fs.StringVar(&cfg.TimeZone, "time-zone", os.Getenv("REPORT_TIME_ZONE"), "IANA time zone reports count days and months in, e.g. Europe/Berlin (default the machine's)")
+
+	fs.StringVar(&cfg.Log.Path, "log-path", os.Getenv("LOG_PATH"), "log file path, logs only go to the console when empty")
+	helper.EnumFlagSet(fs, &cfg.Log.Level, "log-level", helper.LogLevels, "log level (default "+helper.DefaultLogLevel+")")
+	fs.IntVar(&cfg.Log.MaxSize, "log-max-size", 100, "megabytes written to the log file before it is rotated (0 for no limit)")
+	fs.DurationVar(&cfg.Log.MaxAge, "log-max-age", 0, "age at which the log file is rotated, e.g. 24h (0 for no limit)")
+	fs.IntVar(&cfg.Log.MaxBackups, "log-max-backups", 7, "rotated log files kept (0 keeps them all)")
+	fs.BoolVar(&cfg.Log.Compress, "log-compress", true, "gzip rotated log files")
+	helper.EnumFlagSet(fs, &cfg.Log.System, "log-system", []string{config.LogSystemJournald, config.LogSystemSyslog}, "also send logs to journald or syslog")
+}
+
+// An App is a command's configuration and the resources built from it.
+type App struct {
+	Name   string
+	Config *config.Config
+	Logger zerolog.Logger
+
+	// Set by OpenDatabase.
+	DB        *sql.DB
+	Snapshots *model.SnapshotModel
+
+	hooks     []func() error
+	closeLogs func()
+}
+
+// New validates the log configuration and sets up logging, console being
+// where the console logs are written.
+func New(name string, cfg *config.Config, console io.Writer) (*App, error) {
+	if err := validateLog(cfg.Log); err != nil {
+		return nil, err
+	}
+
+	logger, closeLogs, err := provider.NewLogger(cfg.Log, console, name)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up logging: %w", err)
+	}
+
+	app := &App{Name: name, Config: cfg, Logger: logger, closeLogs: closeLogs}
+
+	logger.Debug().Str("command", name).Msg("loggers initialized")
+
+	return app, nil
+}
+
+func validateLog(cfg config.Log) error {
+	switch {
+	case cfg.MaxSize < 0:
+		return fmt.Errorf("invalid --log-max-size %d, must not be negative", cfg.MaxSize)
+	case cfg.MaxAge < 0:
+		return fmt.Errorf("invalid --log-max-age %s, must not be negative", cfg.MaxAge)
+	case cfg.MaxBackups < 0:
+		return fmt.Errorf("invalid --log-max-backups %d, must not be negative", cfg.MaxBackups)
+	}
+
+	return nil
+}
+
+func validateDatabase(cfg *config.Config) error {
+	switch {
+	case cfg.Db.Driver == "":
+		return fmt.Errorf("no database driver given, see --driver or DB_DRIVER")
+	case cfg.Db.Dsn == "":
+		return fmt.Errorf("no database dsn given, see --dsn or DB_DSN")
+	case cfg.Db.MaxOpenConns < 0:
+		return fmt.Errorf("invalid --max-open-conns %d, must not be negative", cfg.Db.MaxOpenConns)
+	case cfg.Db.MaxIdleConns < 0:
+		return fmt.Errorf("invalid --max-idle-conns %d, must not be negative", cfg.Db.MaxIdleConns)
+	case cfg.Db.MaxIdleTime < 0:
+		return fmt.Errorf("invalid --max-idle-time %d, must not be negative", cfg.Db.MaxIdleTime)
+	}
+
+	return nil
+}
+
+// The time zone reports are bucketed in, the machine's unless named.
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid --time-zone %q, must be an IANA time zone such as Europe/Berlin", name)
+	}
+
+	return loc, nil
+}
+
+// Location is the time zone reports are bucketed in.
+func (a *App) Location() (*time.Location, error) {
+	return location(a.Config.TimeZone)
+}
+
+// OpenDatabase connects to and migrates the database, closing it on shutdown.
+// Its model reports in the configured time zone.
+func (a *App) OpenDatabase(ctx context.Context) error {
+	if err := validateDatabase(a.Config); err != nil {
+		return err
+	}
+
+	loc, err := a.Location()
+
+	if err != nil {
+		return err
+	}
+
+	a.Logger.Debug().Caller().Msg("initiating connection to database")
+
+	db, err := provider.NewDatabase(&provider.DbConfig{
+		Driver:       a.Config.Db.Driver,
+		Dsn:          a.Config.Db.Dsn,
+		MaxIdleConns: a.Config.Db.MaxIdleConns,
+		MaxOpenConns: a.Config.Db.MaxOpenConns,
+		MaxIdleTime:  a.Config.Db.MaxIdleTime,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to initiate connection to database: %w", err)
+	}
+
+	a.OnShutdown(db.Close)
+	a.Logger.Info().Msg("connected to database")
+
+	report, err := model.Migrate(ctx, db)
+
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if report.Duplicates > 0 || report.Summed > 0 {
+		a.Logger.Warn().
+			Int("duplicates", report.Duplicates).
+			Int("summed", report.Summed).
+			Msg("snapshots stored more than once were folded into one, those with other counts summed")
+	}
+
+	a.DB, a.Snapshots = db, model.NewSnapshotModel(db).WithLocation(loc)
+
+	return nil
+}
+
+// OnShutdown registers a hook run by Close. Hooks run in the reverse order
+// they were registered in, like deferred calls.
+func (a *App) OnShutdown(hook func() error) {
+	a.hooks = append(a.hooks, hook)
+}
+
+// Close runs the shutdown hooks, then closes the logs.
+func (a *App) Close() {
+	for i := len(a.hooks) - 1; i >= 0; i-- {
+		if err := a.hooks[i](); err != nil {
+			a.Logger.Warn().Err(err).Msg("shutdown hook failed")
+		}
+	}
+
+	a.hooks = nil
+
+	if a.closeLogs != nil {
+		a.closeLogs()
+		a.closeLogs = nil
+	}
+}
+
+// Fatal logs the error, closes the app and exits, like zerolog's Fatal but
+// running the shutdown hooks.
+func (a *App) Fatal(err error, msg string) {
+	a.Logger.Error().Err(err).Msg(msg)
+	a.Close()
+	os.Exit(1)
+}
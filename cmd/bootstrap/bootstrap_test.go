@@ -0,0 +1,126 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+func TestFlags(t *testing.T) {
+	cfg := &config.Config{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	Flags(fs, cfg)
+
+	if err := fs.Parse([]string{"--driver", "sqlite3", "--log-level", "trace", "--log-system", "journald"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Db.Driver != "sqlite3" || cfg.Log.Level != "trace" || cfg.Log.System != "journald" || cfg.Log.MaxBackups != 7 {
+		t.Errorf("got %+v, expected the flags and their defaults", cfg)
+	}
+
+	// Every level the logger knows is accepted, and nothing else.
+	if err := fs.Parse([]string{"--log-level", "verbose"}); err == nil {
+		t.Error("expected an unknown level to be refused")
+	}
+}
+
+func TestApp(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+
+	cfg := &config.Config{}
+	cfg.Log.Level = "debug"
+
+	var console bytes.Buffer
+
+	app, err := New("test", cfg, &console)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = app.OpenDatabase(context.Background()); err == nil || !strings.Contains(err.Error(), "--driver") {
+		t.Errorf("got %v, expected the missing driver to be reported", err)
+	}
+
+	cfg.Db.Driver, cfg.Db.Dsn = "sqlite3", ":memory:"
+	cfg.Db.MaxOpenConns, cfg.Db.MaxIdleConns = 1, 1
+
+	if err = app.OpenDatabase(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = app.Snapshots.Insert(context.Background(), &model.Snapshot{Timestamp: 1}); err != nil {
+		t.Errorf("got %v, expected a migrated database", err)
+	}
+
+	var order []string
+
+	app.OnShutdown(func() error { order = append(order, "first"); return nil })
+	app.OnShutdown(func() error { order = append(order, "second"); return nil })
+
+	app.Close()
+
+	if strings.Join(order, ",") != "second,first" {
+		t.Errorf("got %v, expected hooks to run in reverse", order)
+	}
+
+	if err = app.DB.Ping(); err == nil {
+		t.Error("expected the database to be closed")
+	}
+
+	if !strings.Contains(console.String(), "connected to database") {
+		t.Errorf("got %q, expected debug logs on the console", console.String())
+	}
+
+	cfg.Log.MaxSize = -1
+
+	if _, err = New("test", cfg, &console); err == nil {
+		t.Error("expected a negative size to be refused")
+	}
+}
+
+func TestTimeZone(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Db.Driver, cfg.Db.Dsn = "sqlite3", ":memory:"
+	cfg.Db.MaxOpenConns, cfg.Db.MaxIdleConns = 1, 1
+	cfg.TimeZone = "Mars/Olympus_Mons"
+
+	app, err := New("test", cfg, io.Discard)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer app.Close()
+
+	if err = app.OpenDatabase(context.Background()); err == nil || !strings.Contains(err.Error(), "--time-zone") {
+		t.Errorf("got %v, expected the unknown time zone to be reported", err)
+	}
+
+	cfg.TimeZone = "Asia/Kolkata"
+
+	if err = app.OpenDatabase(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if loc := app.Snapshots.Location(); loc.String() != "Asia/Kolkata" {
+		t.Errorf("got %v, expected reports in Asia/Kolkata", loc)
+	}
+
+	cfg.TimeZone = ""
+
+	if loc, _ := app.Location(); loc != time.Local {
+		t.Errorf("got %v, expected the machine's time zone by default", loc)
+	}
+}
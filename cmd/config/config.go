@@ -1,10 +1,29 @@
 package config
 
+import "time"
+
+// Destinations logs can be sent to besides the console and the log file.
+const (
+	LogSystemJournald = "journald"
+	LogSystemSyslog   = "syslog"
+)
+
+type Log struct {
+	Level string
+	Path  string // empty logs to the console only
+
+	MaxSize    int // megabytes
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+
+	System string // journald or syslog, if any
+}
+
 type Config struct {
-	Log struct {
-		Level string
-		Path  string
-	}
+	Log Log
+
+	TimeZone string // IANA name of the zone reports are bucketed in, the machine's if empty
 
 	Db struct {
 		Driver       string
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/bootstrap"
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+	"github.com/omarabdelaz1z/go-monitor/internal/dashboard"
+)
+
+func main() {
+	var (
+		cfg *config.Config = &config.Config{}
+
+		listen, monitorURL string
+		source, host       string
+	)
+
+	bootstrap.Flags(flag.CommandLine, cfg)
+
+	flag.StringVar(&listen, "listen", "localhost:8090", "HTTP address of the dashboard")
+	flag.StringVar(&monitorURL, "monitor-url", "", "URL of a monitor started with --live-listen, for the live chart, e.g. http://localhost:9201")
+	flag.StringVar(&source, "source", "", "only report traffic of this source, e.g. local or pcap:capture.pcap (default all)")
+	flag.StringVar(&host, "host", "", "only report traffic captured by this host (default all)")
+
+	flag.Parse()
+
+	app, err := bootstrap.New("dashboard", cfg, os.Stdout)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	defer app.Close()
+
+	logger := app.Logger
+
+	if err = app.OpenDatabase(context.Background()); err != nil {
+		app.Fatal(err, "failed to open database")
+	}
+
+	handler, err := dashboard.NewHandler(app.Snapshots.WithSource(source).WithHost(host), monitorURL, logger)
+
+	if err != nil {
+		app.Fatal(err, "failed to create dashboard")
+	}
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Live streams end with the server rather than holding up its shutdown.
+	server.BaseContext = func(net.Listener) context.Context { return ctx }
+
+	go func() {
+		<-ctx.Done()
+
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		server.Shutdown(shutdown)
+	}()
+
+	logger.Info().Str("listen", listen).Msg("serving dashboard")
+
+	if err = server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.Fatal(err, "failed to serve dashboard")
+	}
+
+	logger.Info().Msg("dashboard stopped")
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/bootstrap"
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+	"github.com/omarabdelaz1z/go-monitor/cmd/helper"
+	"github.com/omarabdelaz1z/go-monitor/cmd/run"
+	"github.com/omarabdelaz1z/go-monitor/cmd/stats"
+	"github.com/omarabdelaz1z/go-monitor/internal/maintenance"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/omarabdelaz1z/go-monitor/internal/systemd"
+	"github.com/omarabdelaz1z/go-monitor/internal/transfer"
+)
+
+// Set at build time, e.g. -ldflags "-X main.version=v1.2.0".
+var version = ""
+
+var runCommand = command{
+	name:    "run",
+	summary: "Monitor the network usage, as the monitoor binary does.\nimport-pcap, collect-flows, serve and ctl are run as its arguments.",
+	args:    "[import-pcap|collect-flows|serve|ctl ...]",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		cfg := run.Flags(fs, base)
+
+		return func(args []string) error {
+			run.Main(cfg, args)
+			return nil
+		}
+	},
+}
+
+var statsCommand = command{
+	name:    "stats",
+	summary: "Browse the usage statistics, as the statistics binary does.",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		cfg := stats.Flags(fs, base)
+
+		return func(args []string) error {
+			stats.Main(cfg)
+			return nil
+		}
+	},
+}
+
+var migrateCommand = command{
+	name:    "migrate",
+	summary: "Create or upgrade the database schema.",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		bootstrap.Flags(fs, base)
+
+		return func(args []string) error {
+			app, err := bootstrap.New(name, base, os.Stderr)
+
+			if err != nil {
+				return err
+			}
+
+			defer app.Close()
+
+			if err = app.OpenDatabase(context.Background()); err != nil {
+				return err
+			}
+
+			fmt.Println("database migrated")
+
+			return nil
+		}
+	},
+}
+
+var exportCommand = command{
+	name:    "export",
+	summary: "Write the snapshots of the database, or their sums by period, as CSV, JSON or JSON lines.",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		var (
+			output, format, from, to, source, host string
+			iface                                  *string
+			opts                                   transfer.Options
+		)
+
+		bootstrap.Flags(fs, base)
+		fs.StringVar(&output, "output", "-", "file the snapshots are written to, - for stdout")
+		helper.EnumFlagSet(fs, &format, "format", transfer.Formats, "export format (default by the output's extension, ndjson for stdout)")
+		helper.EnumFlagSet(fs, &opts.Bucket, "bucket", []string{model.PeriodHour, model.PeriodDay, model.PeriodMonth}, "sum the snapshots up by period instead of exporting them raw")
+		fs.StringVar(&from, "from", "", "only export from this date or RFC 3339 time on")
+		fs.StringVar(&to, "to", "", "only export before this date or RFC 3339 time")
+		fs.Func("interface", "only export this interface of polled devices, all for the sum of the interfaces", func(value string) error {
+			if value == "all" {
+				value = ""
+			}
+
+			iface = &value
+
+			return nil
+		})
+		fs.StringVar(&source, "source", "", "only export the snapshots of this source")
+		fs.StringVar(&host, "host", "", "only export the snapshots captured by this host")
+
+		return func(args []string) error {
+			app, err := bootstrap.New(name, base, os.Stderr)
+
+			if err != nil {
+				return err
+			}
+
+			defer app.Close()
+
+			loc, err := app.Location()
+
+			if err != nil {
+				return err
+			}
+
+			if opts.Filter.From, err = parseTime(from, loc); err != nil {
+				return fmt.Errorf("invalid --from: %w", err)
+			}
+
+			if opts.Filter.To, err = parseTime(to, loc); err != nil {
+				return fmt.Errorf("invalid --to: %w", err)
+			}
+
+			opts.Filter.Interface = iface
+			opts.Format = format
+
+			if opts.Format == "" {
+				opts.Format = transfer.FormatOf(output)
+			}
+
+			if err = app.OpenDatabase(context.Background()); err != nil {
+				return err
+			}
+
+			var w io.Writer = os.Stdout
+
+			if output != "-" {
+				file, err := os.Create(output)
+
+				if err != nil {
+					return fmt.Errorf("failed to create export: %w", err)
+				}
+
+				defer file.Close()
+
+				w = file
+			}
+
+			n, err := transfer.Export(context.Background(), app.Snapshots.WithSource(source).WithHost(host), w, opts)
+
+			if err != nil {
+				return err
+			}
+
+			app.Logger.Info().Int("rows", n).Str("format", opts.Format).Msg("snapshots exported")
+
+			return nil
+		}
+	},
+}
+
+// Parse a date in loc or an RFC 3339 time into unix seconds, 0 if empty.
+func parseTime(value string, loc *time.Location) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return date.Unix(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		return 0, errors.New("must be YYYY-MM-DD or an RFC 3339 time")
+	}
+
+	return t.Unix(), nil
+}
+
+var importCommand = command{
+	name:    "import",
+	summary: "Insert the snapshots of raw exports into the database, skipping those stored already.",
+	args:    "[file ...]",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		var format string
+
+		bootstrap.Flags(fs, base)
+		helper.EnumFlagSet(fs, &format, "format", transfer.Formats, "format of the exports (default by their extension, ndjson for stdin)")
+
+		return func(args []string) error {
+			app, err := bootstrap.New(name, base, os.Stderr)
+
+			if err != nil {
+				return err
+			}
+
+			defer app.Close()
+
+			if err = app.OpenDatabase(context.Background()); err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				args = []string{"-"}
+			}
+
+			skipped := 0
+
+			for _, path := range args {
+				result, err := importFile(app.Snapshots, path, format)
+
+				if err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+
+				for _, conflict := range result.Conflicts {
+					app.Logger.Warn().Str("file", path).Err(conflict).Msg("conflicting snapshot skipped")
+				}
+
+				for _, rejection := range result.Rejected {
+					app.Logger.Warn().Str("file", path).Err(rejection).Msg("invalid snapshot skipped")
+				}
+
+				app.Logger.Info().
+					Str("file", path).
+					Int("inserted", result.Inserted).
+					Int("duplicates", result.Duplicates).
+					Int("conflicts", len(result.Conflicts)).
+					Int("rejected", len(result.Rejected)).
+					Msg("snapshots imported")
+
+				skipped += len(result.Conflicts) + len(result.Rejected)
+			}
+
+			if skipped > 0 {
+				return fmt.Errorf("%d conflicting or invalid snapshots were not imported", skipped)
+			}
+
+			return nil
+		}
+	},
+}
+
+func importFile(snapshots *model.SnapshotModel, path, format string) (transfer.Result, error) {
+	if format == "" {
+		format = transfer.FormatOf(path)
+	}
+
+	if path == "-" {
+		return transfer.Import(context.Background(), snapshots, os.Stdin, format)
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return transfer.Result{}, fmt.Errorf("failed to open export: %w", err)
+	}
+
+	defer file.Close()
+
+	return transfer.Import(context.Background(), snapshots, file, format)
+}
+
+var mergeCommand = command{
+	name:    "merge",
+	summary: "Merge the snapshots of other SQLite databases into the database, without counting any twice.",
+	args:    "file ...",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		var opts model.MergeOptions
+
+		bootstrap.Flags(fs, base)
+		fs.StringVar(&opts.Host, "host", "", "host of the merged snapshots captured without one")
+		fs.StringVar(&opts.Source, "source", "", "source the merged snapshots of the local monitor are tagged with")
+		fs.BoolVar(&opts.DryRun, "dry-run", false, "only report what would be merged")
+
+		return func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("no database to merge given")
+			}
+
+			if base.Db.Driver != "sqlite3" {
+				return fmt.Errorf("only sqlite3 databases can be merged, not %q", base.Db.Driver)
+			}
+
+			app, err := bootstrap.New(name, base, os.Stderr)
+
+			if err != nil {
+				return err
+			}
+
+			defer app.Close()
+
+			if err = app.OpenDatabase(context.Background()); err != nil {
+				return err
+			}
+
+			reports, err := model.Merge(context.Background(), app.DB, args, opts)
+
+			if err != nil {
+				return err
+			}
+
+			writeMergeReports(os.Stdout, reports, opts.DryRun)
+
+			return nil
+		}
+	},
+}
+
+func writeMergeReports(w io.Writer, reports []model.MergeReport, dryRun bool) {
+	verb := "merged"
+
+	if dryRun {
+		verb = "would merge"
+	}
+
+	for _, report := range reports {
+		fmt.Fprintf(w, "%s %s:\n", verb, report.Path)
+
+		for _, t := range report.Tables {
+			fmt.Fprintf(w, "  %-20s %d new, %d duplicates, %d conflicts, %d overlaps\n", t.Table, t.Inserted, t.Duplicates, t.Conflicts, t.Overlaps)
+		}
+
+		for _, kind := range []struct {
+			name   string
+			ranges []model.SeriesRange
+		}{
+			{"conflicts with stored snapshots", report.Conflicts},
+			{"overlaps another capture", report.Overlaps},
+		} {
+			for _, r := range kind.ranges {
+				fmt.Fprintf(w, "  %s: %d snapshots of %s from %s to %s\n", kind.name, r.Snapshots, seriesName(r),
+					time.Unix(r.From, 0).Format(time.RFC3339), time.Unix(r.To, 0).Format(time.RFC3339))
+			}
+		}
+	}
+}
+
+func seriesName(r model.SeriesRange) string {
+	name := r.Source
+
+	if r.Interface != "" {
+		name += " " + r.Interface
+	}
+
+	if r.Host != "" {
+		name += " on " + r.Host
+	}
+
+	return name
+}
+
+var maintenanceCommand = command{
+	name:    "maintenance",
+	summary: "Back the database up, check its integrity, repair anomalous byte counts and compact it.",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		opts := maintenance.Options{MaxBytes: maintenance.DefaultMaxBytes}
+
+		bootstrap.Flags(fs, base)
+		fs.StringVar(&opts.Backup, "backup", "", "file, or directory, the database is backed up to while it is written to, none if empty")
+		fs.IntVar(&opts.Keep, "keep", 0, "backups kept in the --backup directory (0 keeps them all)")
+		fs.BoolVar(&opts.Check, "check", true, "check the integrity of the database")
+		fs.BoolVar(&opts.Repair, "repair", false, "zero negative and oversized byte counts and fix totals, rather than only report them")
+		fs.Int64Var(&opts.MaxBytes, "max-bytes", maintenance.DefaultMaxBytes, "byte count above which a snapshot is oversized")
+		fs.BoolVar(&opts.Vacuum, "vacuum", true, "rebuild the database to reclaim unused space")
+		fs.BoolVar(&opts.Analyze, "analyze", true, "refresh the statistics of the query planner")
+
+		return func(args []string) error {
+			if base.Db.Driver != "sqlite3" {
+				return fmt.Errorf("only sqlite3 databases are maintained, not %q", base.Db.Driver)
+			}
+
+			app, err := bootstrap.New(name, base, os.Stderr)
+
+			if err != nil {
+				return err
+			}
+
+			defer app.Close()
+
+			if err = app.OpenDatabase(context.Background()); err != nil {
+				return err
+			}
+
+			report, err := maintenance.Run(context.Background(), app.DB, opts)
+
+			maintenance.LogReport(app.Logger, report)
+
+			return err
+		}
+	},
+}
+
+var serveCommand = command{
+	name:    "serve",
+	summary: "Receive the snapshots of agents, and serve the dashboard and query API.",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		var opts run.ServeOptions
+
+		bootstrap.Flags(fs, base)
+		run.ServeFlags(fs, &opts)
+		fs.StringVar(&opts.Token, "token", os.Getenv("MONITOOR_TOKEN"), "Bearer token shared by agents and the server")
+
+		return func(args []string) error {
+			app, err := bootstrap.New(name, base, os.Stdout)
+
+			if err != nil {
+				return err
+			}
+
+			defer app.Close()
+
+			listeners, err := systemd.Listeners()
+
+			if err != nil {
+				return err
+			}
+
+			if err = app.OpenDatabase(context.Background()); err != nil {
+				return err
+			}
+
+			return run.Serve(app.Logger, app.Snapshots, opts, listeners["serve"])
+		}
+	},
+}
+
+var versionCommand = command{
+	name:    "version",
+	summary: "Print the version of the binary.",
+	setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+		return func(args []string) error {
+			fmt.Printf("%s %s %s/%s %s\n", name, buildVersion(), runtime.GOOS, runtime.GOARCH, runtime.Version())
+			return nil
+		}
+	},
+}
+
+// The version set at build time, that of the module when installed with go
+// install, or the commit it was built from.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+
+	if !ok {
+		return "unknown"
+	}
+
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	revision, modified := "", false
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+
+	if revision == "" {
+		return "devel"
+	}
+
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+
+	if modified {
+		revision += "-dirty"
+	}
+
+	return "devel-" + revision
+}
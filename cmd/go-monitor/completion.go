@@ -0,0 +1,291 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/bootstrap"
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+)
+
+var shells = []string{"bash", "zsh", "fish"}
+
+// Built by a function, the completions list every command, this one included.
+func completionCommand() command {
+	return command{
+		name:    "completion",
+		summary: "Print the completion script of a shell: bash, zsh or fish.\n\n  source <(go-monitor completion bash)\n  go-monitor completion zsh > \"${fpath[1]}/_go-monitor\"\n  go-monitor completion fish > ~/.config/fish/completions/go-monitor.fish",
+		args:    "bash|zsh|fish",
+		setup: func(fs *flag.FlagSet, base *config.Config) func(args []string) error {
+			return func(args []string) error {
+				if len(args) != 1 {
+					return fmt.Errorf("expected one of %v", shells)
+				}
+
+				return writeCompletion(os.Stdout, args[0])
+			}
+		},
+	}
+}
+
+type completionFlag struct {
+	name, usage string
+	value       bool // whether it takes a value
+}
+
+type completionCommandInfo struct {
+	name, summary string
+	flags         []completionFlag
+}
+
+func flagsOf(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+
+	fs.VisitAll(func(f *flag.Flag) {
+		boolean, ok := f.Value.(interface{ IsBoolFlag() bool })
+
+		flags = append(flags, completionFlag{
+			name:  f.Name,
+			usage: firstLine(f.Usage),
+			value: !ok || !boolean.IsBoolFlag(),
+		})
+	})
+
+	return flags
+}
+
+// The commands and flags completed, the global ones under the empty name.
+func completionInfo() []completionCommandInfo {
+	root := flag.NewFlagSet(name, flag.ContinueOnError)
+	bootstrap.Flags(root, &config.Config{})
+
+	infos := []completionCommandInfo{{flags: flagsOf(root)}}
+
+	for _, c := range commands() {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(fs, &config.Config{})
+
+		infos = append(infos, completionCommandInfo{name: c.name, summary: firstLine(c.summary), flags: flagsOf(fs)})
+	}
+
+	return infos
+}
+
+func writeCompletion(w io.Writer, shell string) error {
+	infos := completionInfo()
+
+	switch shell {
+	case "bash":
+		writeBash(w, infos)
+	case "zsh":
+		writeZsh(w, infos)
+	case "fish":
+		writeFish(w, infos)
+	default:
+		return fmt.Errorf("unknown shell %q, expected one of %v", shell, shells)
+	}
+
+	return nil
+}
+
+// Arguments completed after a command, besides its flags.
+func completionArgs(command string) []string {
+	switch command {
+	case "help":
+		var names []string
+
+		for _, c := range commands() {
+			names = append(names, c.name)
+		}
+
+		return names
+	case "completion":
+		return shells
+	case "run":
+		return []string{"import-pcap", "collect-flows", "serve", "ctl"}
+	}
+
+	return nil
+}
+
+// The global flags taking a value, which is not to be taken for the command.
+func valueFlags(infos []completionCommandInfo) string {
+	var names []string
+
+	for _, f := range infos[0].flags {
+		if f.value {
+			names = append(names, "--"+f.name, "-"+f.name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
+
+func writeBash(w io.Writer, infos []completionCommandInfo) {
+	fmt.Fprintf(w, `# bash completion for %[1]s, source <(%[1]s completion bash)
+
+_go_monitor() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" command="" words="" i
+
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case "${COMP_WORDS[i]}" in
+		%[2]s)
+			((i++)) ;;
+		-*) ;;
+		*)
+			command="${COMP_WORDS[i]}"
+			break ;;
+		esac
+	done
+
+	case "$command" in
+`, name, valueFlags(infos))
+
+	for _, info := range infos {
+		var words []string
+
+		if info.name == "" {
+			for _, c := range infos[1:] {
+				words = append(words, c.name)
+			}
+
+			words = append(words, "help")
+		}
+
+		words = append(words, completionArgs(info.name)...)
+
+		for _, f := range info.flags {
+			words = append(words, "--"+f.name)
+		}
+
+		fmt.Fprintf(w, "\t%q)\n\t\twords=%q ;;\n", info.name, strings.Join(words, " "))
+	}
+
+	fmt.Fprintf(w, `	help)
+		words=%q ;;
+	esac
+
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+
+complete -o default -F _go_monitor %s
+`, strings.Join(completionArgs("help"), " "), name)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func writeZsh(w io.Writer, infos []completionCommandInfo) {
+	fmt.Fprintf(w, `#compdef %[1]s
+# zsh completion for %[1]s, %[1]s completion zsh > "${fpath[1]}/_%[1]s"
+
+_go_monitor() {
+	local command="" i
+	local -a candidates
+
+	for ((i = 2; i < CURRENT; i++)); do
+		case "${words[i]}" in
+		%[2]s)
+			((i++)) ;;
+		-*) ;;
+		*)
+			command="${words[i]}"
+			break ;;
+		esac
+	done
+
+	case "$command" in
+`, name, valueFlags(infos))
+
+	for _, info := range infos {
+		fmt.Fprintf(w, "\t%s)\n", zshQuote(info.name))
+
+		if info.name == "" {
+			var entries []string
+
+			for _, c := range infos[1:] {
+				entries = append(entries, zshQuote(c.name+":"+strings.ReplaceAll(c.summary, ":", `\:`)))
+			}
+
+			entries = append(entries, zshQuote("help:Show the help of a command"))
+
+			fmt.Fprintf(w, "\t\tcandidates=(%s)\n\t\t_describe command candidates\n", strings.Join(entries, " "))
+		}
+
+		if args := completionArgs(info.name); len(args) > 0 {
+			fmt.Fprintf(w, "\t\tcompadd -- %s\n", strings.Join(args, " "))
+		}
+
+		var entries []string
+
+		for _, f := range info.flags {
+			entries = append(entries, zshQuote("--"+f.name+":"+strings.ReplaceAll(f.usage, ":", `\:`)))
+		}
+
+		if len(entries) > 0 {
+			fmt.Fprintf(w, "\t\tcandidates=(%s)\n\t\t_describe flag candidates\n", strings.Join(entries, " "))
+		}
+
+		fmt.Fprintln(w, "\t\t;;")
+	}
+
+	fmt.Fprintf(w, `	help)
+		compadd -- %s ;;
+	*)
+		_files ;;
+	esac
+}
+
+compdef _go_monitor %s
+`, strings.Join(completionArgs("help"), " "), name)
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func writeFish(w io.Writer, infos []completionCommandInfo) {
+	fmt.Fprintf(w, "# fish completion for %[1]s, %[1]s completion fish > ~/.config/fish/completions/%[1]s.fish\n\n", name)
+
+	var names []string
+
+	for _, info := range infos[1:] {
+		names = append(names, info.name)
+	}
+
+	names = append(names, "help")
+
+	for _, info := range infos {
+		condition := "__fish_use_subcommand"
+
+		if info.name == "" {
+			for _, c := range infos[1:] {
+				fmt.Fprintf(w, "complete -c %s -f -n %s -a %s -d %s\n", name, condition, c.name, fishQuote(c.summary))
+			}
+
+			fmt.Fprintf(w, "complete -c %s -f -n %s -a help -d %s\n", name, condition, fishQuote("Show the help of a command"))
+		} else {
+			condition = fishQuote("__fish_seen_subcommand_from " + info.name)
+		}
+
+		if args := completionArgs(info.name); len(args) > 0 {
+			fmt.Fprintf(w, "complete -c %s -f -n %s -a %s\n", name, condition, fishQuote(strings.Join(args, " ")))
+		}
+
+		for _, f := range info.flags {
+			required := ""
+
+			if f.value {
+				required = " -r"
+			}
+
+			fmt.Fprintf(w, "complete -c %s -n %s -l %s%s -d %s\n", name, condition, f.name, required, fishQuote(f.usage))
+		}
+	}
+
+	fmt.Fprintf(w, "complete -c %s -f -n %s -a %s\n", name, fishQuote("__fish_seen_subcommand_from help"), fishQuote(strings.Join(completionArgs("help"), " ")))
+}
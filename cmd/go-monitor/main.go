@@ -0,0 +1,165 @@
+// go-monitor bundles the monitor, the statistics and the tools around their
+// database in a single binary.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/bootstrap"
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+)
+
+const name = "go-monitor"
+
+// A command of the binary.
+type command struct {
+	name    string
+	summary string
+	args    string // what follows the flags, for the usage line
+
+	// Register the command's flags on the flag set and return what runs the
+	// command with the arguments left once they are parsed.
+	setup func(fs *flag.FlagSet, base *config.Config) func(args []string) error
+}
+
+func commands() []command {
+	return []command{
+		runCommand,
+		statsCommand,
+		migrateCommand,
+		exportCommand,
+		importCommand,
+		mergeCommand,
+		maintenanceCommand,
+		serveCommand,
+		versionCommand,
+		completionCommand(),
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands() {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return command{}, false
+}
+
+func main() {
+	os.Exit(execute(os.Args[1:], os.Stderr))
+}
+
+// Run the command line, returning the exit code.
+func execute(args []string, stderr io.Writer) int {
+	root := flag.NewFlagSet(name, flag.ContinueOnError)
+	root.SetOutput(stderr)
+	root.Usage = func() { usage(stderr, root) }
+
+	bootstrap.Flags(root, &config.Config{})
+
+	if err := root.Parse(args); err != nil {
+		return exitCode(err)
+	}
+
+	rest := root.Args()
+
+	if len(rest) == 0 {
+		root.Usage()
+		return 2
+	}
+
+	// Global flags are parsed again by the command, into its configuration.
+	globals := args[:len(args)-len(rest)]
+
+	if rest[0] == "help" {
+		if len(rest) == 1 {
+			usage(os.Stdout, root)
+			return 0
+		}
+
+		rest = []string{rest[1], "-h"}
+	}
+
+	c, ok := findCommand(rest[0])
+
+	if !ok {
+		fmt.Fprintf(stderr, "%s: unknown command %q, see %s help\n", name, rest[0], name)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(name+" "+c.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	runner := c.setup(fs, &config.Config{})
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s\n\n%s\n", strings.TrimSpace(name+" "+c.name+" [flags] "+c.args), c.summary)
+
+		if hasFlags(fs) {
+			fmt.Fprintln(fs.Output(), "\nflags:")
+			fs.PrintDefaults()
+		}
+	}
+
+	if err := fs.Parse(append(append([]string{}, globals...), rest[1:]...)); err != nil {
+		return exitCode(err)
+	}
+
+	if err := runner(fs.Args()); err != nil {
+		fmt.Fprintf(stderr, "%s %s: %v\n", name, c.name, err)
+		return 1
+	}
+
+	return 0
+}
+
+func exitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+
+	return 2
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	has := false
+	fs.VisitAll(func(*flag.Flag) { has = true })
+
+	return has
+}
+
+func usage(w io.Writer, root *flag.FlagSet) {
+	fmt.Fprintf(w, "usage: %s [global flags] <command> [flags] [arguments]\n\ncommands:\n", name)
+
+	width := 0
+
+	for _, c := range commands() {
+		if len(c.name) > width {
+			width = len(c.name)
+		}
+	}
+
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, c.name, firstLine(c.summary))
+	}
+
+	fmt.Fprintf(w, "\nRun %s help <command> for the flags of a command.\n\nglobal flags, also accepted after the command:\n", name)
+
+	root.SetOutput(w)
+	root.PrintDefaults()
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
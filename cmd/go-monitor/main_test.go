@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		code int
+	}{
+		{nil, 2},
+		{[]string{"bogus"}, 2},
+		{[]string{"export", "-h"}, 0},
+		{[]string{"help", "run"}, 0},
+		{[]string{"--dsn", "x.db", "version", "extra"}, 2}, // version takes no global flags
+		{[]string{"completion", "tcsh"}, 1},
+		{[]string{"migrate"}, 1}, // no database
+	} {
+		if code := execute(test.args, io.Discard); code != test.code {
+			t.Errorf("%v: got %d, expected %d", test.args, code, test.code)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range shells {
+		var script bytes.Buffer
+
+		if err := writeCompletion(&script, shell); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, expected := range []string{"run", "stats", "migrate", "export", "import", "serve", "version", "persist", "monitor-url", "log-level"} {
+			if !strings.Contains(script.String(), expected) {
+				t.Errorf("%s: %s is not completed", shell, expected)
+			}
+		}
+	}
+}
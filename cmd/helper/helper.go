@@ -5,6 +5,14 @@ import (
 	"fmt"
 )
 
+// The log levels every command accepts, as zerolog names them.
+var LogLevels = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic", "disabled"}
+
+// DefaultLogLevel is the level logs are filtered by unless told otherwise.
+const DefaultLogLevel = "info"
+
+// GetLevel maps a level of LogLevels to its zerolog value, anything else to
+// the default level.
 func GetLevel(level string) int8 {
 	switch level {
 	case "trace":
@@ -24,12 +32,17 @@ func GetLevel(level string) int8 {
 	case "disabled":
 		return 7
 	default:
-		return 6
+		return GetLevel(DefaultLogLevel)
 	}
 }
 
 func EnumFlag(targetVar *string, flagName string, safeList []string, usage string) {
-	flag.Func(flagName, usage, func(flagValue string) error {
+	EnumFlagSet(flag.CommandLine, targetVar, flagName, safeList, usage)
+}
+
+// Like EnumFlag, on the given flag set.
+func EnumFlagSet(fs *flag.FlagSet, targetVar *string, flagName string, safeList []string, usage string) {
+	fs.Func(flagName, usage, func(flagValue string) error {
 		for _, safeValue := range safeList {
 			if flagValue == safeValue {
 				*targetVar = flagValue
@@ -40,3 +53,15 @@ func EnumFlag(targetVar *string, flagName string, safeList []string, usage strin
 		return fmt.Errorf("must be one of %v", safeList)
 	})
 }
+
+func ListFlag(targetVar *[]string, flagName string, usage string) {
+	ListFlagSet(flag.CommandLine, targetVar, flagName, usage)
+}
+
+// Like ListFlag, on the given flag set.
+func ListFlagSet(fs *flag.FlagSet, targetVar *[]string, flagName string, usage string) {
+	fs.Func(flagName, usage, func(flagValue string) error {
+		*targetVar = append(*targetVar, flagValue)
+		return nil
+	})
+}
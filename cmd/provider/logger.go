@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+	"github.com/omarabdelaz1z/go-monitor/cmd/helper"
+	"github.com/omarabdelaz1z/go-monitor/internal/logfile"
+	"github.com/omarabdelaz1z/go-monitor/internal/systemd"
+	"github.com/rs/zerolog"
+)
+
+// NewLogger logs to the console and, as configured, to a rotated log file and
+// journald or syslog, where entries are tagged with the identifier. Levels are
+// filtered globally so they can be changed while running. The returned
+// function closes the destinations.
+func NewLogger(cfg config.Log, console io.Writer, identifier string) (zerolog.Logger, func(), error) {
+	noColor := true
+
+	if file, ok := console.(*os.File); ok {
+		noColor = !isatty.IsTerminal(file.Fd())
+	}
+
+	writers := []io.Writer{zerolog.ConsoleWriter{
+		Out:        console,
+		NoColor:    noColor,
+		TimeFormat: time.RFC1123,
+		FormatCaller: func(i interface{}) string {
+			if i == nil {
+				return ""
+			}
+			return filepath.Base(fmt.Sprintf("%+v", i))
+		},
+	}}
+
+	var closers []io.Closer
+
+	closeAll := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+
+	if cfg.Path != "" {
+		file, err := logfile.Open(logfile.Options{
+			Path:       cfg.Path,
+			MaxSize:    int64(cfg.MaxSize) * 1000 * 1000,
+			MaxAge:     cfg.MaxAge,
+			MaxBackups: cfg.MaxBackups,
+			Compress:   cfg.Compress,
+		})
+
+		if err != nil {
+			return zerolog.Logger{}, nil, err
+		}
+
+		writers, closers = append(writers, file), append(closers, file)
+	}
+
+	switch cfg.System {
+	case config.LogSystemJournald:
+		journal, err := systemd.NewJournal(identifier)
+
+		if err != nil {
+			closeAll()
+			return zerolog.Logger{}, nil, err
+		}
+
+		writers, closers = append(writers, journalWriter{journal}), append(closers, journal)
+	case config.LogSystemSyslog:
+		writer, closer, err := newSyslog(identifier)
+
+		if err != nil {
+			closeAll()
+			return zerolog.Logger{}, nil, err
+		}
+
+		writers, closers = append(writers, writer), append(closers, closer)
+	}
+
+	zerolog.SetGlobalLevel(zerolog.Level(helper.GetLevel(cfg.Level)))
+
+	logger := zerolog.New(zerolog.MultiLevelWriter(writers...)).With().Timestamp().Logger()
+
+	return logger, closeAll, nil
+}
+
+// A journalWriter turns zerolog events into journal entries, their fields
+// into journal fields.
+type journalWriter struct {
+	journal *systemd.Journal
+}
+
+func (w journalWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w journalWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var event map[string]interface{}
+
+	if err := json.Unmarshal(p, &event); err != nil {
+		return 0, fmt.Errorf("failed to decode log event: %w", err)
+	}
+
+	message, _ := event[zerolog.MessageFieldName].(string)
+
+	delete(event, zerolog.MessageFieldName)
+	delete(event, zerolog.LevelFieldName)
+	delete(event, zerolog.TimestampFieldName) // journald stamps entries itself
+
+	fields := make(map[string]string, len(event))
+
+	for key, value := range event {
+		if s, ok := value.(string); ok {
+			fields[key] = s
+			continue
+		}
+
+		encoded, _ := json.Marshal(value)
+		fields[key] = string(encoded)
+	}
+
+	if err := w.journal.Send(journalPriority(level), message, fields); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func journalPriority(level zerolog.Level) int {
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return systemd.PriorityDebug
+	case zerolog.WarnLevel:
+		return systemd.PriorityWarning
+	case zerolog.ErrorLevel:
+		return systemd.PriorityErr
+	case zerolog.FatalLevel, zerolog.PanicLevel:
+		return systemd.PriorityCrit
+	default:
+		return systemd.PriorityInfo
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !plan9
+
+package provider
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+
+	"github.com/rs/zerolog"
+)
+
+func newSyslog(identifier string) (zerolog.LevelWriter, io.Closer, error) {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, identifier)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+
+	return zerolog.SyslogLevelWriter(writer), writer, nil
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package provider
+
+import (
+	"errors"
+	"io"
+
+	"github.com/rs/zerolog"
+)
+
+func newSyslog(identifier string) (zerolog.LevelWriter, io.Closer, error) {
+	return nil, nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,345 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/helper"
+	"github.com/omarabdelaz1z/go-monitor/internal/util"
+	m "github.com/omarabdelaz1z/go-monitor/pkg/monitoor"
+	"github.com/rs/zerolog"
+)
+
+type controlUsage struct {
+	Sent     uint64 `json:"sent"`
+	Received uint64 `json:"received"`
+	Total    uint64 `json:"total"`
+}
+
+type controlStatus struct {
+	Host              string       `json:"host"`
+	StartedAt         time.Time    `json:"started_at"`
+	Rate              controlUsage `json:"rate"` // bytes per second during the last tick
+	Cumulative        controlUsage `json:"cumulative"`
+	Capturing         bool         `json:"capturing"`
+	PersistencePaused bool         `json:"persistence_paused"`
+	LogLevel          string       `json:"log_level"`
+}
+
+type controlReply struct {
+	Message string `json:"message"`
+}
+
+func usageOf(stat m.NetStat) controlUsage {
+	return controlUsage{Sent: stat.BytesSent, Received: stat.BytesRecv, Total: stat.BytesTotal}
+}
+
+// Listen on a unix socket only accessible as the mode allows. The socket is
+// created under a temporary name and moved into place once its mode is set,
+// so it is never reachable with looser permissions.
+func listenControl(path string, mode os.FileMode) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("control socket %s is in use by another monitor", path)
+	}
+
+	pending := fmt.Sprintf("%s.%d", path, os.Getpid())
+	os.Remove(pending)
+
+	listener, err := net.Listen("unix", pending)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+
+	// The socket is removed under its final name when the service stops.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err = os.Chmod(pending, mode); err == nil {
+		err = os.Rename(pending, path)
+	}
+
+	if err != nil {
+		listener.Close()
+		os.Remove(pending)
+
+		return nil, fmt.Errorf("failed to set up control socket: %w", err)
+	}
+
+	return listener, nil
+}
+
+func (s *Service) controlHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", s.controlRoute(http.MethodGet, s.controlStatus))
+	mux.HandleFunc("/capture", s.controlRoute(http.MethodPost, s.controlCapture))
+	mux.HandleFunc("/reset", s.controlRoute(http.MethodPost, s.controlReset))
+	mux.HandleFunc("/pause", s.controlRoute(http.MethodPost, s.controlPause(true)))
+	mux.HandleFunc("/resume", s.controlRoute(http.MethodPost, s.controlPause(false)))
+	mux.HandleFunc("/log-level", s.controlRoute(http.MethodPost, s.controlLogLevel))
+
+	return mux
+}
+
+type controlError struct {
+	status  int
+	message string
+}
+
+func (e *controlError) Error() string {
+	return e.message
+}
+
+func (s *Service) controlRoute(method string, action func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		result, err := action(r)
+
+		if err != nil {
+			status := http.StatusInternalServerError
+
+			var cErr *controlError
+
+			if errors.As(err, &cErr) {
+				status = cErr.status
+			}
+
+			http.Error(w, err.Error(), status)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func (s *Service) controlStatus(r *http.Request) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := controlStatus{
+		Host:              s.config.hostID,
+		StartedAt:         s.startedAt,
+		Cumulative:        usageOf(*s.cumulativeStat),
+		Capturing:         s.capturing(),
+		PersistencePaused: s.persistencePaused(),
+		LogLevel:          zerolog.GlobalLevel().String(),
+	}
+
+	if tick := s.lastTick; tick != nil && tick.Interval > 0 {
+		seconds := tick.Interval.Seconds()
+
+		status.Rate = controlUsage{
+			Sent:     uint64(float64(tick.Stat.BytesSent) / seconds),
+			Received: uint64(float64(tick.Stat.BytesRecv) / seconds),
+			Total:    uint64(float64(tick.Stat.BytesTotal) / seconds),
+		}
+	}
+
+	return status, nil
+}
+
+// Capture right away, then flush the snapshots still queued for the server.
+func (s *Service) controlCapture(r *http.Request) (interface{}, error) {
+	if !s.capturing() {
+		return nil, &controlError{http.StatusConflict, "snapshots are not captured, see --persist, --server and the sink flags"}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+
+	select {
+	case s.captureNow <- done:
+	case <-ctx.Done():
+		return nil, &controlError{http.StatusServiceUnavailable, "capture is busy"}
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, &controlError{http.StatusGatewayTimeout, "timed out waiting for the capture"}
+	}
+
+	if s.forwarder != nil {
+		if err := s.forwarder.Flush(ctx); err != nil {
+			return nil, &controlError{http.StatusBadGateway, fmt.Sprintf("captured, but failed to flush to the server: %v", err)}
+		}
+	}
+
+	s.logger.Info().Msg("capture forced over the control socket")
+
+	return controlReply{Message: "captured"}, nil
+}
+
+func (s *Service) controlReset(r *http.Request) (interface{}, error) {
+	s.mu.Lock()
+	cumulative := *s.cumulativeStat
+	*s.cumulativeStat = m.NetStat{}
+	s.mu.Unlock()
+
+	s.logger.Info().Str("cumulative", util.ByteCountSI(cumulative.BytesTotal)).Msg("session counter reset over the control socket")
+
+	return controlReply{Message: fmt.Sprintf("session counter reset from %s", util.ByteCountSI(cumulative.BytesTotal))}, nil
+}
+
+func (s *Service) controlPause(pause bool) func(r *http.Request) (interface{}, error) {
+	return func(r *http.Request) (interface{}, error) {
+		if s.snapshots == nil {
+			return nil, &controlError{http.StatusConflict, "snapshots are not persisted, see --persist"}
+		}
+
+		state := "resumed"
+
+		if pause {
+			state = "paused"
+		}
+
+		if !s.pausePersistence(pause) {
+			return controlReply{Message: "persistence already " + state}, nil
+		}
+
+		s.logger.Warn().Msg("persistence " + state + " over the control socket")
+
+		return controlReply{Message: "persistence " + state}, nil
+	}
+}
+
+func (s *Service) controlLogLevel(r *http.Request) (interface{}, error) {
+	level := r.URL.Query().Get("level")
+
+	for _, allowed := range helper.LogLevels {
+		if level == allowed {
+			zerolog.SetGlobalLevel(zerolog.Level(helper.GetLevel(level)))
+			return controlReply{Message: "log level set to " + level}, nil
+		}
+	}
+
+	return nil, &controlError{http.StatusBadRequest, fmt.Sprintf("level must be one of %v", helper.LogLevels)}
+}
+
+// Ctl talks to a running monitor over its control socket, e.g.
+// monitoor --control-socket /run/monitoor/control.sock ctl status
+func Ctl(socket string, args []string) error {
+	var asJSON bool
+
+	fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	fs.StringVar(&socket, "socket", socket, "Control socket of the running monitor")
+	fs.BoolVar(&asJSON, "json", false, "Print the raw JSON reply")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monitoor ctl [flags] status|capture|reset|pause|resume|log-level LEVEL")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if socket == "" {
+		return fmt.Errorf("no control socket given, see --control-socket")
+	}
+
+	client := &http.Client{
+		Timeout: time.Minute,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	var method, path string
+
+	switch command := fs.Arg(0); command {
+	case "status":
+		method, path = http.MethodGet, "/status"
+	case "capture", "reset", "pause", "resume":
+		method, path = http.MethodPost, "/"+command
+	case "log-level":
+		if fs.NArg() < 2 {
+			return fmt.Errorf("log-level requires one of %v", helper.LogLevels)
+		}
+
+		method, path = http.MethodPost, "/log-level?level="+fs.Arg(1)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown ctl command %q", command)
+	}
+
+	request, err := http.NewRequest(method, "http://monitoor"+path, nil)
+
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(request)
+
+	if err != nil {
+		return fmt.Errorf("failed to reach the monitor: %w", err)
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", strings.TrimSpace(string(body)))
+	}
+
+	if asJSON {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+
+	if path != "/status" {
+		var reply controlReply
+
+		if err = json.Unmarshal(body, &reply); err != nil {
+			return err
+		}
+
+		fmt.Println(reply.Message)
+
+		return nil
+	}
+
+	var status controlStatus
+
+	if err = json.Unmarshal(body, &status); err != nil {
+		return err
+	}
+
+	fmt.Printf("host:        %s\n", status.Host)
+	fmt.Printf("running for: %s\n", time.Since(status.StartedAt).Round(time.Second))
+	fmt.Printf("rate:        %s/s up, %s/s down\n", util.ByteCountSI(status.Rate.Sent), util.ByteCountSI(status.Rate.Received))
+	fmt.Printf("session:     %s (%s up, %s down)\n", util.ByteCountSI(status.Cumulative.Total), util.ByteCountSI(status.Cumulative.Sent), util.ByteCountSI(status.Cumulative.Received))
+	fmt.Printf("capturing:   %s\n", strconv.FormatBool(status.Capturing))
+	fmt.Printf("persistence: %s\n", map[bool]string{true: "paused", false: "active"}[status.PersistencePaused])
+	fmt.Printf("log level:   %s\n", status.LogLevel)
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	m "github.com/omarabdelaz1z/go-monitor/pkg/monitoor"
+	"github.com/rs/zerolog"
+)
+
+// Serve the control socket of s from a temporary directory, returning its
+// path and a client reaching it.
+func serveControl(t *testing.T, s *Service) (string, *http.Client) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	listener, err := listenControl(path, 0600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: s.controlHandler()}
+
+	go server.Serve(listener)
+
+	t.Cleanup(func() {
+		server.Close()
+		os.Remove(path)
+	})
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	return path, client
+}
+
+// Send a request to the control socket, returning the status and body.
+func control(t *testing.T, client *http.Client, method, path string) (int, string) {
+	t.Helper()
+
+	request, err := http.NewRequest(method, "http://monitoor"+path, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := client.Do(request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response.StatusCode, string(body)
+}
+
+func status(t *testing.T, client *http.Client) controlStatus {
+	t.Helper()
+
+	code, body := control(t, client, http.MethodGet, "/status")
+
+	if code != http.StatusOK {
+		t.Fatalf("got %d %s, expected the status", code, body)
+	}
+
+	var s controlStatus
+
+	if err := json.Unmarshal([]byte(body), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestControlSocket(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+
+	snapshots := newTestModel(t)
+
+	s := &Service{
+		config:         &Config{allowPersist: true, hostID: "web-1"},
+		snapshots:      snapshots,
+		logger:         zerolog.Nop(),
+		cumulativeStat: &m.NetStat{BytesSent: 1000, BytesRecv: 2000, BytesTotal: 3000},
+		periodicStat:   &m.NetStat{BytesSent: 10, BytesRecv: 20, BytesTotal: 30},
+		startedAt:      time.Now(),
+		lastTick:       &Tick{Interval: 2 * time.Second, Stat: &m.NetStat{BytesSent: 100, BytesRecv: 100, BytesTotal: 200}},
+		captureTicker:  time.NewTicker(time.Hour),
+		captureNow:     make(chan chan struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Capture(ctx, nil)
+
+	path, client := serveControl(t, s)
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("got mode %v, expected a socket only its owner can reach", info.Mode())
+	}
+
+	if _, err = listenControl(path, 0600); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("got %v, expected a socket in use not to be replaced", err)
+	}
+
+	st := status(t, client)
+
+	if st.Host != "web-1" || st.Cumulative.Total != 3000 || st.Rate.Total != 100 || !st.Capturing || st.PersistencePaused {
+		t.Errorf("got %+v, expected web-1 capturing 100 B/s after 3000 bytes", st)
+	}
+
+	if code, _ := control(t, client, http.MethodPost, "/status"); code != http.StatusMethodNotAllowed {
+		t.Errorf("got %d, expected the status to be read only", code)
+	}
+
+	if code, body := control(t, client, http.MethodPost, "/capture"); code != http.StatusOK || !strings.Contains(body, "captured") {
+		t.Fatalf("got %d %s, expected a capture", code, body)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+
+	if stat, err := snapshots.GetStatByDate(ctx, today); err != nil || stat.HoursMonitored != 1 || stat.Total != 30 {
+		t.Errorf("got %+v, %v, expected the periodic usage to be persisted", stat, err)
+	}
+
+	if code, body := control(t, client, http.MethodPost, "/pause"); code != http.StatusOK || !strings.Contains(body, "persistence paused") {
+		t.Errorf("got %d %s, expected persistence to be paused", code, body)
+	}
+
+	if code, body := control(t, client, http.MethodPost, "/pause"); code != http.StatusOK || !strings.Contains(body, "already paused") {
+		t.Errorf("got %d %s, expected persistence to be paused already", code, body)
+	}
+
+	if !status(t, client).PersistencePaused {
+		t.Error("expected the status to report persistence paused")
+	}
+
+	s.mu.Lock()
+	s.periodicStat.BytesTotal = 50
+	s.mu.Unlock()
+
+	if code, body := control(t, client, http.MethodPost, "/capture"); code != http.StatusOK {
+		t.Fatalf("got %d %s, expected a capture", code, body)
+	}
+
+	if stat, err := snapshots.GetStatByDate(ctx, today); err != nil || stat.HoursMonitored != 1 {
+		t.Errorf("got %+v, %v, expected nothing persisted while paused", stat, err)
+	}
+
+	if code, body := control(t, client, http.MethodPost, "/resume"); code != http.StatusOK || !strings.Contains(body, "persistence resumed") || status(t, client).PersistencePaused {
+		t.Errorf("got %d %s, expected persistence to be resumed", code, body)
+	}
+
+	if code, body := control(t, client, http.MethodPost, "/reset"); code != http.StatusOK || !strings.Contains(body, "reset from 3.0 kB") {
+		t.Errorf("got %d %s, expected the session counter to be reset from 3 kB", code, body)
+	}
+
+	if st = status(t, client); st.Cumulative.Total != 0 {
+		t.Errorf("got %+v, expected the session counter to be reset", st.Cumulative)
+	}
+
+	if code, body := control(t, client, http.MethodPost, "/log-level?level=loud"); code != http.StatusBadRequest || !strings.Contains(body, "must be one of") {
+		t.Errorf("got %d %s, expected an invalid level to be rejected", code, body)
+	}
+
+	if code, _ := control(t, client, http.MethodPost, "/log-level?level=debug"); code != http.StatusOK || status(t, client).LogLevel != "debug" {
+		t.Errorf("got %d, expected the log level to be debug", code)
+	}
+}
+
+func TestControlSocketNotCapturing(t *testing.T) {
+	s := &Service{
+		config:         &Config{hostID: "web-1"},
+		logger:         zerolog.Nop(),
+		cumulativeStat: &m.NetStat{},
+		captureNow:     make(chan chan struct{}),
+	}
+
+	_, client := serveControl(t, s)
+
+	if st := status(t, client); st.Capturing {
+		t.Errorf("got %+v, expected nothing to be captured", st)
+	}
+
+	if code, body := control(t, client, http.MethodPost, "/capture"); code != http.StatusConflict || !strings.Contains(body, "not captured") {
+		t.Errorf("got %d %s, expected a capture to conflict", code, body)
+	}
+
+	if code, body := control(t, client, http.MethodPost, "/pause"); code != http.StatusConflict || !strings.Contains(body, "not persisted") {
+		t.Errorf("got %d %s, expected a pause to conflict", code, body)
+	}
+}
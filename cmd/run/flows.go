@@ -0,0 +1,143 @@
+package run
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/omarabdelaz1z/go-monitor/internal/traffic"
+	"github.com/omarabdelaz1z/go-monitor/pkg/netflow"
+	"github.com/omarabdelaz1z/go-monitor/pkg/pcap"
+	"github.com/rs/zerolog"
+)
+
+// Collect NetFlow v5/v9 and IPFIX exports from routers, e.g.
+// monitoor --dsn monitor.db collect-flows --listen :2055 --subnet-v4 24
+func collectFlows(logger zerolog.Logger, snapshots *model.SnapshotModel, captureTime time.Duration, args []string) error {
+	var (
+		listen, localNets          string
+		subnetBitsV4, subnetBitsV6 int
+		topEndpoints               int
+		grace                      time.Duration
+	)
+
+	fs := flag.NewFlagSet("collect-flows", flag.ContinueOnError)
+	fs.StringVar(&listen, "listen", ":2055", "UDP address flow exports are received on")
+	fs.StringVar(&localNets, "local-nets", traffic.DefaultLocalNets, "Comma separated CIDRs of the local network")
+	fs.IntVar(&subnetBitsV4, "subnet-v4", 24, "Prefix length local IPv4 addresses are grouped by (0 disables)")
+	fs.IntVar(&subnetBitsV6, "subnet-v6", 64, "Prefix length local IPv6 addresses are grouped by (0 disables)")
+	fs.IntVar(&topEndpoints, "top-endpoints", 50, "Remote endpoints kept per interval")
+	fs.DurationVar(&grace, "grace", 5*time.Minute, "Time flows of an interval are awaited after it ends, e.g. the exporters' active timeout")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	local, err := traffic.ParseLocality(localNets)
+
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenPacket("udp", listen)
+
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		mu          sync.Mutex
+		aggregators = map[string]*traffic.Aggregator{} // by exporter
+		decoder     = netflow.NewDecoder()
+	)
+
+	handle := func(flows []netflow.Flow) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, f := range flows {
+			aggregator, ok := aggregators[f.Exporter]
+
+			if !ok {
+				aggregator = traffic.NewAggregator(captureTime, local)
+				aggregator.SubnetBitsV4, aggregator.SubnetBitsV6 = subnetBitsV4, subnetBitsV6
+				aggregators[f.Exporter] = aggregator
+
+				logger.Info().Str("exporter", f.Exporter).Msg("new flow exporter")
+			}
+
+			aggregator.Add(f.Time, pcap.ProtocolName(f.Protocol), f.Src, f.Dst, f.SrcPort, f.DstPort, f.Bytes)
+		}
+	}
+
+	// Persist the intervals that ended by the given time, every interval when
+	// shutting down. Flows exported later are added to the stored interval.
+	flush := func(before time.Time) {
+		mu.Lock()
+		pending := map[string][]traffic.Bucket{}
+
+		for exporter, aggregator := range aggregators {
+			pending[exporter] = aggregator.Flush(before)
+		}
+
+		mu.Unlock()
+
+		for exporter, buckets := range pending {
+			persistFlows(context.Background(), logger, snapshots, "netflow:"+exporter, buckets, topEndpoints)
+		}
+	}
+
+	logger.Info().Str("listen", conn.LocalAddr().String()).Msg("collecting flows")
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- netflow.Serve(ctx, conn, decoder, handle, func(err error) {
+			logger.Warn().Err(err).Msg("failed to decode flow export")
+		})
+	}()
+
+	ticker := time.NewTicker(captureTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			flush(now.Add(-grace))
+		case err = <-done:
+			flush(time.Now().Add(captureTime))
+			logger.Info().Uint64("missing_templates", decoder.MissingTemplates).Msg("flow collector stopped")
+
+			return err
+		}
+	}
+}
+
+// Persist the flushed intervals of an exporter, adding those stored already,
+// i.e. flows arriving after the grace period, to the stored ones.
+func persistFlows(ctx context.Context, logger zerolog.Logger, snapshots *model.SnapshotModel, source string, buckets []traffic.Bucket, topEndpoints int) {
+	for _, b := range buckets {
+		outcome, err := persistBucket(ctx, snapshots, source, b, topEndpoints, true)
+
+		if err != nil {
+			logger.Error().Err(err).Str("source", source).Time("start", b.Start).Uint64("total", b.Stat.Total).Msg("failed to persist flows, they are dropped")
+			continue
+		}
+
+		if outcome == model.IntervalMerged {
+			logger.Info().Str("source", source).Time("start", b.Start).Uint64("total", b.Stat.Total).Msg("late flows added to a stored interval")
+			continue
+		}
+
+		logger.Debug().Str("source", source).Time("start", b.Start).Uint64("total", b.Stat.Total).Msg("flows persisted")
+	}
+}
@@ -0,0 +1,54 @@
+package run
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/omarabdelaz1z/go-monitor/internal/traffic"
+	"github.com/rs/zerolog"
+)
+
+func TestPersistLateFlows(t *testing.T) {
+	ctx := context.Background()
+	snapshots := newTestModel(t)
+
+	local, err := traffic.ParseLocality(traffic.DefaultLocalNets)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aggregator := traffic.NewAggregator(time.Hour, local)
+	start := time.Date(2022, 10, 5, 20, 0, 0, 0, time.UTC)
+	src, dst := net.ParseIP("192.168.1.10"), net.ParseIP("192.0.2.10")
+
+	aggregator.Add(start.Add(10*time.Minute), "tcp", src, dst, 50000, 443, 100)
+	persistFlows(ctx, zerolog.Nop(), snapshots, "netflow:192.0.2.1", aggregator.Flush(start.Add(time.Hour)), 10)
+
+	// A flow of the interval exported after it was stored.
+	aggregator.Add(start.Add(50*time.Minute), "udp", src, dst, 50000, 53, 20)
+	persistFlows(ctx, zerolog.Nop(), snapshots, "netflow:192.0.2.1", aggregator.Flush(start.Add(time.Hour)), 10)
+
+	hours, err := snapshots.WithSource("netflow:192.0.2.1").GetPeriodStats(ctx, 0, 1<<40, model.PeriodHour, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hours) != 1 || hours[0].Snapshots != 1 || hours[0].Total != 120 {
+		t.Errorf("got %+v, expected the late flow added to the interval", hours)
+	}
+
+	usage, err := snapshots.WithSource("netflow:192.0.2.1").GetProtocolUsageByDate(ctx, "2022-10-05")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usage) != 2 || usage[0].Protocol != "tcp" || usage[0].Total != 100 || usage[1].Total != 20 {
+		t.Errorf("got %+v, expected the protocols of both flushes", usage)
+	}
+}
@@ -0,0 +1,123 @@
+package helper
+
+import (
+	"sort"
+
+	m "github.com/omarabdelaz1z/go-monitor/pkg/monitoor"
+)
+
+// Increment the current netstat by the other netstat.
+func Incr(current, new *m.NetStat) m.NetStat {
+	return m.NetStat{
+		BytesSent:  current.BytesSent + new.BytesSent,
+		BytesRecv:  current.BytesRecv + new.BytesRecv,
+		BytesTotal: current.BytesTotal + new.BytesTotal,
+	}
+}
+
+// A netstat of the delta between the current netstat and the other netstat.
+func Delta(current, previous *m.NetStat) *m.NetStat {
+	return &m.NetStat{
+		BytesSent:  current.BytesSent - previous.BytesSent,
+		BytesRecv:  current.BytesRecv - previous.BytesRecv,
+		BytesTotal: current.BytesTotal - previous.BytesTotal,
+	}
+}
+
+func UpdateWith(old *m.NetStat, new m.NetStat) {
+	old.BytesSent = new.BytesSent
+	old.BytesRecv = new.BytesRecv
+	old.BytesTotal = new.BytesTotal
+}
+
+// Increment the current protocol counters by the other ones.
+func ProtoIncr(current, new *m.ProtoStat) m.ProtoStat {
+	return m.ProtoStat{
+		TCP: m.TCPStat{
+			InSegs:      current.TCP.InSegs + new.TCP.InSegs,
+			OutSegs:     current.TCP.OutSegs + new.TCP.OutSegs,
+			RetransSegs: current.TCP.RetransSegs + new.TCP.RetransSegs,
+			SynRetrans:  current.TCP.SynRetrans + new.TCP.SynRetrans,
+			Timeouts:    current.TCP.Timeouts + new.TCP.Timeouts,
+			EstabResets: current.TCP.EstabResets + new.TCP.EstabResets,
+			OutRsts:     current.TCP.OutRsts + new.TCP.OutRsts,
+			InErrs:      current.TCP.InErrs + new.TCP.InErrs,
+		},
+		UDP: m.UDPStat{
+			InDatagrams:  current.UDP.InDatagrams + new.UDP.InDatagrams,
+			OutDatagrams: current.UDP.OutDatagrams + new.UDP.OutDatagrams,
+			NoPorts:      current.UDP.NoPorts + new.UDP.NoPorts,
+			InErrors:     current.UDP.InErrors + new.UDP.InErrors,
+			RcvbufErrors: current.UDP.RcvbufErrors + new.UDP.RcvbufErrors,
+			SndbufErrors: current.UDP.SndbufErrors + new.UDP.SndbufErrors,
+		},
+		ICMP: m.ICMPStat{
+			InMsgs:   current.ICMP.InMsgs + new.ICMP.InMsgs,
+			OutMsgs:  current.ICMP.OutMsgs + new.ICMP.OutMsgs,
+			InErrors: current.ICMP.InErrors + new.ICMP.InErrors,
+		},
+	}
+}
+
+// The protocol counters gained between the previous and the current reading.
+// A counter that went backwards, e.g. after a namespace reset, counts as zero.
+func ProtoDelta(current, previous *m.ProtoStat) *m.ProtoStat {
+	return &m.ProtoStat{
+		TCP: m.TCPStat{
+			InSegs:      sub(current.TCP.InSegs, previous.TCP.InSegs),
+			OutSegs:     sub(current.TCP.OutSegs, previous.TCP.OutSegs),
+			RetransSegs: sub(current.TCP.RetransSegs, previous.TCP.RetransSegs),
+			SynRetrans:  sub(current.TCP.SynRetrans, previous.TCP.SynRetrans),
+			Timeouts:    sub(current.TCP.Timeouts, previous.TCP.Timeouts),
+			EstabResets: sub(current.TCP.EstabResets, previous.TCP.EstabResets),
+			OutRsts:     sub(current.TCP.OutRsts, previous.TCP.OutRsts),
+			InErrs:      sub(current.TCP.InErrs, previous.TCP.InErrs),
+		},
+		UDP: m.UDPStat{
+			InDatagrams:  sub(current.UDP.InDatagrams, previous.UDP.InDatagrams),
+			OutDatagrams: sub(current.UDP.OutDatagrams, previous.UDP.OutDatagrams),
+			NoPorts:      sub(current.UDP.NoPorts, previous.UDP.NoPorts),
+			InErrors:     sub(current.UDP.InErrors, previous.UDP.InErrors),
+			RcvbufErrors: sub(current.UDP.RcvbufErrors, previous.UDP.RcvbufErrors),
+			SndbufErrors: sub(current.UDP.SndbufErrors, previous.UDP.SndbufErrors),
+		},
+		ICMP: m.ICMPStat{
+			InMsgs:   sub(current.ICMP.InMsgs, previous.ICMP.InMsgs),
+			OutMsgs:  sub(current.ICMP.OutMsgs, previous.ICMP.OutMsgs),
+			InErrors: sub(current.ICMP.InErrors, previous.ICMP.InErrors),
+		},
+	}
+}
+
+// The usage of each interface between the previous and the current reading,
+// sorted by name. Interfaces missing from the previous reading are skipped.
+func InterfaceDelta(current, previous map[string]m.NetStat) []m.InterfaceStat {
+	stats := make([]m.InterfaceStat, 0, len(current))
+
+	for name, c := range current {
+		p, ok := previous[name]
+
+		if !ok {
+			continue
+		}
+
+		sent, recv := sub(c.BytesSent, p.BytesSent), sub(c.BytesRecv, p.BytesRecv)
+
+		stats = append(stats, m.InterfaceStat{
+			Name:    name,
+			NetStat: m.NetStat{BytesSent: sent, BytesRecv: recv, BytesTotal: sent + recv},
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+
+	return stats
+}
+
+func sub(current, previous uint64) uint64 {
+	if current < previous {
+		return 0
+	}
+
+	return current - previous
+}
@@ -0,0 +1,104 @@
+package helper
+
+import (
+	"testing"
+
+	m "github.com/omarabdelaz1z/go-monitor/pkg/monitoor"
+)
+
+func TestIncr(t *testing.T) {
+	A := &m.NetStat{
+		BytesSent:  20,
+		BytesRecv:  5,
+		BytesTotal: 25,
+	}
+
+	B := &m.NetStat{
+		BytesSent:  10,
+		BytesRecv:  3,
+		BytesTotal: 13,
+	}
+
+	C := Incr(A, B)
+
+	if C.BytesSent != 30 || C.BytesRecv != 8 || C.BytesTotal != 38 {
+		t.Errorf("Incr failed")
+	}
+}
+
+func TestDelta(t *testing.T) {
+	A := &m.NetStat{
+		BytesSent:  20,
+		BytesRecv:  5,
+		BytesTotal: 25,
+	}
+
+	B := &m.NetStat{
+		BytesSent:  10,
+		BytesRecv:  3,
+		BytesTotal: 13,
+	}
+
+	C := Delta(A, B)
+
+	if C.BytesSent != 10 || C.BytesRecv != 2 || C.BytesTotal != 12 {
+		t.Errorf("got: %d %d %d. %s", C.BytesSent, C.BytesRecv, C.BytesTotal, "expected: 10 3 12")
+	}
+}
+
+func TestProtoDelta(t *testing.T) {
+	A := &m.ProtoStat{
+		TCP:  m.TCPStat{InSegs: 100, OutSegs: 80, RetransSegs: 3},
+		UDP:  m.UDPStat{InDatagrams: 10, RcvbufErrors: 1},
+		ICMP: m.ICMPStat{InMsgs: 2},
+	}
+
+	B := &m.ProtoStat{
+		TCP:  m.TCPStat{InSegs: 60, OutSegs: 50, RetransSegs: 1},
+		UDP:  m.UDPStat{InDatagrams: 4, RcvbufErrors: 2},
+		ICMP: m.ICMPStat{InMsgs: 2},
+	}
+
+	C := ProtoDelta(A, B)
+
+	if C.TCP.InSegs != 40 || C.TCP.OutSegs != 30 || C.TCP.RetransSegs != 2 {
+		t.Errorf("got: %d %d %d. %s", C.TCP.InSegs, C.TCP.OutSegs, C.TCP.RetransSegs, "expected: 40 30 2")
+	}
+
+	if C.UDP.InDatagrams != 6 || C.UDP.RcvbufErrors != 0 || C.ICMP.InMsgs != 0 {
+		t.Errorf("got: %d %d %d. %s", C.UDP.InDatagrams, C.UDP.RcvbufErrors, C.ICMP.InMsgs, "expected: 6 0 0")
+	}
+
+	D := ProtoIncr(A, C)
+
+	if D.TCP.InSegs != 140 || D.UDP.InDatagrams != 16 {
+		t.Errorf("got: %d %d. %s", D.TCP.InSegs, D.UDP.InDatagrams, "expected: 140 16")
+	}
+}
+
+func TestInterfaceDelta(t *testing.T) {
+	previous := map[string]m.NetStat{
+		"eth0": {BytesSent: 100, BytesRecv: 50, BytesTotal: 150},
+		"wg0":  {BytesSent: 10, BytesRecv: 900, BytesTotal: 910},
+	}
+
+	current := map[string]m.NetStat{
+		"eth0": {BytesSent: 160, BytesRecv: 80, BytesTotal: 240},
+		"wg0":  {BytesSent: 5, BytesRecv: 1000, BytesTotal: 1005}, // re-created, sent went backwards
+		"lo":   {BytesSent: 7, BytesRecv: 7, BytesTotal: 14},
+	}
+
+	stats := InterfaceDelta(current, previous)
+
+	if len(stats) != 2 || stats[0].Name != "eth0" || stats[1].Name != "wg0" {
+		t.Fatalf("got: %+v. expected: eth0 and wg0", stats)
+	}
+
+	if s := stats[0]; s.BytesSent != 60 || s.BytesRecv != 30 || s.BytesTotal != 90 {
+		t.Errorf("got: %d %d %d. %s", s.BytesSent, s.BytesRecv, s.BytesTotal, "expected: 60 30 90")
+	}
+
+	if s := stats[1]; s.BytesSent != 0 || s.BytesRecv != 100 || s.BytesTotal != 100 {
+		t.Errorf("got: %d %d %d. %s", s.BytesSent, s.BytesRecv, s.BytesTotal, "expected: 0 100 100")
+	}
+}
@@ -0,0 +1,194 @@
+package run
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/omarabdelaz1z/go-monitor/internal/traffic"
+	"github.com/omarabdelaz1z/go-monitor/internal/util"
+	"github.com/omarabdelaz1z/go-monitor/pkg/pcap"
+	"github.com/rs/zerolog"
+)
+
+// Import packet captures into the snapshot store, e.g.
+// monitoor --dsn monitor.db import-pcap --source office capture.pcapng
+func importPcap(ctx context.Context, logger zerolog.Logger, snapshots *model.SnapshotModel, args []string) error {
+	var (
+		source, localNets string
+		bucket            time.Duration
+		topEndpoints      int
+	)
+
+	fs := flag.NewFlagSet("import-pcap", flag.ContinueOnError)
+	fs.StringVar(&source, "source", "", "Source label of the imported traffic (default pcap:<first file name>)")
+	fs.DurationVar(&bucket, "bucket", time.Hour*1, "Interval the packets are summed into")
+	fs.StringVar(&localNets, "local-nets", traffic.DefaultLocalNets, "Comma separated CIDRs of the local network")
+	fs.IntVar(&topEndpoints, "top-endpoints", 50, "Remote endpoints kept per interval")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no capture files given")
+	}
+
+	if source == "" {
+		source = "pcap:" + filepath.Base(fs.Arg(0))
+	}
+
+	local, err := traffic.ParseLocality(localNets)
+
+	if err != nil {
+		return err
+	}
+
+	aggregator := traffic.NewAggregator(bucket, local)
+
+	for _, name := range fs.Args() {
+		packets, skipped, err := readCapture(name, aggregator)
+
+		if err != nil {
+			return err
+		}
+
+		logger.Info().Str("file", name).Int("packets", packets).Int("skipped", skipped).Msg("capture read")
+	}
+
+	buckets := aggregator.Buckets()
+	protocols := map[string]model.Stat{}
+	imported, duplicates, conflicts := 0, 0, 0
+
+	for _, b := range buckets {
+		outcome, err := persistBucket(ctx, snapshots, source, b, topEndpoints, false)
+
+		if err != nil {
+			return err
+		}
+
+		// Importing a capture again changes nothing, but another capture of
+		// the same interval and source is not imported either.
+		switch outcome {
+		case model.IntervalDuplicate:
+			duplicates++
+			logger.Info().Str("source", source).Time("end", b.End).Msg("interval imported already, skipped")
+			continue
+		case model.IntervalConflict:
+			conflicts++
+			logger.Warn().Str("source", source).Time("end", b.End).Uint64("total", b.Stat.Total).Msg("interval stored with other counts, its traffic is dropped")
+			continue
+		}
+
+		imported++
+
+		for protocol, stat := range b.Protocols {
+			protocols[protocol] = model.Stat{
+				Sent:     protocols[protocol].Sent + stat.Sent,
+				Received: protocols[protocol].Received + stat.Received,
+				Total:    protocols[protocol].Total + stat.Total,
+			}
+		}
+	}
+
+	logger.Info().Str("source", source).Int("intervals", imported).Int("duplicates", duplicates).Int("conflicts", conflicts).Msg("capture imported")
+
+	caption := fmt.Sprintf("Imported %d intervals as %s", imported, source)
+
+	if conflicts > 0 {
+		caption += fmt.Sprintf(", dropped %d stored with other counts", conflicts)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetCaption(caption)
+	t.AppendHeader(table.Row{"Protocol", "Uploaded", "Downloaded", "Total"})
+
+	for protocol, stat := range protocols {
+		t.AppendRow(table.Row{
+			protocol,
+			util.ByteCountSI(stat.Sent),
+			util.ByteCountSI(stat.Received),
+			util.ByteCountSI(stat.Total),
+		})
+	}
+
+	t.SortBy([]table.SortBy{{Name: "Protocol"}})
+	t.Render()
+
+	return nil
+}
+
+// Persist the usage, its protocols, local subnets and top remote endpoints of
+// an interval at once, stamped with its end like the snapshots of the monitor.
+// If the source has a snapshot of the interval already, the bucket is added to
+// it when merge is set, and left out otherwise.
+func persistBucket(ctx context.Context, snapshots *model.SnapshotModel, source string, b traffic.Bucket, topEndpoints int, merge bool) (model.IntervalOutcome, error) {
+	interval := model.Interval{
+		Snapshot:  model.Snapshot{Timestamp: b.End.Unix(), Source: source, Stat: b.Stat},
+		Endpoints: b.TopEndpoints(topEndpoints),
+	}
+
+	for protocol, stat := range b.Protocols {
+		interval.Protocols = append(interval.Protocols, model.ProtocolUsage{Protocol: protocol, Stat: stat})
+	}
+
+	for subnet, stat := range b.Subnets {
+		interval.Subnets = append(interval.Subnets, model.SubnetStat{Subnet: subnet, Stat: stat})
+	}
+
+	outcome, err := snapshots.StoreInterval(ctx, interval, merge)
+
+	if err != nil {
+		return outcome, fmt.Errorf("failed to persist interval: %w", err)
+	}
+
+	return outcome, nil
+}
+
+// Feed every IP packet of a capture file to the aggregator.
+func readCapture(name string, aggregator *traffic.Aggregator) (packets, skipped int, err error) {
+	file, err := os.Open(name)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open capture: %w", err)
+	}
+
+	defer file.Close()
+
+	reader, err := pcap.NewReader(file)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	for {
+		p, err := reader.Next()
+
+		if err == io.EOF {
+			return packets, skipped, nil
+		}
+
+		if err != nil {
+			return packets, skipped, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		packets++
+
+		flow, ok := pcap.Decode(p)
+
+		// Simple packets captured before any timestamped one can't be placed.
+		if !ok || p.Timestamp.IsZero() {
+			skipped++
+			continue
+		}
+
+		aggregator.Add(p.Timestamp, pcap.ProtocolName(flow.Protocol), flow.Src, flow.Dst, flow.SrcPort, flow.DstPort, uint64(p.Length))
+	}
+}
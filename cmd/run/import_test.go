@@ -0,0 +1,102 @@
+package run
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+func newTestModel(t *testing.T) *model.SnapshotModel {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = model.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return model.NewSnapshotModel(db).WithLocation(time.UTC)
+}
+
+func TestImportPcap(t *testing.T) {
+	ctx := context.Background()
+	snapshots := newTestModel(t)
+
+	args := []string{"--source", "office", "../../pkg/pcap/testdata/capture.pcap"}
+
+	// Importing the capture again changes nothing.
+	for i := 0; i < 2; i++ {
+		if err := importPcap(ctx, zerolog.Nop(), snapshots, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hours, err := snapshots.WithSource("office").GetPeriodStats(ctx, 0, 1<<40, model.PeriodHour, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The intervals start at 20:00 and 21:00, and are stamped with their end.
+	if len(hours) != 2 || hours[0].Period != "2022-10-05T21:00:00" || hours[0].Snapshots != 1 || hours[0].Total != 1574 || hours[1].Total != 74 {
+		t.Errorf("got %+v, expected each interval once at its end", hours)
+	}
+
+	usage, err := snapshots.WithSource("office").GetProtocolUsageByDate(ctx, "2022-10-05")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usage) != 2 || usage[0].Protocol != "tcp" || usage[0].Total != 1574 || usage[1].Protocol != "udp" || usage[1].Total != 74 {
+		t.Errorf("got %+v, expected the bytes of tcp and udp once", usage)
+	}
+}
+
+func TestImportPcapConflict(t *testing.T) {
+	ctx := context.Background()
+	snapshots := newTestModel(t)
+
+	// Another capture of the first interval was imported before.
+	end := time.Date(2022, 10, 5, 21, 0, 0, 0, time.UTC).Unix()
+
+	if err := snapshots.Insert(ctx, &model.Snapshot{Timestamp: end, Source: "office", Stat: model.Stat{Sent: 1, Received: 1, Total: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importPcap(ctx, zerolog.Nop(), snapshots, []string{"--source", "office", "../../pkg/pcap/testdata/capture.pcap"}); err != nil {
+		t.Fatal(err)
+	}
+
+	hours, err := snapshots.WithSource("office").GetPeriodStats(ctx, 0, 1<<40, model.PeriodHour, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hours) != 2 || hours[0].Total != 2 || hours[1].Total != 74 {
+		t.Errorf("got %+v, expected the stored interval kept and the other imported", hours)
+	}
+
+	usage, err := snapshots.WithSource("office").GetProtocolUsageByDate(ctx, "2022-10-05")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing of the conflicting interval is stored.
+	if len(usage) != 1 || usage[0].Protocol != "udp" {
+		t.Errorf("got %+v, expected the protocols of the imported interval only", usage)
+	}
+}
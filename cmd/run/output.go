@@ -0,0 +1,111 @@
+package run
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	m "github.com/omarabdelaz1z/go-monitor/pkg/monitoor"
+)
+
+// How the live display is written.
+const (
+	outputConsole = "console"
+	outputJSONL   = "jsonl"
+)
+
+type usageRecord struct {
+	Sent         uint64  `json:"sent"`
+	Received     uint64  `json:"received"`
+	Total        uint64  `json:"total"`
+	SentRate     float64 `json:"sent_rate"`
+	ReceivedRate float64 `json:"received_rate"`
+	TotalRate    float64 `json:"total_rate"`
+}
+
+type interfaceRecord struct {
+	Name string `json:"interface"`
+	usageRecord
+}
+
+type protocolRecord struct {
+	TCPIn           uint64 `json:"tcp_in"`
+	TCPOut          uint64 `json:"tcp_out"`
+	TCPRetrans      uint64 `json:"tcp_retrans"`
+	TCPResets       uint64 `json:"tcp_resets"`
+	UDPIn           uint64 `json:"udp_in"`
+	UDPOut          uint64 `json:"udp_out"`
+	UDPRcvbufErrors uint64 `json:"udp_rcvbuf_errors"`
+	ICMPIn          uint64 `json:"icmp_in"`
+	ICMPOut         uint64 `json:"icmp_out"`
+}
+
+// A tickRecord is one line of the jsonl output. Byte counts are raw integers,
+// rates are in bytes per second over the interval.
+type tickRecord struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Interval   float64           `json:"interval_seconds"`
+	Host       string            `json:"host"`
+	Interface  string            `json:"interface"`
+	Cumulative uint64            `json:"cumulative"`
+	Interfaces []interfaceRecord `json:"interfaces,omitempty"`
+	Protocols  *protocolRecord   `json:"protocols,omitempty"`
+	usageRecord
+}
+
+func newUsageRecord(stat m.NetStat, interval time.Duration) usageRecord {
+	seconds := interval.Seconds()
+
+	r := usageRecord{
+		Sent:     stat.BytesSent,
+		Received: stat.BytesRecv,
+		Total:    stat.BytesTotal,
+	}
+
+	if seconds > 0 {
+		r.SentRate = float64(stat.BytesSent) / seconds
+		r.ReceivedRate = float64(stat.BytesRecv) / seconds
+		r.TotalRate = float64(stat.BytesTotal) / seconds
+	}
+
+	return r
+}
+
+// Write a tick as a single JSON object followed by a newline.
+func writeTick(w io.Writer, host string, tick *Tick, cumulative uint64) error {
+	return json.NewEncoder(w).Encode(newTickRecord(host, tick, cumulative))
+}
+
+func newTickRecord(host string, tick *Tick, cumulative uint64) tickRecord {
+	record := tickRecord{
+		Timestamp:   tick.At.UTC(),
+		Interval:    tick.Interval.Seconds(),
+		Host:        host,
+		Interface:   "all",
+		Cumulative:  cumulative,
+		usageRecord: newUsageRecord(*tick.Stat, tick.Interval),
+	}
+
+	for _, iface := range tick.Interfaces {
+		record.Interfaces = append(record.Interfaces, interfaceRecord{
+			Name:        iface.Name,
+			usageRecord: newUsageRecord(iface.NetStat, tick.Interval),
+		})
+	}
+
+	if p := tick.Protocols; p != nil {
+		record.Protocols = &protocolRecord{
+			TCPIn:           p.TCP.InSegs,
+			TCPOut:          p.TCP.OutSegs,
+			TCPRetrans:      p.TCP.RetransSegs,
+			TCPResets:       p.TCP.EstabResets + p.TCP.OutRsts,
+			UDPIn:           p.UDP.InDatagrams,
+			UDPOut:          p.UDP.OutDatagrams,
+			UDPRcvbufErrors: p.UDP.RcvbufErrors,
+			ICMPIn:          p.ICMP.InMsgs,
+			ICMPOut:         p.ICMP.OutMsgs,
+		}
+	}
+
+	return record
+}
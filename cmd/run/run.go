@@ -0,0 +1,460 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/bootstrap"
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+	"github.com/omarabdelaz1z/go-monitor/cmd/helper"
+	"github.com/omarabdelaz1z/go-monitor/cmd/run/sink"
+	"github.com/omarabdelaz1z/go-monitor/internal/dashboard"
+	"github.com/omarabdelaz1z/go-monitor/internal/fleet"
+	"github.com/omarabdelaz1z/go-monitor/internal/maintenance"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/omarabdelaz1z/go-monitor/internal/systemd"
+	m "github.com/omarabdelaz1z/go-monitor/pkg/monitoor"
+)
+
+// Config of the monitor, see Flags.
+type Config struct {
+	monitorTime, captureTime time.Duration
+
+	base *config.Config
+
+	allowPersist bool
+
+	topProcesses int
+
+	perUnit    bool
+	cgroupRoot string
+
+	perNetns bool
+
+	topEndpoints   int
+	endpointSource string
+	resolve        bool
+	resolveTTL     time.Duration
+
+	protocols bool
+
+	snmpDevices     []string
+	snmpCredentials m.SNMPCredentials
+	snmpTimeout     time.Duration
+
+	server, hostID, token string
+	agentBuffer           int
+
+	influxURL, influxToken string
+	remoteWriteURL         string
+	remoteWriteToken       string
+	sinkPoints             string
+	sinkBatch              sink.BatchConfig
+
+	mqtt         sink.MQTTConfig
+	mqttQoS      int
+	mqttInterval time.Duration
+
+	statsdAddress, statsdPrefix string
+	statsdMTU                   int
+	statsdBatch                 sink.BatchConfig
+
+	output string
+
+	liveListen string
+
+	controlSocket string
+	controlMode   string
+
+	maintenanceInterval time.Duration
+	maintenance         maintenance.Options
+}
+
+// Flags registers the monitor's flags on the flag set, the shared ones
+// included, and returns the configuration they are parsed into.
+func Flags(fs *flag.FlagSet, base *config.Config) *Config {
+	var (
+		mCfg *Config = &Config{
+			base:         base,
+			allowPersist: false,
+			monitorTime:  time.Duration(0),
+			captureTime:  time.Duration(0),
+
+			endpointSource: m.EndpointSourceSockDiag,
+
+			snmpCredentials: m.SNMPCredentials{Version: "2c"},
+
+			sinkPoints: sinkPointsCapture,
+
+			output: outputConsole,
+		}
+	)
+
+	bootstrap.Flags(fs, mCfg.base)
+
+	fs.DurationVar(&mCfg.captureTime, "capture-time", time.Hour*1, "Capture time")
+	fs.DurationVar(&mCfg.monitorTime, "monitor-time", time.Second*1, "Monitor time")
+	fs.BoolVar(&mCfg.allowPersist, "persist", false, "Persist data to database")
+	fs.StringVar(&mCfg.controlSocket, "control-socket", os.Getenv("MONITOOR_CONTROL_SOCKET"), "Unix socket to control the running monitor with, see monitoor ctl")
+	fs.StringVar(&mCfg.controlMode, "control-mode", "0600", "Permissions of the control socket, in octal")
+	fs.DurationVar(&mCfg.maintenanceInterval, "maintenance-interval", 0, "Run the database maintenance this often while persisting, e.g. 24h (0 disables)")
+	fs.StringVar(&mCfg.maintenance.Backup, "maintenance-backup", "", "Directory, or file, the database is backed up to by the maintenance")
+	fs.IntVar(&mCfg.maintenance.Keep, "maintenance-keep", 7, "Backups kept in the maintenance backup directory (0 keeps them all)")
+	fs.BoolVar(&mCfg.maintenance.Repair, "maintenance-repair", false, "Repair anomalous byte counts during maintenance, rather than only report them")
+	fs.BoolVar(&mCfg.maintenance.Vacuum, "maintenance-vacuum", false, "Vacuum the database during maintenance")
+	fs.StringVar(&mCfg.liveListen, "live-listen", "", "Serve live ticks as server-sent events on this address for the dashboard, e.g. localhost:9201")
+	helper.EnumFlagSet(fs, &mCfg.output, "output", []string{outputConsole, outputJSONL}, "Live display format: human-readable console lines or one JSON object per tick")
+	fs.IntVar(&mCfg.topProcesses, "top-processes", 0, "Report the top N processes by usage (0 disables)")
+	fs.BoolVar(&mCfg.perUnit, "per-unit", false, "Account usage per systemd unit or container")
+	fs.StringVar(&mCfg.cgroupRoot, "cgroup-root", m.DefaultCgroupRoot, "cgroup v2 mount point")
+	fs.BoolVar(&mCfg.perNetns, "per-netns", false, "Report usage per network namespace (container)")
+	fs.IntVar(&mCfg.topEndpoints, "top-endpoints", 0, "Track the top N remote endpoints per capture (0 disables)")
+	helper.EnumFlagSet(fs, &mCfg.endpointSource, "endpoint-source", []string{m.EndpointSourceSockDiag, m.EndpointSourceConntrack}, "Source of remote endpoint counters")
+	fs.BoolVar(&mCfg.resolve, "resolve", false, "Resolve host names of remote endpoints")
+	fs.DurationVar(&mCfg.resolveTTL, "resolve-ttl", time.Hour*1, "How long resolved host names are cached, failed lookups a minute at most")
+	fs.BoolVar(&mCfg.protocols, "protocols", false, "Break traffic down by protocol and track TCP/UDP health counters")
+
+	helper.ListFlagSet(fs, &mCfg.snmpDevices, "snmp-device", "Poll the interfaces of a switch or router, given as [name=]host[:port] (repeatable)")
+	helper.EnumFlagSet(fs, &mCfg.snmpCredentials.Version, "snmp-version", []string{"2c", "3"}, "SNMP version of the polled devices")
+	fs.StringVar(&mCfg.snmpCredentials.Community, "snmp-community", "public", "SNMPv2c community")
+	fs.StringVar(&mCfg.snmpCredentials.User, "snmp-user", "", "SNMPv3 user name")
+	fs.StringVar(&mCfg.snmpCredentials.AuthProtocol, "snmp-auth-protocol", "", "SNMPv3 auth protocol: MD5, SHA, SHA224, SHA256, SHA384 or SHA512")
+	fs.StringVar(&mCfg.snmpCredentials.AuthPassphrase, "snmp-auth-pass", os.Getenv("SNMP_AUTH_PASS"), "SNMPv3 auth passphrase")
+	fs.StringVar(&mCfg.snmpCredentials.PrivProtocol, "snmp-priv-protocol", "", "SNMPv3 privacy protocol: DES, AES, AES192, AES256, AES192C or AES256C")
+	fs.StringVar(&mCfg.snmpCredentials.PrivPassphrase, "snmp-priv-pass", os.Getenv("SNMP_PRIV_PASS"), "SNMPv3 privacy passphrase")
+	fs.DurationVar(&mCfg.snmpTimeout, "snmp-timeout", time.Second*2, "Timeout of SNMP requests")
+
+	hostname, _ := os.Hostname()
+
+	fs.StringVar(&mCfg.server, "server", "", "Stream captured snapshots to the go-monitor server at this URL")
+	fs.StringVar(&mCfg.hostID, "host-id", hostname, "Host the captured snapshots are attributed to")
+	fs.StringVar(&mCfg.token, "token", os.Getenv("MONITOOR_TOKEN"), "Bearer token shared by agents and the server")
+	fs.IntVar(&mCfg.agentBuffer, "agent-buffer", 10000, "Snapshots buffered while the server is unreachable")
+
+	fs.StringVar(&mCfg.influxURL, "influx-url", "", "InfluxDB write URL, e.g. http://localhost:8086/api/v2/write?org=home&bucket=network")
+	fs.StringVar(&mCfg.influxToken, "influx-token", os.Getenv("INFLUX_TOKEN"), "InfluxDB API token")
+	fs.StringVar(&mCfg.remoteWriteURL, "remote-write-url", "", "Prometheus remote-write URL, e.g. http://localhost:9090/api/v1/write")
+	fs.StringVar(&mCfg.remoteWriteToken, "remote-write-token", os.Getenv("REMOTE_WRITE_TOKEN"), "Bearer token of the remote-write endpoint")
+	helper.EnumFlagSet(fs, &mCfg.sinkPoints, "sink-points", []string{sinkPointsCapture, sinkPointsTick, sinkPointsBoth}, "What sinks receive: each captured snapshot, each tick's rate, or both")
+	fs.IntVar(&mCfg.sinkBatch.Size, "sink-batch-size", 500, "Points written to a sink at once")
+	fs.DurationVar(&mCfg.sinkBatch.FlushInterval, "sink-flush-interval", time.Second*10, "Longest a point waits before it is written to a sink")
+	fs.IntVar(&mCfg.sinkBatch.QueueSize, "sink-queue-size", 10000, "Points queued per sink, beyond which new points are dropped")
+	fs.IntVar(&mCfg.sinkBatch.Retries, "sink-retries", 5, "Retries of a failed sink write before its points are dropped")
+	fs.DurationVar(&mCfg.sinkBatch.Backoff, "sink-backoff", time.Second*1, "Wait before retrying a failed sink write, doubled on every retry")
+	fs.DurationVar(&mCfg.sinkBatch.MaxBackoff, "sink-max-backoff", time.Minute*1, "Longest wait between retries of a sink write")
+
+	fs.StringVar(&mCfg.mqtt.Broker, "mqtt-broker", "", "Publish live stats to this MQTT broker, e.g. tcp://localhost:1883 or ssl://broker:8883")
+	fs.StringVar(&mCfg.mqtt.TopicPrefix, "mqtt-topic-prefix", "", "Topic prefix of published stats (default monitoor/<host-id>)")
+	fs.IntVar(&mCfg.mqttQoS, "mqtt-qos", 0, "QoS of published messages (0, 1 or 2)")
+	fs.StringVar(&mCfg.mqtt.Username, "mqtt-username", os.Getenv("MQTT_USERNAME"), "MQTT user name")
+	fs.StringVar(&mCfg.mqtt.Password, "mqtt-password", os.Getenv("MQTT_PASSWORD"), "MQTT password")
+	fs.StringVar(&mCfg.mqtt.CAFile, "mqtt-ca-file", "", "CA certificate the broker is verified with")
+	fs.StringVar(&mCfg.mqtt.CertFile, "mqtt-cert-file", "", "Client certificate presented to the broker")
+	fs.StringVar(&mCfg.mqtt.KeyFile, "mqtt-key-file", "", "Key of the client certificate")
+	fs.BoolVar(&mCfg.mqtt.InsecureSkipVerify, "mqtt-insecure", false, "Skip verifying the broker's certificate")
+	fs.StringVar(&mCfg.mqtt.DiscoveryPrefix, "mqtt-discovery-prefix", "homeassistant", "Home Assistant discovery prefix, empty disables discovery")
+	fs.DurationVar(&mCfg.mqttInterval, "mqtt-interval", time.Second*5, "How often live stats are published")
+
+	fs.StringVar(&mCfg.statsdAddress, "statsd-address", "", "Send per-tick counters and rates to this StatsD/DogStatsD address, e.g. localhost:8125")
+	fs.StringVar(&mCfg.statsdPrefix, "statsd-prefix", "monitoor", "Prefix of StatsD metric names")
+	fs.IntVar(&mCfg.statsdMTU, "statsd-mtu", sink.DefaultStatsDMTU, "Largest StatsD datagram in bytes")
+	fs.IntVar(&mCfg.statsdBatch.QueueSize, "statsd-queue-size", 1000, "Ticks queued for StatsD, beyond which new ones are dropped")
+	fs.DurationVar(&mCfg.statsdBatch.FlushInterval, "statsd-flush-interval", time.Second*1, "Longest a metric waits before it is sent to StatsD")
+
+	return mCfg
+}
+
+// Main runs the monitor, or the command given as first argument: import-pcap,
+// collect-flows, serve or ctl. It exits on failure.
+func Main(mCfg *Config, args []string) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+
+	// The client talks to a running monitor, it needs neither logs nor database.
+	if args[0] == "ctl" {
+		if err := Ctl(mCfg.controlSocket, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	var (
+		err          error
+		snapshots    *model.SnapshotModel
+		periodicStat *m.NetStat
+	)
+
+	// Keep stdout for the ticks alone when they are written as json.
+	consoleOut := os.Stdout
+
+	if mCfg.output == outputJSONL {
+		consoleOut = os.Stderr
+	}
+
+	app, err := bootstrap.New("monitoor", mCfg.base, consoleOut)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	defer app.Close()
+
+	logger := app.Logger
+
+	logger.Info().Msg("config loaded")
+
+	// Sockets systemd listens on for the service, by FileDescriptorName=.
+	listeners, err := systemd.Listeners()
+
+	if err != nil {
+		app.Fatal(err, "failed to use activated sockets")
+	}
+
+	command := args[0]
+
+	if command != "" && command != "import-pcap" && command != "collect-flows" && command != "serve" {
+		app.Fatal(fmt.Errorf("unknown command %q", command), "invalid arguments")
+	}
+
+	if mCfg.allowPersist || command != "" {
+		logger.Info().Msg("persistance allowed")
+
+		if err = app.OpenDatabase(context.Background()); err != nil {
+			app.Fatal(err, "failed to open database")
+		}
+
+		snapshots = app.Snapshots.WithHost(mCfg.hostID)
+
+		if command == "import-pcap" {
+			if err = importPcap(context.Background(), logger, snapshots, args[1:]); err != nil {
+				app.Fatal(err, "failed to import capture")
+			}
+
+			return
+		}
+
+		if command == "collect-flows" {
+			if err = collectFlows(logger, snapshots, mCfg.captureTime, args[1:]); err != nil {
+				app.Fatal(err, "failed to collect flows")
+			}
+
+			return
+		}
+
+		if command == "serve" {
+			if err = serve(logger, snapshots, mCfg.token, activated(listeners, "serve"), args[1:]); err != nil {
+				app.Fatal(err, "failed to serve")
+			}
+
+			return
+		}
+	}
+
+	if mCfg.allowPersist || mCfg.server != "" || mCfg.influxURL != "" || mCfg.remoteWriteURL != "" {
+		periodicStat = &m.NetStat{
+			BytesSent:  0,
+			BytesRecv:  0,
+			BytesTotal: 0,
+		}
+	}
+
+	service := &Service{
+		config:    mCfg,
+		logger:    logger,
+		output:    os.Stdout,
+		snapshots: snapshots,
+
+		monitorTicker: time.NewTicker(mCfg.monitorTime),
+		captureTicker: time.NewTicker(mCfg.captureTime),
+
+		cumulativeStat: &m.NetStat{
+			BytesSent:  0,
+			BytesRecv:  0,
+			BytesTotal: 0,
+		},
+
+		periodicStat: periodicStat,
+
+		startedAt:  time.Now(),
+		captureNow: make(chan chan struct{}),
+	}
+
+	if mCfg.server != "" {
+		service.forwarder = fleet.NewForwarder(mCfg.server, mCfg.hostID, mCfg.token, mCfg.agentBuffer)
+		logger.Info().Str("server", mCfg.server).Str("host", mCfg.hostID).Msg("agent mode enabled")
+	}
+
+	if mCfg.influxURL != "" {
+		service.addSink(sink.NewInflux(mCfg.influxURL, mCfg.influxToken))
+	}
+
+	if mCfg.remoteWriteURL != "" {
+		service.addSink(sink.NewRemoteWrite(mCfg.remoteWriteURL, mCfg.remoteWriteToken))
+	}
+
+	if listener := activated(listeners, "control"); listener != nil {
+		service.controlListener = listener
+		service.control = &http.Server{Handler: service.controlHandler(), ReadHeaderTimeout: 10 * time.Second}
+	} else if mCfg.controlSocket != "" {
+		mode, err := strconv.ParseUint(mCfg.controlMode, 8, 32)
+
+		if err != nil {
+			app.Fatal(err, "invalid control socket mode")
+		}
+
+		service.controlListener, err = listenControl(mCfg.controlSocket, os.FileMode(mode))
+
+		if err != nil {
+			app.Fatal(err, "failed to create control socket")
+		}
+
+		service.controlPath = mCfg.controlSocket
+
+		service.control = &http.Server{Handler: service.controlHandler(), ReadHeaderTimeout: 10 * time.Second}
+	}
+
+	service.liveListener = activated(listeners, "live")
+
+	if mCfg.liveListen != "" || service.liveListener != nil {
+		service.live = dashboard.NewEvents()
+
+		mux := http.NewServeMux()
+		mux.Handle(dashboard.EventsPath, service.live)
+
+		service.liveServer = &http.Server{Addr: mCfg.liveListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	}
+
+	if mCfg.statsdAddress != "" {
+		statsd, err := sink.NewStatsD(mCfg.statsdAddress, mCfg.statsdPrefix, mCfg.statsdMTU)
+
+		if err != nil {
+			app.Fatal(err, "failed to create statsd sink")
+		}
+
+		app.OnShutdown(statsd.Close)
+
+		mCfg.statsdBatch.Size = 100
+		service.statsd = service.newBatcher(statsd, mCfg.statsdBatch)
+	}
+
+	if mCfg.mqtt.Broker != "" {
+		if mCfg.mqttQoS < 0 || mCfg.mqttQoS > 2 {
+			app.Fatal(fmt.Errorf("qos %d must be 0, 1 or 2", mCfg.mqttQoS), "invalid mqtt qos")
+		}
+
+		mCfg.mqtt.Host, mCfg.mqtt.QoS = mCfg.hostID, byte(mCfg.mqttQoS)
+
+		if mCfg.mqtt.Location, err = app.Location(); err != nil {
+			app.Fatal(err, "invalid time zone")
+		}
+
+		service.mqtt, err = sink.NewMQTTPublisher(mCfg.mqtt)
+
+		if err != nil {
+			app.Fatal(err, "failed to create mqtt publisher")
+		}
+
+		if snapshots != nil {
+			today, err := snapshots.WithSource(model.LocalSource).GetStatByDate(context.Background(), time.Now().In(mCfg.mqtt.Location).Format("2006-01-02"))
+
+			if err == nil {
+				service.mqtt.SetToday(today.Stat.Sent, today.Stat.Received)
+			}
+		}
+	}
+
+	if mCfg.maintenanceInterval > 0 {
+		if !mCfg.allowPersist || mCfg.base.Db.Driver != "sqlite3" {
+			app.Fatal(errors.New("maintenance needs --persist with a sqlite3 database"), "invalid maintenance")
+		}
+
+		mCfg.maintenance.Check, mCfg.maintenance.Analyze = true, true
+		service.db = app.DB
+	}
+
+	if mCfg.protocols && mCfg.allowPersist {
+		service.periodicProtocols = &m.ProtoStat{}
+	}
+
+	if mCfg.topProcesses > 0 || mCfg.perUnit {
+		service.processes = m.NewProcessCollector()
+	}
+
+	if mCfg.perUnit {
+		service.cgroups = m.NewCgroupCollector(mCfg.cgroupRoot, service.processes)
+		service.periodicUnits = map[string]*m.NetStat{}
+	}
+
+	if mCfg.perNetns {
+		service.namespaces = m.NewNetnsCollector()
+	}
+
+	if mCfg.topEndpoints > 0 {
+		service.endpoints, err = m.NewEndpointCollector(mCfg.endpointSource)
+
+		if err != nil {
+			app.Fatal(err, "failed to create endpoint collector")
+		}
+
+		service.periodicEndpoints = map[m.Endpoint]m.NetStat{}
+
+		if mCfg.resolve {
+			service.resolver = m.NewResolver(mCfg.resolveTTL)
+		}
+	}
+
+	for _, spec := range mCfg.snmpDevices {
+		device, err := m.ParseSNMPDevice(spec, mCfg.snmpCredentials)
+
+		if err != nil {
+			app.Fatal(err, "invalid snmp device")
+		}
+
+		poller, err := m.NewSNMPPoller(device, mCfg.snmpTimeout)
+
+		if err != nil {
+			app.Fatal(fmt.Errorf("%s: %w", device.Name, err), "failed to create snmp poller")
+		}
+
+		service.devices = append(service.devices, poller)
+	}
+
+	if len(service.devices) > 0 {
+		service.periodicDevices = map[deviceInterface]*m.NetStat{}
+	}
+
+	for name, listener := range listeners {
+		logger.Warn().Str("name", name).Msg("activated socket not used, name it serve, live or control")
+		listener.Close()
+	}
+
+	if err = service.Run(); err != nil {
+		app.Fatal(err, "error occrred while running service")
+	}
+
+	logger.Info().Msg("service stopped successfully")
+}
+
+// Take the socket systemd passed under the name, if any.
+func activated(listeners map[string]net.Listener, name string) net.Listener {
+	listener, ok := listeners[name]
+
+	if !ok {
+		return nil
+	}
+
+	delete(listeners, name)
+
+	return listener
+}
@@ -0,0 +1,103 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/dashboard"
+	"github.com/omarabdelaz1z/go-monitor/internal/fleet"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// ServeOptions are what the server listens on and accepts.
+type ServeOptions struct {
+	Listen     string
+	Token      string
+	MonitorURL string
+}
+
+// ServeFlags registers the server's own flags on the flag set.
+func ServeFlags(fs *flag.FlagSet, opts *ServeOptions) {
+	fs.StringVar(&opts.Listen, "listen", ":8080", "HTTP address agents stream snapshots to, also serving the dashboard and query API")
+	fs.StringVar(&opts.MonitorURL, "monitor-url", "", "URL of a monitor started with --live-listen, for the dashboard's live chart")
+}
+
+// Receive the snapshots agents stream with --server, e.g.
+// monitoor --dsn fleet.db --token secret serve --listen :8080
+func serve(logger zerolog.Logger, snapshots *model.SnapshotModel, token string, listener net.Listener, args []string) error {
+	opts := ServeOptions{Token: token}
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	ServeFlags(fs, &opts)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return Serve(logger, snapshots, opts, listener)
+}
+
+// Serve receives the snapshots of agents and serves the dashboard and query
+// API over them. A socket passed by systemd takes the place of opts.Listen.
+func Serve(logger zerolog.Logger, snapshots *model.SnapshotModel, opts ServeOptions, listener net.Listener) error {
+	listen, token := opts.Listen, opts.Token
+
+	if token == "" {
+		logger.Warn().Msg("no token set, any client may submit snapshots")
+	}
+
+	site, err := dashboard.NewHandler(snapshots.WithHost(""), opts.MonitorURL, logger)
+
+	if err != nil {
+		return fmt.Errorf("failed to create dashboard: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(fleet.SnapshotsPath, fleet.NewHandler(snapshots.WithHost(""), token, logger))
+	mux.Handle("/", site) // the query API included
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Live streams end with the server rather than holding up its shutdown.
+	server.BaseContext = func(net.Listener) context.Context { return ctx }
+
+	go func() {
+		<-ctx.Done()
+
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		server.Shutdown(shutdown)
+	}()
+
+	if listener != nil {
+		logger.Info().Str("listen", listener.Addr().String()).Msg("accepting agent snapshots on activated socket")
+		err = server.Serve(listener)
+	} else {
+		logger.Info().Str("listen", listen).Msg("accepting agent snapshots")
+		err = server.ListenAndServe()
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	logger.Info().Msg("server stopped")
+
+	return nil
+}
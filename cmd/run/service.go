@@ -0,0 +1,1078 @@
+package run
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/run/helper"
+	"github.com/omarabdelaz1z/go-monitor/cmd/run/sink"
+	"github.com/omarabdelaz1z/go-monitor/internal/dashboard"
+	"github.com/omarabdelaz1z/go-monitor/internal/fleet"
+	"github.com/omarabdelaz1z/go-monitor/internal/maintenance"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/omarabdelaz1z/go-monitor/internal/systemd"
+	"github.com/omarabdelaz1z/go-monitor/internal/util"
+	m "github.com/omarabdelaz1z/go-monitor/pkg/monitoor"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+)
+
+// What sinks receive.
+const (
+	sinkPointsCapture = "capture"
+	sinkPointsTick    = "tick"
+	sinkPointsBoth    = "both"
+)
+
+// A deviceInterface identifies an interface of a device polled over SNMP.
+type deviceInterface struct {
+	Device, Interface string
+}
+
+// A Tick is what the monitor observed during one monitor-time interval.
+type Tick struct {
+	At         time.Time
+	Interval   time.Duration // since the previous tick
+	Stat       *m.NetStat
+	Interfaces []m.InterfaceStat // nil unless per-interface counters are collected
+	Protocols  *m.ProtoStat      // nil unless protocol counters are collected
+}
+
+type Service struct {
+	config    *Config
+	snapshots *model.SnapshotModel
+	mu        sync.RWMutex
+
+	logger zerolog.Logger
+	output io.Writer // where jsonl ticks are written
+
+	live         *dashboard.Events
+	liveServer   *http.Server
+	liveListener net.Listener // passed by systemd, if any
+
+	monitorTicker, captureTicker *time.Ticker
+	cumulativeStat, periodicStat *m.NetStat
+
+	processes *m.ProcessCollector
+	cgroups   *m.CgroupCollector
+
+	namespaces *m.NetnsCollector
+
+	endpoints         *m.EndpointCollector
+	resolver          *m.Resolver
+	periodicEndpoints map[m.Endpoint]m.NetStat
+
+	periodicUnits map[string]*m.NetStat
+
+	periodicProtocols *m.ProtoStat
+
+	devices         []*m.SNMPPoller
+	periodicDevices map[deviceInterface]*m.NetStat
+
+	forwarder *fleet.Forwarder
+
+	sinks  []*sink.Batcher
+	statsd *sink.Batcher
+	mqtt   *sink.MQTTPublisher
+
+	startedAt time.Time
+	lastTick  *Tick
+
+	captureNow      chan chan struct{} // requests an immediate capture
+	paused          int32              // whether persistence is paused, accessed atomically
+	control         *http.Server
+	controlListener net.Listener
+	controlPath     string // removed on shutdown, empty when systemd owns the socket
+
+	notify   bool          // whether systemd is notified of the service state
+	watchdog time.Duration // how often systemd expects a sign of life, 0 if never
+
+	db *sql.DB // maintained every maintenanceInterval, if set
+}
+
+func (s *Service) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	g, gCtx := errgroup.WithContext(ctx)
+
+	buffer := make(chan *Tick)
+
+	g.Go(func() error {
+		s.logger.Info().Msg("monitor goroutine launched")
+		return s.Monitor(gCtx, buffer)
+	})
+
+	g.Go(func() error {
+		s.logger.Info().Msg("display goroutine launched")
+		return s.Display(gCtx, buffer)
+	})
+
+	if s.capturing() {
+		g.Go(func() error {
+			s.logger.Info().Msg("capture goroutine launched")
+			return s.Capture(gCtx, buffer)
+		})
+	}
+
+	if s.control != nil {
+		g.Go(func() error {
+			s.logger.Info().Str("socket", s.controlListener.Addr().String()).Msg("control socket launched")
+
+			if err := s.control.Serve(s.controlListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("failed to serve control socket: %w", err)
+			}
+
+			return nil
+		})
+
+		g.Go(func() error {
+			<-gCtx.Done()
+
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			s.control.Shutdown(shutdown)
+
+			if s.controlPath != "" {
+				os.Remove(s.controlPath)
+			}
+
+			s.logger.Info().Msg("control socket stopped")
+
+			return nil
+		})
+	}
+
+	if s.liveServer != nil {
+		// Streams end with the service rather than holding up its shutdown.
+		s.liveServer.BaseContext = func(net.Listener) context.Context { return gCtx }
+
+		g.Go(func() error {
+			var err error
+
+			if s.liveListener != nil {
+				s.logger.Info().Str("address", s.liveListener.Addr().String()).Msg("live events server launched on activated socket")
+				err = s.liveServer.Serve(s.liveListener)
+			} else {
+				s.logger.Info().Str("address", s.liveServer.Addr).Msg("live events server launched")
+				err = s.liveServer.ListenAndServe()
+			}
+
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("failed to serve live events: %w", err)
+			}
+
+			return nil
+		})
+
+		g.Go(func() error {
+			<-gCtx.Done()
+
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			s.liveServer.Shutdown(shutdown)
+			s.logger.Info().Msg("live events server stopped")
+
+			return nil
+		})
+	}
+
+	if s.forwarder != nil {
+		g.Go(func() error {
+			s.logger.Info().Msg("forwarder goroutine launched")
+			s.forwarder.Run(gCtx, s.config.monitorTime*10, func(err error) {
+				pending, dropped := s.forwarder.Stats()
+				s.logger.Warn().Err(err).Int("pending", pending).Uint64("dropped", dropped).Msg("failed to forward snapshots")
+			})
+			s.logger.Info().Msg("forwarder stopped")
+			return nil
+		})
+	}
+
+	if s.processes != nil {
+		g.Go(func() error {
+			s.logger.Info().Msg("processes goroutine launched")
+			return s.Processes(gCtx)
+		})
+	}
+
+	if s.namespaces != nil {
+		g.Go(func() error {
+			s.logger.Info().Msg("namespaces goroutine launched")
+			return s.Namespaces(gCtx)
+		})
+	}
+
+	if s.endpoints != nil {
+		g.Go(func() error {
+			s.logger.Info().Msg("endpoints goroutine launched")
+			return s.Endpoints(gCtx)
+		})
+	}
+
+	for _, poller := range s.devices {
+		poller := poller
+
+		g.Go(func() error {
+			s.logger.Info().Str("device", poller.Device().Name).Msg("device goroutine launched")
+			return s.Device(gCtx, poller)
+		})
+	}
+
+	batchers := s.sinks
+
+	if s.statsd != nil {
+		batchers = append(batchers[:len(batchers):len(batchers)], s.statsd)
+	}
+
+	for _, batcher := range batchers {
+		batcher := batcher
+
+		g.Go(func() error {
+			s.logger.Info().Str("sink", batcher.Name()).Msg("sink goroutine launched")
+			batcher.Run(gCtx)
+			s.logger.Info().Str("sink", batcher.Name()).Uint64("dropped", batcher.Dropped()).Msg("sink stopped")
+			return nil
+		})
+	}
+
+	if s.mqtt != nil {
+		g.Go(func() error {
+			s.logger.Info().Msg("mqtt goroutine launched")
+			s.mqtt.Run(gCtx, s.config.mqttInterval, func(err error) {
+				s.logger.Warn().Err(err).Msg("failed to publish to mqtt")
+			})
+			s.logger.Info().Msg("mqtt stopped")
+			return nil
+		})
+	}
+
+	if s.db != nil {
+		g.Go(func() error {
+			s.logger.Info().Dur("interval", s.config.maintenanceInterval).Msg("maintenance goroutine launched")
+			return s.Maintain(gCtx)
+		})
+	}
+
+	s.notifyReady(gCtx, g)
+
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("service failed while running: %w", err)
+	}
+
+	return nil
+}
+
+// Tell systemd the service is up once its goroutines are launched, and that
+// it is stopping once they are asked to stop.
+func (s *Service) notifyReady(ctx context.Context, g *errgroup.Group) {
+	watchdog, err := systemd.WatchdogInterval()
+
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("watchdog disabled")
+	}
+
+	notified, err := systemd.Notify(systemd.Ready, systemd.Status("monitoring every %s", s.config.monitorTime))
+
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to notify systemd")
+		return
+	}
+
+	if !notified {
+		return
+	}
+
+	s.mu.Lock()
+	s.notify, s.watchdog = true, watchdog
+	s.mu.Unlock()
+
+	s.logger.Info().Dur("watchdog", watchdog).Msg("systemd notified")
+
+	// Each tick pings the watchdog, a tick slower than half its interval
+	// would get the service restarted while healthy.
+	if watchdog > 0 && s.config.monitorTime > watchdog/2 {
+		s.logger.Warn().Dur("watchdog", watchdog).Dur("monitor_time", s.config.monitorTime).Msg("monitor time is too long for the watchdog, raise WatchdogSec=")
+	}
+
+	g.Go(func() error {
+		<-ctx.Done()
+		systemd.Notify(systemd.Stopping)
+		return nil
+	})
+}
+
+// Tell systemd the monitor is alive and how fast the host is talking.
+func (s *Service) notifyTick(tick *Tick) {
+	s.mu.RLock()
+	notify, watchdog := s.notify, s.watchdog
+	s.mu.RUnlock()
+
+	if !notify {
+		return
+	}
+
+	states := []string{}
+
+	if watchdog > 0 {
+		states = append(states, systemd.Watchdog)
+	}
+
+	if seconds := tick.Interval.Seconds(); seconds > 0 {
+		states = append(states, systemd.Status("%s/s up, %s/s down",
+			util.ByteCountSI(uint64(float64(tick.Stat.BytesSent)/seconds)),
+			util.ByteCountSI(uint64(float64(tick.Stat.BytesRecv)/seconds))))
+	}
+
+	if len(states) == 0 {
+		return
+	}
+
+	if _, err := systemd.Notify(states...); err != nil {
+		s.logger.Warn().Err(err).Msg("failed to notify systemd")
+	}
+}
+
+func (s *Service) Monitor(ctx context.Context, buffer chan<- *Tick) error {
+	var currentStat *m.NetStat
+	var currentProtocols *m.ProtoStat
+	var currentInterfaces map[string]m.NetStat
+	var currentAt time.Time
+	var err error
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("monitor stopped")
+			s.monitorTicker.Stop()
+			close(buffer)
+			return nil
+		case <-s.monitorTicker.C:
+			if nil == currentStat {
+				currentStat, err = m.Brief()
+
+				if err != nil {
+					s.logger.Warn().Caller().Err(err).Msg("failed to get current stat")
+					continue // retry again
+				}
+
+				currentAt = time.Now()
+			}
+			var newStat *m.NetStat
+
+			newStat, err = m.Brief()
+
+			if err != nil {
+				s.logger.Warn().Err(err).Msg("failed to get new netstat")
+				continue // retry again
+			}
+
+			now := time.Now()
+			delta := helper.Delta(newStat, currentStat)
+			tick := &Tick{At: now, Interval: now.Sub(currentAt), Stat: delta}
+
+			if s.config.output == outputJSONL {
+				newInterfaces, err := m.Interfaces()
+
+				if err != nil {
+					s.logger.Warn().Err(err).Msg("failed to get interface stats")
+				} else {
+					if currentInterfaces != nil {
+						tick.Interfaces = helper.InterfaceDelta(newInterfaces, currentInterfaces)
+					}
+
+					currentInterfaces = newInterfaces
+				}
+			}
+
+			if s.config.protocols {
+				newProtocols, err := m.Protocols()
+
+				if err != nil {
+					s.logger.Warn().Err(err).Msg("failed to get protocol stat")
+				} else if currentProtocols != nil {
+					tick.Protocols = helper.ProtoDelta(newProtocols, currentProtocols)
+				}
+
+				if newProtocols != nil {
+					currentProtocols = newProtocols
+				}
+			}
+
+			s.emitRate(now, model.LocalSource, "", delta)
+
+			if s.mqtt != nil {
+				s.mqtt.Add(delta.BytesSent, delta.BytesRecv)
+			}
+
+			buffer <- tick
+
+			s.notifyTick(tick)
+
+			s.mu.Lock()
+			if s.periodicStat != nil {
+				helper.UpdateWith(s.periodicStat, helper.Incr(s.periodicStat, delta))
+
+				if tick.Protocols != nil && s.periodicProtocols != nil {
+					*s.periodicProtocols = helper.ProtoIncr(s.periodicProtocols, tick.Protocols)
+				}
+			}
+
+			helper.UpdateWith(s.cumulativeStat, helper.Incr(s.cumulativeStat, delta))
+			s.lastTick = tick
+			s.mu.Unlock()
+
+			helper.UpdateWith(currentStat, *newStat)
+			currentAt = now
+		}
+	}
+}
+
+func (s *Service) Display(ctx context.Context, buffer <-chan *Tick) error {
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("display stopped")
+			s.captureTicker.Stop()
+			return nil
+		case tick, ok := <-buffer:
+			if !ok {
+				s.logger.Error().Caller().Msg("buffer channel is closed")
+				return fmt.Errorf("buffer channel is closed")
+			}
+
+			s.mu.RLock()
+			cumulativeBytes := s.cumulativeStat.BytesTotal
+			s.mu.RUnlock()
+
+			if s.live != nil && s.live.Clients() > 0 {
+				if message, err := json.Marshal(newTickRecord(s.config.hostID, tick, cumulativeBytes)); err == nil {
+					s.live.Publish(message)
+				}
+			}
+
+			if s.config.output == outputJSONL {
+				if err := writeTick(s.output, s.config.hostID, tick, cumulativeBytes); err != nil {
+					return fmt.Errorf("failed to write tick: %w", err)
+				}
+
+				continue
+			}
+
+			cumulative := util.ByteCountSI(cumulativeBytes)
+			stat := tick.Stat
+
+			event := s.logger.Info().
+				Str("service", "display").
+				Str("sent", util.ByteCountSI(stat.BytesSent)).
+				Str("received", util.ByteCountSI(stat.BytesRecv)).
+				Str("total", util.ByteCountSI(stat.BytesTotal)).
+				Str("cumulative", cumulative)
+
+			if p := tick.Protocols; p != nil {
+				event = event.
+					Uint64("tcp_in", p.TCP.InSegs).
+					Uint64("tcp_out", p.TCP.OutSegs).
+					Uint64("tcp_retrans", p.TCP.RetransSegs).
+					Uint64("tcp_resets", p.TCP.EstabResets+p.TCP.OutRsts).
+					Uint64("udp_in", p.UDP.InDatagrams).
+					Uint64("udp_out", p.UDP.OutDatagrams).
+					Uint64("udp_rcvbuf_errors", p.UDP.RcvbufErrors).
+					Uint64("icmp_in", p.ICMP.InMsgs).
+					Uint64("icmp_out", p.ICMP.OutMsgs)
+			}
+
+			event.Send()
+		}
+	}
+}
+
+func (s *Service) Capture(ctx context.Context, buffer <-chan *Tick) error {
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("capture stopped")
+			s.captureTicker.Stop()
+			return nil
+		case <-s.captureTicker.C:
+			s.capture(ctx)
+		case done := <-s.captureNow:
+			s.capture(ctx)
+			close(done)
+		}
+	}
+}
+
+// Capture a snapshot of the usage since the previous one, persisting it unless
+// persistence is paused.
+func (s *Service) capture(ctx context.Context) {
+	snapshots := s.snapshots
+
+	if s.persistencePaused() {
+		snapshots = nil
+	}
+
+	s.mu.RLock()
+
+	snap := &model.Snapshot{
+		Timestamp: time.Now().Unix(),
+		Stat: model.Stat{
+			Sent:     s.periodicStat.BytesSent,
+			Received: s.periodicStat.BytesRecv,
+			Total:    s.periodicStat.BytesTotal,
+		},
+	}
+
+	s.logger.Debug().Fields(map[string]string{
+		"sent":      fmt.Sprint(snap.Stat.Sent),
+		"recv":      fmt.Sprint(snap.Stat.Received),
+		"total":     fmt.Sprint(snap.Stat.Total),
+		"timestamp": fmt.Sprint(snap.Timestamp),
+	}).Msg("persisting snapshot")
+
+	// The usage is only handed off once stored, otherwise it is carried over
+	// to the next snapshot rather than forwarded twice.
+	if snapshots != nil {
+		if err := snapshots.Insert(ctx, snap); err != nil {
+			s.logger.Error().Caller().Err(err).Msg("failed to persist snapshot, its usage is carried over to the next one")
+
+			s.mu.RUnlock()
+			return
+		}
+	}
+
+	if s.forwarder != nil {
+		s.forwarder.Enqueue(*snap)
+	}
+
+	s.emitCapture(snap)
+
+	if snapshots == nil {
+		s.mu.RUnlock()
+		s.resetPeriodic()
+		s.forwardDevices(ctx, snapshots, snap.Timestamp)
+
+		return
+	}
+
+	units := make([]model.UnitStat, 0, len(s.periodicUnits))
+
+	for unit, stat := range s.periodicUnits {
+		units = append(units, model.UnitStat{
+			Unit: unit,
+			Stat: model.Stat{Sent: stat.BytesSent, Received: stat.BytesRecv, Total: stat.BytesTotal},
+		})
+	}
+
+	if len(units) > 0 {
+		if err := snapshots.InsertUnits(ctx, snap.Timestamp, units); err != nil {
+			s.logger.Error().Caller().Err(err).Msg("failed to persist unit stats")
+		}
+	}
+
+	if s.periodicProtocols != nil {
+		p := s.periodicProtocols
+
+		err := snapshots.InsertProtocols(ctx, snap.Timestamp, model.ProtocolStat{
+			TCP: model.TCPStat{
+				InSegs:      p.TCP.InSegs,
+				OutSegs:     p.TCP.OutSegs,
+				RetransSegs: p.TCP.RetransSegs,
+				SynRetrans:  p.TCP.SynRetrans,
+				Timeouts:    p.TCP.Timeouts,
+				EstabResets: p.TCP.EstabResets,
+				OutRsts:     p.TCP.OutRsts,
+				InErrs:      p.TCP.InErrs,
+			},
+			UDP: model.UDPStat{
+				InDatagrams:  p.UDP.InDatagrams,
+				OutDatagrams: p.UDP.OutDatagrams,
+				NoPorts:      p.UDP.NoPorts,
+				InErrors:     p.UDP.InErrors,
+				RcvbufErrors: p.UDP.RcvbufErrors,
+				SndbufErrors: p.UDP.SndbufErrors,
+			},
+			ICMP: model.ICMPStat{
+				InMsgs:   p.ICMP.InMsgs,
+				OutMsgs:  p.ICMP.OutMsgs,
+				InErrors: p.ICMP.InErrors,
+			},
+		})
+
+		if err != nil {
+			s.logger.Error().Caller().Err(err).Msg("failed to persist protocol stats")
+		}
+	}
+
+	top := m.TopEndpoints(s.periodicEndpoints, s.config.topEndpoints)
+
+	s.mu.RUnlock()
+
+	s.resetPeriodic()
+	s.forwardDevices(ctx, snapshots, snap.Timestamp)
+
+	if len(top) > 0 {
+		s.persistEndpoints(ctx, snapshots, snap.Timestamp, top)
+	}
+}
+
+func (s *Service) persistencePaused() bool {
+	return atomic.LoadInt32(&s.paused) == 1
+}
+
+// Pause or resume persisting snapshots, reporting whether it changed.
+func (s *Service) pausePersistence(pause bool) bool {
+	var value int32
+
+	if pause {
+		value = 1
+	}
+
+	return atomic.SwapInt32(&s.paused, value) != value
+}
+
+// Whether snapshots are captured, to the database, a server or sinks.
+func (s *Service) capturing() bool {
+	return s.config.allowPersist || s.forwarder != nil || len(s.sinks) > 0
+}
+
+func (s *Service) addSink(sk sink.Sink) {
+	s.sinks = append(s.sinks, s.newBatcher(sk, s.config.sinkBatch))
+}
+
+func (s *Service) newBatcher(sk sink.Sink, config sink.BatchConfig) *sink.Batcher {
+	batcher := sink.NewBatcher(sk, config)
+
+	batcher.OnError = func(err error, dropped int) {
+		s.logger.Warn().Err(err).Str("sink", sk.Name()).Int("dropped", dropped).Uint64("total_dropped", batcher.Dropped()).Msg("failed to write to sink")
+	}
+
+	batcher.OnDrop = func(dropped, total uint64) {
+		s.logger.Warn().Str("sink", sk.Name()).Uint64("dropped", dropped).Uint64("total_dropped", total).Msg("sink queue full, points dropped")
+	}
+
+	return batcher
+}
+
+// Hand a captured snapshot to the sinks.
+func (s *Service) emitCapture(snap *model.Snapshot) {
+	if len(s.sinks) == 0 || s.config.sinkPoints == sinkPointsTick {
+		return
+	}
+
+	source := snap.Source
+
+	if source == "" {
+		source = model.LocalSource
+	}
+
+	s.emit(sink.Point{
+		Name: "monitoor_capture",
+		Time: time.Unix(snap.Timestamp, 0),
+		Tags: map[string]string{"host": s.config.hostID, "source": source, "interface": snap.Interface},
+		Fields: map[string]float64{
+			"sent_bytes":     float64(snap.Stat.Sent),
+			"received_bytes": float64(snap.Stat.Received),
+			"total_bytes":    float64(snap.Stat.Total),
+		},
+	})
+}
+
+// Hand the rate of a tick's usage to the sinks.
+func (s *Service) emitRate(at time.Time, source, iface string, delta *m.NetStat) {
+	seconds := s.config.monitorTime.Seconds()
+
+	rates := map[string]float64{
+		"sent_bytes_per_second":     float64(delta.BytesSent) / seconds,
+		"received_bytes_per_second": float64(delta.BytesRecv) / seconds,
+		"total_bytes_per_second":    float64(delta.BytesTotal) / seconds,
+	}
+
+	if len(s.sinks) > 0 && s.config.sinkPoints != sinkPointsCapture {
+		s.emit(sink.Point{
+			Name:   "monitoor_rate",
+			Time:   at,
+			Tags:   map[string]string{"host": s.config.hostID, "source": source, "interface": iface},
+			Fields: rates,
+		})
+	}
+
+	if s.statsd == nil {
+		return
+	}
+
+	if iface == "" {
+		iface = "all"
+	}
+
+	s.statsd.Send(sink.Point{
+		Time:   at,
+		Tags:   map[string]string{"host": s.config.hostID, "source": source, "interface": iface},
+		Fields: rates,
+		Counters: map[string]float64{
+			"sent_bytes":     float64(delta.BytesSent),
+			"received_bytes": float64(delta.BytesRecv),
+			"total_bytes":    float64(delta.BytesTotal),
+		},
+	})
+}
+
+func (s *Service) emit(p sink.Point) {
+	for _, batcher := range s.sinks {
+		batcher.Send(p)
+	}
+}
+
+// Start a new capture interval, forgetting the periodic usage but that of
+// devices, which forwardDevices takes.
+func (s *Service) resetPeriodic() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	helper.UpdateWith(s.periodicStat, m.NetStat{})
+
+	if s.periodicProtocols != nil {
+		*s.periodicProtocols = m.ProtoStat{}
+	}
+
+	for unit := range s.periodicUnits {
+		delete(s.periodicUnits, unit)
+	}
+
+	for endpoint := range s.periodicEndpoints {
+		delete(s.periodicEndpoints, endpoint)
+	}
+}
+
+// Persist and forward a snapshot per polled device interface.
+func (s *Service) forwardDevices(ctx context.Context, snapshots *model.SnapshotModel, timestamp int64) {
+	s.mu.Lock()
+	devices := make([]*model.Snapshot, 0, len(s.periodicDevices))
+
+	for key, stat := range s.periodicDevices {
+		devices = append(devices, &model.Snapshot{
+			Timestamp: timestamp,
+			Source:    "snmp:" + key.Device,
+			Interface: key.Interface,
+			Stat:      model.Stat{Sent: stat.BytesSent, Received: stat.BytesRecv, Total: stat.BytesTotal},
+		})
+
+		delete(s.periodicDevices, key)
+	}
+	s.mu.Unlock()
+
+	for _, device := range devices {
+		if s.forwarder != nil {
+			s.forwarder.Enqueue(*device)
+		}
+
+		s.emitCapture(device)
+
+		if snapshots == nil {
+			continue
+		}
+
+		if err := snapshots.Insert(ctx, device); err != nil {
+			s.logger.Error().Caller().Err(err).Str("source", device.Source).Msg("failed to persist device snapshot")
+		}
+	}
+}
+
+// Run the database maintenance every interval. A failed run is logged, the
+// next one trying again.
+func (s *Service) Maintain(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.maintenanceInterval)
+	defer ticker.Stop()
+
+	logger := s.logger.With().Str("service", "maintenance").Logger()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("maintenance stopped")
+			return nil
+		case <-ticker.C:
+			report, err := maintenance.Run(ctx, s.db, s.config.maintenance)
+
+			maintenance.LogReport(logger, report)
+
+			if err != nil && ctx.Err() == nil {
+				logger.Error().Err(err).Msg("database maintenance failed")
+			}
+		}
+	}
+}
+
+func (s *Service) Processes(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.monitorTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("processes stopped")
+			return nil
+		case <-ticker.C:
+			stats, err := s.processes.Collect()
+
+			if err != nil {
+				s.logger.Warn().Err(err).Msg("failed to collect process stats")
+				continue // retry again
+			}
+
+			for i, stat := range m.TopTalkers(stats, s.config.topProcesses) {
+				s.logger.Info().
+					Str("service", "processes").
+					Int("rank", i+1).
+					Int32("pid", stat.PID).
+					Str("name", stat.Name).
+					Str("sent", util.ByteCountSI(stat.BytesSent)).
+					Str("received", util.ByteCountSI(stat.BytesRecv)).
+					Str("total", util.ByteCountSI(stat.BytesTotal)).
+					Send()
+			}
+
+			if s.cgroups != nil {
+				s.accountUnits(stats)
+			}
+		}
+	}
+}
+
+func (s *Service) accountUnits(stats []m.ProcessStat) {
+	groups, err := s.cgroups.Group(stats)
+
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to group process stats by cgroup")
+		return
+	}
+
+	units := map[string]m.NetStat{}
+
+	for _, group := range groups {
+		unit := units[group.Unit]
+		units[group.Unit] = helper.Incr(&unit, &group.NetStat)
+	}
+
+	s.mu.Lock()
+	for unit, stat := range units {
+		if stat.BytesTotal == 0 {
+			continue
+		}
+
+		if _, ok := s.periodicUnits[unit]; !ok {
+			s.periodicUnits[unit] = &m.NetStat{}
+		}
+
+		helper.UpdateWith(s.periodicUnits[unit], helper.Incr(s.periodicUnits[unit], &stat))
+
+		s.logger.Debug().
+			Str("service", "units").
+			Str("unit", unit).
+			Str("sent", util.ByteCountSI(stat.BytesSent)).
+			Str("received", util.ByteCountSI(stat.BytesRecv)).
+			Str("total", util.ByteCountSI(stat.BytesTotal)).
+			Send()
+	}
+	s.mu.Unlock()
+}
+
+func (s *Service) Namespaces(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.monitorTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("namespaces stopped")
+			return nil
+		case <-ticker.C:
+			stats, err := s.namespaces.Collect()
+
+			if err != nil {
+				s.logger.Warn().Err(err).Msg("failed to collect namespace stats")
+				continue // retry again
+			}
+
+			for _, ns := range stats {
+				sum := ns.Sum()
+
+				if sum.BytesTotal == 0 {
+					continue
+				}
+
+				s.logger.Info().
+					Str("service", "namespaces").
+					Uint64("netns", ns.Inode).
+					Str("label", ns.Label()).
+					Int32("pid", ns.PID).
+					Str("sent", util.ByteCountSI(sum.BytesSent)).
+					Str("received", util.ByteCountSI(sum.BytesRecv)).
+					Str("total", util.ByteCountSI(sum.BytesTotal)).
+					Send()
+			}
+		}
+	}
+}
+
+func (s *Service) Endpoints(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.monitorTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("endpoints stopped")
+			return nil
+		case <-ticker.C:
+			stats, err := s.endpoints.Collect()
+
+			if err != nil {
+				s.logger.Warn().Err(err).Msg("failed to collect endpoint stats")
+				continue // retry again
+			}
+
+			if !s.config.allowPersist {
+				for i, stat := range m.TopEndpoints(stats, s.config.topEndpoints) {
+					s.logger.Info().
+						Str("service", "endpoints").
+						Int("rank", i+1).
+						Str("endpoint", stat.Endpoint.String()).
+						Str("sent", util.ByteCountSI(stat.BytesSent)).
+						Str("received", util.ByteCountSI(stat.BytesRecv)).
+						Str("total", util.ByteCountSI(stat.BytesTotal)).
+						Send()
+				}
+
+				continue
+			}
+
+			s.mu.Lock()
+			for endpoint, stat := range stats {
+				periodic := s.periodicEndpoints[endpoint]
+				s.periodicEndpoints[endpoint] = helper.Incr(&periodic, &stat)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Service) Device(ctx context.Context, poller *m.SNMPPoller) error {
+	ticker := time.NewTicker(s.config.monitorTime)
+	defer ticker.Stop()
+
+	device := poller.Device().Name
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Str("device", device).Msg("device stopped")
+			return nil
+		case <-ticker.C:
+			stats, err := poller.Poll()
+
+			if err != nil {
+				s.logger.Warn().Err(err).Str("device", device).Msg("failed to poll device")
+				continue // retry again
+			}
+
+			var sum m.NetStat
+
+			now := time.Now()
+
+			for _, iface := range stats {
+				sum = helper.Incr(&sum, &iface.NetStat)
+				s.emitRate(now, "snmp:"+device, iface.Name, &iface.NetStat)
+
+				s.logger.Debug().
+					Str("service", "devices").
+					Str("device", device).
+					Str("interface", iface.Name).
+					Str("sent", util.ByteCountSI(iface.BytesSent)).
+					Str("received", util.ByteCountSI(iface.BytesRecv)).
+					Send()
+			}
+
+			if len(stats) > 0 {
+				s.logger.Info().
+					Str("service", "devices").
+					Str("device", device).
+					Str("sent", util.ByteCountSI(sum.BytesSent)).
+					Str("received", util.ByteCountSI(sum.BytesRecv)).
+					Str("total", util.ByteCountSI(sum.BytesTotal)).
+					Send()
+			}
+
+			if !s.capturing() {
+				continue
+			}
+
+			s.mu.Lock()
+			for _, iface := range stats {
+				key := deviceInterface{Device: device, Interface: iface.Name}
+
+				if _, ok := s.periodicDevices[key]; !ok {
+					s.periodicDevices[key] = &m.NetStat{}
+				}
+
+				helper.UpdateWith(s.periodicDevices[key], helper.Incr(s.periodicDevices[key], &iface.NetStat))
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Service) persistEndpoints(ctx context.Context, snapshots *model.SnapshotModel, timestamp int64, top []m.EndpointStat) {
+	hostnames := make([]string, len(top))
+
+	if s.resolver != nil {
+		var wg sync.WaitGroup
+
+		// The addresses are looked up at once, each given up after a while, so
+		// that a slow DNS server holds the capture up that long at most.
+		for i, stat := range top {
+			wg.Add(1)
+
+			go func(i int, addr string) {
+				defer wg.Done()
+
+				timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+				defer cancel()
+
+				hostnames[i] = s.resolver.Lookup(timeout, addr)
+			}(i, stat.Address)
+		}
+
+		wg.Wait()
+	}
+
+	endpoints := make([]model.EndpointStat, 0, len(top))
+
+	for i, stat := range top {
+		endpoints = append(endpoints, model.EndpointStat{
+			Protocol: stat.Protocol,
+			Address:  stat.Address,
+			Port:     int(stat.Port),
+			Hostname: hostnames[i],
+			Stat:     model.Stat{Sent: stat.BytesSent, Received: stat.BytesRecv, Total: stat.BytesTotal},
+		})
+	}
+
+	if err := snapshots.InsertEndpoints(ctx, timestamp, endpoints); err != nil {
+		s.logger.Error().Caller().Err(err).Msg("failed to persist endpoint stats")
+	}
+}
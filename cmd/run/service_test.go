@@ -0,0 +1,56 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/fleet"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	m "github.com/omarabdelaz1z/go-monitor/pkg/monitoor"
+	"github.com/rs/zerolog"
+)
+
+func TestCaptureCarriesOverUnstoredUsage(t *testing.T) {
+	ctx := context.Background()
+	snapshots := newTestModel(t)
+
+	s := &Service{
+		config:       &Config{allowPersist: true, hostID: "web-1"},
+		snapshots:    snapshots,
+		logger:       zerolog.Nop(),
+		periodicStat: &m.NetStat{BytesSent: 10, BytesRecv: 20, BytesTotal: 30},
+		forwarder:    fleet.NewForwarder("http://127.0.0.1:0", "web-1", "", 10),
+	}
+
+	// Snapshots stored for the next seconds already, e.g. by a capture forced
+	// over the control socket.
+	now := time.Now().Unix()
+	var taken []model.Snapshot
+
+	for i := int64(0); i < 5; i++ {
+		taken = append(taken, model.Snapshot{Timestamp: now + i})
+	}
+
+	if err := snapshots.InsertSnapshots(ctx, taken); err != nil {
+		t.Fatal(err)
+	}
+
+	s.capture(ctx)
+
+	if pending, _ := s.forwarder.Stats(); pending != 0 || s.periodicStat.BytesTotal != 30 {
+		t.Fatalf("got %d pending and %d bytes, expected the usage to be kept until stored", pending, s.periodicStat.BytesTotal)
+	}
+
+	fresh := newTestModel(t)
+	s.snapshots = fresh
+	s.capture(ctx)
+
+	if pending, _ := s.forwarder.Stats(); pending != 1 || s.periodicStat.BytesTotal != 0 {
+		t.Errorf("got %d pending and %d bytes, expected the usage to be forwarded once", pending, s.periodicStat.BytesTotal)
+	}
+
+	if stat, err := fresh.GetStatByDate(ctx, time.Now().UTC().Format("2006-01-02")); err != nil || stat.Total != 30 {
+		t.Errorf("got %+v, %v, expected the usage to be stored", stat, err)
+	}
+}
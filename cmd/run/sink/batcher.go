@@ -0,0 +1,162 @@
+package sink
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// BatchConfig controls how points are grouped and retried.
+type BatchConfig struct {
+	Size          int           // points per write
+	FlushInterval time.Duration // longest a point waits for its batch to fill
+	QueueSize     int           // points waiting to be batched, beyond which new ones are dropped
+	Retries       int           // attempts after a failed write before its points are dropped
+	Backoff       time.Duration // wait before the first retry, doubled on every retry
+	MaxBackoff    time.Duration
+}
+
+// A Batcher queues points for a sink and writes them in batches from its own
+// goroutine, so producers never block on the network.
+type Batcher struct {
+	sink   Sink
+	config BatchConfig
+	queue  chan Point
+
+	dropped uint64
+
+	// Called with every failed write, and the number of points dropped with it
+	// when retries are exhausted.
+	OnError func(err error, dropped int)
+
+	// Called from time to time when points were dropped because the queue was
+	// full, with how many since the previous call and in total.
+	OnDrop func(dropped, total uint64)
+
+	reported uint64
+}
+
+func NewBatcher(sink Sink, config BatchConfig) *Batcher {
+	if config.Size <= 0 {
+		config.Size = 1
+	}
+
+	if config.QueueSize < config.Size {
+		config.QueueSize = config.Size
+	}
+
+	return &Batcher{sink: sink, config: config, queue: make(chan Point, config.QueueSize)}
+}
+
+func (b *Batcher) Name() string {
+	return b.sink.Name()
+}
+
+// Queue a point without blocking, dropping it when the queue is full.
+func (b *Batcher) Send(p Point) {
+	select {
+	case b.queue <- p:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
+
+// The number of points dropped so far, because the queue was full or the
+// sink kept failing.
+func (b *Batcher) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Write batches until ctx is done, then flush what is left.
+func (b *Batcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Point, 0, b.config.Size)
+
+	flush := func(ctx context.Context) {
+		if len(batch) > 0 {
+			b.write(ctx, batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			for {
+				select {
+				case p := <-b.queue:
+					if batch = append(batch, p); len(batch) >= b.config.Size {
+						flush(final)
+					}
+				default:
+					flush(final)
+					return
+				}
+			}
+		case p := <-b.queue:
+			batch = append(batch, p)
+
+			if len(batch) >= b.config.Size {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+			b.reportDrops()
+		}
+	}
+}
+
+func (b *Batcher) reportDrops() {
+	total := b.Dropped()
+
+	if total == b.reported {
+		return
+	}
+
+	if b.OnDrop != nil {
+		b.OnDrop(total-b.reported, total)
+	}
+
+	b.reported = total
+}
+
+// Write a batch, retrying with exponential backoff.
+func (b *Batcher) write(ctx context.Context, batch []Point) {
+	backoff := b.config.Backoff
+
+	for attempt := 0; ; attempt++ {
+		err := b.sink.Write(ctx, batch)
+
+		if err == nil {
+			return
+		}
+
+		if attempt >= b.config.Retries || ctx.Err() != nil {
+			atomic.AddUint64(&b.dropped, uint64(len(batch)))
+
+			if b.OnError != nil {
+				b.OnError(err, len(batch))
+			}
+
+			return
+		}
+
+		if b.OnError != nil {
+			b.OnError(err, 0)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; b.config.MaxBackoff > 0 && backoff > b.config.MaxBackoff {
+			backoff = b.config.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// Influx writes points in the InfluxDB line protocol, to the write endpoint of
+// either InfluxDB 1.x (/write?db=...) or 2.x (/api/v2/write?org=...&bucket=...).
+type Influx struct {
+	url, token string
+	client     *http.Client
+}
+
+func NewInflux(url, token string) *Influx {
+	return &Influx{url: url, token: token, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (i *Influx) Name() string {
+	return "influx"
+}
+
+func (i *Influx) Write(ctx context.Context, points []Point) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, bytes.NewReader(LineProtocol(points)))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	if i.token != "" {
+		req.Header.Set("Authorization", "Token "+i.token)
+	}
+
+	return do(i.client, req)
+}
+
+// Encode points in the line protocol with nanosecond timestamps.
+func LineProtocol(points []Point) []byte {
+	var buf bytes.Buffer
+
+	for _, p := range points {
+		buf.WriteString(measurementEscaper.Replace(p.Name))
+
+		for _, k := range sortedKeys(p.Tags) {
+			if p.Tags[k] == "" {
+				continue // empty tag values are invalid
+			}
+
+			buf.WriteByte(',')
+			buf.WriteString(tagEscaper.Replace(k))
+			buf.WriteByte('=')
+			buf.WriteString(tagEscaper.Replace(p.Tags[k]))
+		}
+
+		for i, k := range sortedFields(p.Fields) {
+			if i == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+
+			buf.WriteString(tagEscaper.Replace(k))
+			buf.WriteByte('=')
+			buf.WriteString(strconv.FormatFloat(p.Fields[k], 'f', -1, 64))
+		}
+
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(p.Time.UnixNano(), 10))
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// Send a request, failing on any status but 2xx.
+func do(client *http.Client, req *http.Request) error {
+	res, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s responded %s: %s", req.URL.Host, res.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTConfig describes the broker and the topics stats are published on.
+type MQTTConfig struct {
+	Broker   string // e.g. tcp://localhost:1883 or ssl://broker:8883
+	ClientID string
+	Username string
+	Password string
+	QoS      byte
+
+	// TLS, used for ssl:// and wss:// brokers.
+	CAFile, CertFile, KeyFile string
+	InsecureSkipVerify        bool
+
+	Host            string // the monitored host, used in topics and names
+	TopicPrefix     string // defaults to monitoor/<host>
+	DiscoveryPrefix string // Home Assistant discovery prefix, empty disables discovery
+
+	Location *time.Location // the time zone today's totals are reset in, the machine's if nil
+}
+
+// An MQTTState is the retained message published on <prefix>/state.
+type MQTTState struct {
+	SentRate      float64 `json:"sent_rate"`
+	ReceivedRate  float64 `json:"received_rate"`
+	TotalRate     float64 `json:"total_rate"`
+	TodaySent     uint64  `json:"today_sent"`
+	TodayReceived uint64  `json:"today_received"`
+	TodayTotal    uint64  `json:"today_total"`
+}
+
+// An MQTTPublisher publishes the current rates and today's totals. Usage is
+// added from the monitor without blocking; publishing, and reconnecting to the
+// broker, happen on the publisher's own goroutine.
+type MQTTPublisher struct {
+	config MQTTConfig
+	client mqtt.Client
+
+	mu             sync.Mutex
+	sent, received uint64 // since the last publish
+	today          MQTTState
+	day            string
+	since          time.Time
+	now            func() time.Time
+}
+
+func NewMQTTPublisher(config MQTTConfig) (*MQTTPublisher, error) {
+	if config.TopicPrefix == "" {
+		config.TopicPrefix = "monitoor/" + config.Host
+	}
+
+	config.TopicPrefix = strings.TrimSuffix(config.TopicPrefix, "/")
+
+	if config.ClientID == "" {
+		config.ClientID = "monitoor-" + config.Host
+	}
+
+	if config.QoS > 2 {
+		return nil, fmt.Errorf("invalid mqtt qos %d", config.QoS)
+	}
+
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	p := &MQTTPublisher{config: config, now: time.Now}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5*time.Second).
+		SetMaxReconnectInterval(time.Minute).
+		SetWill(p.availabilityTopic(), "offline", config.QoS, true).
+		SetOnConnectHandler(p.announce)
+
+	if config.CAFile != "" || config.CertFile != "" || config.InsecureSkipVerify {
+		tlsConfig, err := config.tls()
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	p.client = mqtt.NewClient(opts)
+
+	return p, nil
+}
+
+func (c MQTTConfig) tls() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mqtt ca: %w", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CAFile)
+		}
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mqtt client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+func (p *MQTTPublisher) date(t time.Time) string {
+	return t.In(p.config.Location).Format("2006-01-02")
+}
+
+func (p *MQTTPublisher) stateTopic() string        { return p.config.TopicPrefix + "/state" }
+func (p *MQTTPublisher) availabilityTopic() string { return p.config.TopicPrefix + "/availability" }
+
+// Start connecting in the background, retrying until the broker is reachable.
+func (p *MQTTPublisher) Connect() {
+	p.mu.Lock()
+	p.since = p.now()
+	p.mu.Unlock()
+
+	p.client.Connect()
+}
+
+// Seed today's totals, e.g. from the database after a restart.
+func (p *MQTTPublisher) SetToday(sent, received uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.day = p.date(p.now())
+	p.today.TodaySent, p.today.TodayReceived, p.today.TodayTotal = sent, received, sent+received
+}
+
+// Account usage observed since the previous call.
+func (p *MQTTPublisher) Add(sent, received uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sent += sent
+	p.received += received
+}
+
+// The state to publish: the rates since the previous one and today's totals.
+func (p *MQTTPublisher) state() MQTTState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+
+	if day := p.date(now); day != p.day {
+		p.day, p.today = day, MQTTState{}
+	}
+
+	if seconds := now.Sub(p.since).Seconds(); seconds > 0 {
+		p.today.SentRate = float64(p.sent) / seconds
+		p.today.ReceivedRate = float64(p.received) / seconds
+		p.today.TotalRate = float64(p.sent+p.received) / seconds
+	}
+
+	p.today.TodaySent += p.sent
+	p.today.TodayReceived += p.received
+	p.today.TodayTotal += p.sent + p.received
+
+	p.sent, p.received, p.since = 0, 0, now
+
+	return p.today
+}
+
+// Publish the state, retained so new subscribers get it immediately. While
+// disconnected the state is skipped, the next one carries the totals anyway.
+func (p *MQTTPublisher) Publish() error {
+	state := p.state()
+
+	if !p.client.IsConnectionOpen() {
+		return nil
+	}
+
+	payload, err := json.Marshal(state)
+
+	if err != nil {
+		return err
+	}
+
+	token := p.client.Publish(p.stateTopic(), p.config.QoS, true, payload)
+
+	if !token.WaitTimeout(10 * time.Second) {
+		return fmt.Errorf("timed out publishing to %s", p.config.Broker)
+	}
+
+	return token.Error()
+}
+
+// Publish availability and discovery messages, on every (re)connect.
+func (p *MQTTPublisher) announce(client mqtt.Client) {
+	client.Publish(p.availabilityTopic(), p.config.QoS, true, "online")
+
+	if p.config.DiscoveryPrefix == "" {
+		return
+	}
+
+	for topic, payload := range p.discovery() {
+		client.Publish(topic, p.config.QoS, true, payload)
+	}
+}
+
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	StateTopic        string          `json:"state_topic"`
+	ValueTemplate     string          `json:"value_template"`
+	UnitOfMeasurement string          `json:"unit_of_measurement"`
+	DeviceClass       string          `json:"device_class"`
+	StateClass        string          `json:"state_class"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Device            discoveryDevice `json:"device"`
+}
+
+// The Home Assistant discovery messages by topic, one sensor per state field.
+func (p *MQTTPublisher) discovery() map[string][]byte {
+	id := "monitoor_" + strings.NewReplacer(".", "_", "-", "_", " ", "_").Replace(p.config.Host)
+
+	device := discoveryDevice{
+		Identifiers:  []string{id},
+		Name:         "Monitoor " + p.config.Host,
+		Manufacturer: "go-monitor",
+		Model:        "monitoor",
+	}
+
+	sensors := []struct {
+		key, name, unit, class, stateClass string
+	}{
+		{"sent_rate", "Upload rate", "B/s", "data_rate", "measurement"},
+		{"received_rate", "Download rate", "B/s", "data_rate", "measurement"},
+		{"total_rate", "Total rate", "B/s", "data_rate", "measurement"},
+		{"today_sent", "Uploaded today", "B", "data_size", "total_increasing"},
+		{"today_received", "Downloaded today", "B", "data_size", "total_increasing"},
+		{"today_total", "Total today", "B", "data_size", "total_increasing"},
+	}
+
+	messages := make(map[string][]byte, len(sensors))
+
+	for _, s := range sensors {
+		payload, _ := json.Marshal(discoveryConfig{
+			Name:              s.name,
+			UniqueID:          id + "_" + s.key,
+			StateTopic:        p.stateTopic(),
+			ValueTemplate:     "{{ value_json." + s.key + " }}",
+			UnitOfMeasurement: s.unit,
+			DeviceClass:       s.class,
+			StateClass:        s.stateClass,
+			AvailabilityTopic: p.availabilityTopic(),
+			Device:            device,
+		})
+
+		messages[fmt.Sprintf("%s/sensor/%s/%s/config", p.config.DiscoveryPrefix, id, s.key)] = payload
+	}
+
+	return messages
+}
+
+// Publish the state every interval until ctx is done.
+func (p *MQTTPublisher) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	p.Connect()
+	defer p.Close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Publish(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Mark the host offline and disconnect.
+func (p *MQTTPublisher) Close() {
+	if p.client.IsConnectionOpen() {
+		p.client.Publish(p.availabilityTopic(), p.config.QoS, true, "offline").WaitTimeout(time.Second)
+	}
+
+	p.client.Disconnect(250)
+}
@@ -0,0 +1,240 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type message struct {
+	topic    string
+	payload  string
+	retained bool
+}
+
+// A fakeBroker is a minimal MQTT 3.1.1 broker recording what clients publish.
+type fakeBroker struct {
+	listener net.Listener
+	messages chan message
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &fakeBroker{listener: listener, messages: make(chan message, 64)}
+
+	t.Cleanup(func() {
+		listener.Close()
+		b.drop()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+
+			go b.serve(conn)
+		}
+	}()
+
+	return b
+}
+
+func (b *fakeBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// Close every client connection, as a broker restart would.
+func (b *fakeBroker) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+
+	b.conns = nil
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+
+	for {
+		header, err := r.ReadByte()
+
+		if err != nil {
+			return
+		}
+
+		var length, shift int
+
+		for {
+			digit, err := r.ReadByte()
+
+			if err != nil {
+				return
+			}
+
+			length |= int(digit&127) << shift
+			shift += 7
+
+			if digit&128 == 0 {
+				break
+			}
+		}
+
+		body := make([]byte, length)
+
+		if _, err = io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			conn.Write([]byte{0x20, 2, 0, 0})
+		case 3: // PUBLISH
+			qos := header >> 1 & 3
+			n := int(binary.BigEndian.Uint16(body))
+			topic, rest := string(body[2:2+n]), body[2+n:]
+
+			if qos > 0 {
+				conn.Write([]byte{0x40, 2, rest[0], rest[1]}) // PUBACK
+				rest = rest[2:]
+			}
+
+			b.messages <- message{topic: topic, payload: string(rest), retained: header&1 == 1}
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0})
+		case 14: // DISCONNECT
+			conn.Close()
+			return
+		}
+	}
+}
+
+// Wait for a message on the topic, skipping others.
+func (b *fakeBroker) await(t *testing.T, topic string) message {
+	t.Helper()
+
+	timeout := time.After(10 * time.Second)
+
+	for {
+		select {
+		case m := <-b.messages:
+			if m.topic == topic {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", topic)
+		}
+	}
+}
+
+func TestMQTTPublisher(t *testing.T) {
+	broker := newFakeBroker(t)
+
+	p, err := NewMQTTPublisher(MQTTConfig{Broker: broker.url(), QoS: 1, Host: "web-1", DiscoveryPrefix: "homeassistant"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := time.Date(2022, 10, 5, 12, 0, 0, 0, time.Local)
+	p.now = func() time.Time { return clock }
+
+	p.Connect()
+	defer p.Close()
+
+	if m := broker.await(t, "monitoor/web-1/availability"); m.payload != "online" || !m.retained {
+		t.Errorf("got %+v, expected a retained online message", m)
+	}
+
+	m := broker.await(t, "homeassistant/sensor/monitoor_web_1/today_total/config")
+
+	var discovery map[string]interface{}
+
+	if err = json.Unmarshal([]byte(m.payload), &discovery); err != nil {
+		t.Fatal(err)
+	}
+
+	if discovery["state_topic"] != "monitoor/web-1/state" || discovery["value_template"] != "{{ value_json.today_total }}" || discovery["device_class"] != "data_size" || !m.retained {
+		t.Errorf("got %+v, expected a retained data_size sensor on the state topic", discovery)
+	}
+
+	p.SetToday(1000, 2000)
+	p.Add(100, 300)
+	clock = clock.Add(2 * time.Second)
+
+	if err = p.Publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	m = broker.await(t, "monitoor/web-1/state")
+
+	var state MQTTState
+
+	if err = json.Unmarshal([]byte(m.payload), &state); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := MQTTState{SentRate: 50, ReceivedRate: 150, TotalRate: 200, TodaySent: 1100, TodayReceived: 2300, TodayTotal: 3400}
+
+	if state != expected || !m.retained {
+		t.Errorf("got %+v, expected retained %+v", state, expected)
+	}
+
+	// The broker goes away; adding usage must not block, and the client
+	// reconnects on its own.
+	broker.drop()
+
+	done := make(chan struct{})
+
+	go func() {
+		for i := 0; i < 1000; i++ {
+			p.Add(1, 1)
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("adding usage blocked while disconnected")
+	}
+
+	broker.await(t, "monitoor/web-1/availability")
+
+	// A new day starts over.
+	clock = clock.Add(24 * time.Hour)
+
+	if err = p.Publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m = broker.await(t, "monitoor/web-1/state"); !strings.Contains(m.payload, `"today_total":2000`) {
+		t.Errorf("got %s, expected today's totals to restart at midnight", m.payload)
+	}
+}
@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWrite pushes points to a Prometheus remote-write endpoint. Every field
+// becomes a series named <point>_<field>, labelled with the point's tags.
+type RemoteWrite struct {
+	url, token string
+	client     *http.Client
+}
+
+func NewRemoteWrite(url, token string) *RemoteWrite {
+	return &RemoteWrite{url: url, token: token, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (r *RemoteWrite) Name() string {
+	return "remote-write"
+}
+
+func (r *RemoteWrite) Write(ctx context.Context, points []Point) error {
+	body := snappy.Encode(nil, WriteRequest(points))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	return do(r.client, req)
+}
+
+type label struct {
+	name, value string
+}
+
+// Encode points as an uncompressed prometheus.WriteRequest message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func WriteRequest(points []Point) []byte {
+	var out []byte
+
+	for _, p := range points {
+		for _, field := range sortedFields(p.Fields) {
+			labels := []label{{"__name__", p.Name + "_" + field}}
+
+			for _, k := range sortedKeys(p.Tags) {
+				if p.Tags[k] != "" {
+					labels = append(labels, label{k, p.Tags[k]})
+				}
+			}
+
+			sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+			var series []byte
+
+			for _, l := range labels {
+				var encoded []byte
+				encoded = protowire.AppendTag(encoded, 1, protowire.BytesType)
+				encoded = protowire.AppendString(encoded, l.name)
+				encoded = protowire.AppendTag(encoded, 2, protowire.BytesType)
+				encoded = protowire.AppendString(encoded, l.value)
+
+				series = protowire.AppendTag(series, 1, protowire.BytesType)
+				series = protowire.AppendBytes(series, encoded)
+			}
+
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(p.Fields[field]))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(p.Time.UnixMilli()))
+
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, sample)
+
+			out = protowire.AppendTag(out, 1, protowire.BytesType)
+			out = protowire.AppendBytes(out, series)
+		}
+	}
+
+	return out
+}
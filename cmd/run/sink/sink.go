@@ -0,0 +1,50 @@
+// Package sink exports the monitored usage to time-series databases.
+package sink
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// A Point is one measurement: a set of fields sharing the same tags and time.
+type Point struct {
+	Name   string
+	Time   time.Time
+	Tags   map[string]string
+	Fields map[string]float64
+
+	// Increments since the previous point, for sinks telling them apart from
+	// gauges. Others ignore them.
+	Counters map[string]float64
+}
+
+// A Sink writes points to a remote system, usually through a Batcher.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, points []Point) error
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sortedFields(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
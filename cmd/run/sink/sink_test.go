@@ -0,0 +1,266 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var testPoints = []Point{
+	{
+		Name:   "monitoor_capture",
+		Time:   time.Unix(1665000000, 0),
+		Tags:   map[string]string{"host": "web 1", "source": "local", "interface": ""},
+		Fields: map[string]float64{"sent_bytes": 1500, "received_bytes": 3000},
+	},
+	{
+		Name:   "monitoor_rate",
+		Time:   time.Unix(1665000001, 500000000),
+		Tags:   map[string]string{"host": "web,2"},
+		Fields: map[string]float64{"total_bytes_per_second": 12.5},
+	},
+}
+
+// A request captured by the stand-in server.
+type captured struct {
+	header http.Header
+	body   []byte
+}
+
+func newStandIn(t *testing.T, status int) (*httptest.Server, <-chan captured) {
+	t.Helper()
+
+	requests := make(chan captured, 8)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- captured{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func TestInflux(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusNoContent)
+
+	if err := NewInflux(server.URL+"/api/v2/write?org=home&bucket=net", "secret").Write(context.Background(), testPoints); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-requests
+
+	expected := "monitoor_capture,host=web\\ 1,source=local received_bytes=3000,sent_bytes=1500 1665000000000000000\n" +
+		"monitoor_rate,host=web\\,2 total_bytes_per_second=12.5 1665000001500000000\n"
+
+	if string(r.body) != expected {
+		t.Errorf("got %q, expected %q", r.body, expected)
+	}
+
+	if auth := r.header.Get("Authorization"); auth != "Token secret" {
+		t.Errorf("got authorization %q, expected the token", auth)
+	}
+}
+
+type sample struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// Decode a WriteRequest, failing the test on malformed input.
+func decodeWriteRequest(t *testing.T, b []byte) []sample {
+	t.Helper()
+
+	fields := func(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+
+			if n < 0 {
+				t.Fatalf("malformed tag: %v", protowire.ParseError(n))
+			}
+
+			b = b[n:]
+
+			if n = fn(num, typ, b); n < 0 {
+				t.Fatalf("malformed field %d: %v", num, protowire.ParseError(n))
+			}
+
+			b = b[n:]
+		}
+	}
+
+	var samples []sample
+
+	fields(b, func(_ protowire.Number, _ protowire.Type, b []byte) int {
+		series, n := protowire.ConsumeBytes(b)
+		s := sample{labels: map[string]string{}}
+
+		fields(series, func(num protowire.Number, _ protowire.Type, b []byte) int {
+			msg, n := protowire.ConsumeBytes(b)
+
+			fields(msg, func(field protowire.Number, _ protowire.Type, b []byte) int {
+				switch {
+				case num == 1:
+					value, n := protowire.ConsumeString(b)
+					b = b[n:]
+
+					_, _, m := protowire.ConsumeTag(b)
+					label, k := protowire.ConsumeString(b[m:])
+					s.labels[value] = label
+
+					return n + m + k
+				case field == 1:
+					bits, n := protowire.ConsumeFixed64(b)
+					s.value = math.Float64frombits(bits)
+					return n
+				default:
+					ts, n := protowire.ConsumeVarint(b)
+					s.timestamp = int64(ts)
+					return n
+				}
+			})
+
+			return n
+		})
+
+		samples = append(samples, s)
+
+		return n
+	})
+
+	return samples
+}
+
+func TestRemoteWrite(t *testing.T) {
+	server, requests := newStandIn(t, http.StatusNoContent)
+
+	if err := NewRemoteWrite(server.URL+"/api/v1/write", "").Write(context.Background(), testPoints); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-requests
+
+	if r.header.Get("Content-Encoding") != "snappy" || r.header.Get("Content-Type") != "application/x-protobuf" || r.header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("got headers %v, expected a snappy compressed protobuf", r.header)
+	}
+
+	body, err := snappy.Decode(nil, r.body)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := decodeWriteRequest(t, body)
+
+	expected := []sample{
+		{map[string]string{"__name__": "monitoor_capture_received_bytes", "host": "web 1", "source": "local"}, 3000, 1665000000000},
+		{map[string]string{"__name__": "monitoor_capture_sent_bytes", "host": "web 1", "source": "local"}, 1500, 1665000000000},
+		{map[string]string{"__name__": "monitoor_rate_total_bytes_per_second", "host": "web,2"}, 12.5, 1665000001500},
+	}
+
+	if len(samples) != len(expected) {
+		t.Fatalf("got %d samples, expected %d", len(samples), len(expected))
+	}
+
+	for i, s := range samples {
+		e := expected[i]
+
+		if s.value != e.value || s.timestamp != e.timestamp || len(s.labels) != len(e.labels) {
+			t.Errorf("sample %d: got %+v, expected %+v", i, s, e)
+			continue
+		}
+
+		for k, v := range e.labels {
+			if s.labels[k] != v {
+				t.Errorf("sample %d: got label %s=%q, expected %q", i, k, s.labels[k], v)
+			}
+		}
+	}
+
+	if err = NewRemoteWrite(newFailingServer(t), "").Write(context.Background(), testPoints); err == nil {
+		t.Error("expected an error when the endpoint rejects the write")
+	}
+}
+
+func newFailingServer(t *testing.T) string {
+	server, _ := newStandIn(t, http.StatusBadRequest)
+	return server.URL
+}
+
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	written  [][]Point
+}
+
+func (f *flakySink) Name() string { return "flaky" }
+
+func (f *flakySink) Write(_ context.Context, points []Point) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("unavailable")
+	}
+
+	f.written = append(f.written, append([]Point(nil), points...))
+
+	return nil
+}
+
+func TestBatcherRetries(t *testing.T) {
+	sink := &flakySink{failures: 2}
+	b := NewBatcher(sink, BatchConfig{Size: 2, FlushInterval: time.Hour, QueueSize: 4, Retries: 3, Backoff: time.Millisecond})
+
+	var errs int
+	b.OnError = func(error, int) { errs++ }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+
+	for _, p := range append(testPoints, testPoints[0]) {
+		b.Send(p)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if len(sink.written) != 2 || len(sink.written[0]) != 2 || len(sink.written[1]) != 1 {
+		t.Errorf("got batches %v, expected a full batch then the rest on shutdown", sink.written)
+	}
+
+	if errs != 2 || b.Dropped() != 0 {
+		t.Errorf("got %d errors and %d dropped, expected two retried failures", errs, b.Dropped())
+	}
+}
+
+func TestBatcherDropsWhenFull(t *testing.T) {
+	b := NewBatcher(&flakySink{}, BatchConfig{Size: 1, QueueSize: 2, FlushInterval: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		b.Send(testPoints[0])
+	}
+
+	if b.Dropped() != 3 {
+		t.Errorf("got %d dropped, expected the points beyond the queue to be dropped", b.Dropped())
+	}
+}
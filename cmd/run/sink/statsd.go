@@ -0,0 +1,116 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// The largest datagram that fits an Ethernet frame without fragmenting, once
+// IP and UDP headers are accounted for on common tunnels.
+const DefaultStatsDMTU = 1432
+
+var statsdEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", ":", "_", "\n", "_")
+
+// StatsD sends fields as gauges and counters as counters over UDP, tagged the
+// DogStatsD way, packing as many metrics per datagram as the MTU allows.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	mtu    int
+}
+
+func NewStatsD(address, prefix string, mtu int) (*StatsD, error) {
+	conn, err := net.Dial("udp", address)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial statsd: %w", err)
+	}
+
+	if mtu <= 0 {
+		mtu = DefaultStatsDMTU
+	}
+
+	return &StatsD{conn: conn, prefix: strings.TrimSuffix(prefix, "."), mtu: mtu}, nil
+}
+
+func (s *StatsD) Name() string {
+	return "statsd"
+}
+
+func (s *StatsD) Write(_ context.Context, points []Point) error {
+	var packet bytes.Buffer
+
+	send := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+
+		_, err := s.conn.Write(packet.Bytes())
+		packet.Reset()
+
+		return err
+	}
+
+	for _, line := range s.lines(points) {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > s.mtu {
+			if err := send(); err != nil {
+				return err
+			}
+		}
+
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+
+		packet.WriteString(line)
+	}
+
+	return send()
+}
+
+// Encode points as metric lines, e.g. monitoor.sent_bytes:1500|c|#host:web-1
+func (s *StatsD) lines(points []Point) []string {
+	var lines []string
+
+	for _, p := range points {
+		var tags []string
+
+		for _, k := range sortedKeys(p.Tags) {
+			if p.Tags[k] != "" {
+				tags = append(tags, statsdEscaper.Replace(k)+":"+statsdEscaper.Replace(p.Tags[k]))
+			}
+		}
+
+		suffix := ""
+
+		if len(tags) > 0 {
+			suffix = "|#" + strings.Join(tags, ",")
+		}
+
+		for _, k := range sortedFields(p.Counters) {
+			lines = append(lines, s.metric(k, p.Counters[k], "c")+suffix)
+		}
+
+		for _, k := range sortedFields(p.Fields) {
+			lines = append(lines, s.metric(k, p.Fields[k], "g")+suffix)
+		}
+	}
+
+	return lines
+}
+
+func (s *StatsD) metric(name string, value float64, kind string) string {
+	if s.prefix != "" {
+		name = s.prefix + "." + name
+	}
+
+	return statsdEscaper.Replace(name) + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	s, err := NewStatsD(conn.LocalAddr().String(), "monitoor", 120)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	point := Point{
+		Tags:     map[string]string{"host": "web-1", "interface": "eth0"},
+		Fields:   map[string]float64{"sent_bytes_per_second": 12.5},
+		Counters: map[string]float64{"sent_bytes": 25, "received_bytes": 50},
+	}
+
+	if err = s.Write(context.Background(), []Point{point}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"monitoor.received_bytes:50|c|#host:web-1,interface:eth0\nmonitoor.sent_bytes:25|c|#host:web-1,interface:eth0",
+		"monitoor.sent_bytes_per_second:12.5|g|#host:web-1,interface:eth0",
+	}
+
+	buf := make([]byte, 1500)
+
+	for _, e := range expected {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		n, _, err := conn.ReadFrom(buf)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := string(buf[:n]); got != e {
+			t.Errorf("got datagram %q, expected %q", got, e)
+		}
+
+		if n > 120 {
+			t.Errorf("got a %d bytes datagram, expected at most the mtu", n)
+		}
+	}
+}
+
+func TestStatsDLines(t *testing.T) {
+	s := &StatsD{prefix: ""}
+
+	lines := s.lines([]Point{{Tags: map[string]string{"source": "snmp:core,1"}, Counters: map[string]float64{"total_bytes": 1}}})
+
+	if len(lines) != 1 || lines[0] != "total_bytes:1|c|#source:snmp_core_1" {
+		t.Errorf("got %q, expected tag values to be sanitized", strings.Join(lines, "\n"))
+	}
+}
+
+func TestBatcherReportsDrops(t *testing.T) {
+	b := NewBatcher(&flakySink{}, BatchConfig{Size: 10, QueueSize: 10, FlushInterval: 10 * time.Millisecond})
+
+	reported := make(chan uint64, 1)
+	b.OnDrop = func(dropped, total uint64) { reported <- dropped }
+
+	for i := 0; i < 15; i++ {
+		b.Send(testPoints[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go b.Run(ctx)
+
+	select {
+	case dropped := <-reported:
+		if dropped != 5 {
+			t.Errorf("got %d dropped, expected 5", dropped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("drops were never reported")
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package stats
 
 import (
 	"fmt"
@@ -49,3 +49,19 @@ func selectPrompt(label string, items ...string) (int, string, error) {
 
 	return index, result, nil
 }
+
+// Prompt for today, given as YYYY-MM-DD, or one of the given months. Exactly
+// one of date and month (MM) is set, unless the prompt was interrupted.
+func selectPeriod(months []string, today string) (date, month, caption string, err error) {
+	index, option, err := selectPrompt("Which period would you like to view?", append([]string{"Today"}, months...)...)
+
+	if err != nil || option == "" {
+		return "", "", "", err
+	}
+
+	if index == 0 {
+		return today, "", today, nil
+	}
+
+	return "", option[:2], option[5:], nil
+}
@@ -0,0 +1,496 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/omarabdelaz1z/go-monitor/internal/util"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+)
+
+const topEndpointsLimit = 10
+
+type Service struct {
+	snapshots *model.SnapshotModel
+	config    *config.Config
+	logger    zerolog.Logger
+
+	monthSafeList []string
+}
+
+func (s *Service) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	g, gCtx := errgroup.WithContext(ctx)
+
+	months, err := s.snapshots.GetMonthsInYear(gCtx, fmt.Sprint(time.Now().In(s.snapshots.Location()).Year()))
+
+	if err != nil {
+		return fmt.Errorf("failed to get months: %w", err)
+	}
+
+	s.monthSafeList = transformMonthName(months...)
+
+	g.Go(func() error {
+		return s.Respond(gCtx)
+	})
+
+	if err = g.Wait(); err != nil {
+		s.logger.Error().Err(err).Caller().Msg("service failed while running")
+		return fmt.Errorf("service failed while running: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) Respond(ctx context.Context) error {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			option, _, err := selectPrompt("What would you like to do?", "View today's stats", "View stats for a month", "View all stats", "View stats by unit", "View top destinations", "View protocol stats", "View stats by subnet", "View stats by host", "Exit")
+
+			if option == -1 && nil == err {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			switch option {
+			case 0:
+				err = s.HandleTodayStats(ctx, t)
+
+				if err != nil {
+					switch err {
+					case model.ErrNoRows:
+						fmt.Println("No stats for today")
+					case model.ErrTimedOut:
+						fmt.Println("Timed out while fetching stats")
+					default:
+						return err
+					}
+				}
+
+				t.Render()
+			case 1:
+				err = s.HandleMonthStats(ctx, t)
+
+				if err != nil {
+					return err
+				}
+
+				t.Render()
+			case 2:
+				err = s.HandleAllStats(ctx, t)
+
+				if err != nil {
+					return err
+				}
+
+				t.Render()
+			case 3:
+				err = s.HandleUnitStats(ctx, t)
+
+				if err != nil {
+					switch err {
+					case model.ErrNoRows:
+						fmt.Println("No unit stats for the selected period")
+					case model.ErrTimedOut:
+						fmt.Println("Timed out while fetching stats")
+					default:
+						return err
+					}
+				}
+
+				t.Render()
+			case 4:
+				err = s.HandleEndpointStats(ctx, t)
+
+				if err != nil {
+					switch err {
+					case model.ErrNoRows:
+						fmt.Println("No destinations for the selected period")
+					case model.ErrTimedOut:
+						fmt.Println("Timed out while fetching stats")
+					default:
+						return err
+					}
+				}
+
+				t.Render()
+			case 5:
+				err = s.HandleProtocolStats(ctx, t)
+
+				if err != nil {
+					switch err {
+					case model.ErrNoRows:
+						fmt.Println("No protocol stats for the selected period")
+					case model.ErrTimedOut:
+						fmt.Println("Timed out while fetching stats")
+					default:
+						return err
+					}
+				}
+
+				t.Render()
+			case 6:
+				err = s.HandleSubnetStats(ctx, t)
+
+				if err != nil {
+					switch err {
+					case model.ErrNoRows:
+						fmt.Println("No subnet stats for the selected period")
+					case model.ErrTimedOut:
+						fmt.Println("Timed out while fetching stats")
+					default:
+						return err
+					}
+				}
+
+				t.Render()
+			case 7:
+				err = s.HandleHostStats(ctx, t)
+
+				if err != nil {
+					switch err {
+					case model.ErrNoRows:
+						fmt.Println("No host stats for the selected period")
+					case model.ErrTimedOut:
+						fmt.Println("Timed out while fetching stats")
+					default:
+						return err
+					}
+				}
+
+				t.Render()
+			case 8:
+				return nil
+			}
+
+			t.ResetHeaders()
+			t.ResetRows()
+			t.ResetFooters()
+		}
+	}
+}
+
+func (s *Service) HandleMonthStats(ctx context.Context, t table.Writer) error {
+	var (
+		err        error
+		option     string
+		dailyStats []model.Snapshot
+	)
+
+	_, option, err = selectPrompt("Which month would you like to view?", s.monthSafeList...)
+
+	if err != nil {
+		return err
+	}
+
+	if nil == err && option == "" {
+		return nil
+	}
+
+	dailyStats, err = s.snapshots.GetStatsByMonth(ctx, option[:2])
+
+	if err != nil {
+		return fmt.Errorf("failed to get stats by month: %w", err)
+	}
+
+	t.SetCaption(fmt.Sprintf("Stats for %s", option[5:]))
+	t.AppendHeader(table.Row{"Date", "Uploaded", "Downloaded", "Total"})
+
+	for i := 0; i < len(dailyStats)-1; i++ {
+		t.AppendRow(table.Row{
+			time.Unix(dailyStats[i].Timestamp, 0).In(s.snapshots.Location()).Format("2006-01-02"),
+			util.ByteCountSI(dailyStats[i].Stat.Sent),
+			util.ByteCountSI(dailyStats[i].Stat.Received),
+			util.ByteCountSI(dailyStats[i].Stat.Total),
+		})
+	}
+
+	t.AppendSeparator()
+	t.AppendFooter(table.Row{
+		"Cumulative",
+		util.ByteCountSI(dailyStats[len(dailyStats)-1].Stat.Sent),
+		util.ByteCountSI(dailyStats[len(dailyStats)-1].Stat.Received),
+		util.ByteCountSI(dailyStats[len(dailyStats)-1].Stat.Total),
+	})
+
+	return nil
+}
+
+func (s *Service) HandleAllStats(ctx context.Context, t table.Writer) error {
+	stats, err := s.snapshots.GetAllStats(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	t.SetCaption("All stats")
+	t.AppendHeader(table.Row{"Month", "Uploaded", "Downloaded", "Total"})
+
+	for _, stat := range stats {
+		t.AppendRow(table.Row{
+			time.Unix(stat.Timestamp, 0).In(s.snapshots.Location()).Format("2006-01-02"),
+			util.ByteCountSI(stat.Stat.Sent),
+			util.ByteCountSI(stat.Stat.Received),
+			util.ByteCountSI(stat.Stat.Total),
+		})
+	}
+
+	return nil
+}
+
+// Today's date in the time zone of the reports.
+func (s *Service) today() string {
+	return time.Now().In(s.snapshots.Location()).Format("2006-01-02")
+}
+
+func (s *Service) HandleTodayStats(ctx context.Context, t table.Writer) error {
+	today := s.today()
+
+	stat, err := s.snapshots.GetStatByDate(ctx, today)
+
+	if err != nil {
+		return err
+	}
+
+	t.SetCaption(fmt.Sprintf("Monitored %d hours on %s", stat.HoursMonitored, today))
+	t.AppendHeader(table.Row{"Uploaded", "Downloaded", "Total"})
+
+	t.AppendRow(table.Row{
+		util.ByteCountSI(stat.Stat.Sent),
+		util.ByteCountSI(stat.Stat.Received),
+		util.ByteCountSI(stat.Stat.Total),
+	})
+
+	return nil
+}
+
+func (s *Service) HandleUnitStats(ctx context.Context, t table.Writer) error {
+	var (
+		err   error
+		stats []model.UnitStat
+	)
+
+	date, month, caption, err := selectPeriod(s.monthSafeList, s.today())
+
+	if err != nil || caption == "" {
+		return err
+	}
+
+	if date != "" {
+		stats, err = s.snapshots.GetUnitStatsByDate(ctx, date)
+	} else {
+		stats, err = s.snapshots.GetUnitStatsByMonth(ctx, month)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	t.SetCaption(fmt.Sprintf("Stats by unit for %s", caption))
+	t.AppendHeader(table.Row{"Unit", "Uploaded", "Downloaded", "Total"})
+
+	for _, stat := range stats {
+		t.AppendRow(table.Row{
+			stat.Unit,
+			util.ByteCountSI(stat.Stat.Sent),
+			util.ByteCountSI(stat.Stat.Received),
+			util.ByteCountSI(stat.Stat.Total),
+		})
+	}
+
+	return nil
+}
+
+func (s *Service) HandleSubnetStats(ctx context.Context, t table.Writer) error {
+	var (
+		err   error
+		stats []model.SubnetStat
+	)
+
+	date, month, caption, err := selectPeriod(s.monthSafeList, s.today())
+
+	if err != nil || caption == "" {
+		return err
+	}
+
+	if date != "" {
+		stats, err = s.snapshots.GetSubnetStatsByDate(ctx, date)
+	} else {
+		stats, err = s.snapshots.GetSubnetStatsByMonth(ctx, month)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	t.SetCaption(fmt.Sprintf("Stats by subnet for %s", caption))
+	t.AppendHeader(table.Row{"Subnet", "Uploaded", "Downloaded", "Total"})
+
+	for _, stat := range stats {
+		t.AppendRow(table.Row{
+			stat.Subnet,
+			util.ByteCountSI(stat.Stat.Sent),
+			util.ByteCountSI(stat.Stat.Received),
+			util.ByteCountSI(stat.Stat.Total),
+		})
+	}
+
+	return nil
+}
+
+func (s *Service) HandleHostStats(ctx context.Context, t table.Writer) error {
+	var (
+		err   error
+		stats []model.HostStat
+	)
+
+	date, month, caption, err := selectPeriod(s.monthSafeList, s.today())
+
+	if err != nil || caption == "" {
+		return err
+	}
+
+	if date != "" {
+		stats, err = s.snapshots.GetHostStatsByDate(ctx, date)
+	} else {
+		stats, err = s.snapshots.GetHostStatsByMonth(ctx, month)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	t.SetCaption(fmt.Sprintf("Stats by host for %s", caption))
+	t.AppendHeader(table.Row{"Host", "Uploaded", "Downloaded", "Total"})
+
+	for _, stat := range stats {
+		host := stat.Host
+
+		if host == "" {
+			host = "(unknown)"
+		}
+
+		t.AppendRow(table.Row{
+			host,
+			util.ByteCountSI(stat.Stat.Sent),
+			util.ByteCountSI(stat.Stat.Received),
+			util.ByteCountSI(stat.Stat.Total),
+		})
+	}
+
+	return nil
+}
+
+func (s *Service) HandleEndpointStats(ctx context.Context, t table.Writer) error {
+	var (
+		err   error
+		stats []model.EndpointStat
+	)
+
+	date, month, caption, err := selectPeriod(s.monthSafeList, s.today())
+
+	if err != nil || caption == "" {
+		return err
+	}
+
+	if date != "" {
+		stats, err = s.snapshots.GetTopEndpointsByDate(ctx, date, topEndpointsLimit)
+	} else {
+		stats, err = s.snapshots.GetTopEndpointsByMonth(ctx, month, topEndpointsLimit)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	t.SetCaption(fmt.Sprintf("Top destinations for %s", caption))
+	t.AppendHeader(table.Row{"Protocol", "Endpoint", "Host", "Uploaded", "Downloaded", "Total"})
+
+	for _, stat := range stats {
+		t.AppendRow(table.Row{
+			stat.Protocol,
+			net.JoinHostPort(stat.Address, fmt.Sprint(stat.Port)),
+			stat.Hostname,
+			util.ByteCountSI(stat.Stat.Sent),
+			util.ByteCountSI(stat.Stat.Received),
+			util.ByteCountSI(stat.Stat.Total),
+		})
+	}
+
+	return nil
+}
+
+func (s *Service) HandleProtocolStats(ctx context.Context, t table.Writer) error {
+	var (
+		err  error
+		stat model.ProtocolStat
+	)
+
+	date, month, caption, err := selectPeriod(s.monthSafeList, s.today())
+
+	if err != nil || caption == "" {
+		return err
+	}
+
+	if date != "" {
+		stat, err = s.snapshots.GetProtocolStatByDate(ctx, date)
+	} else {
+		stat, err = s.snapshots.GetProtocolStatByMonth(ctx, month)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	t.SetCaption(fmt.Sprintf("Protocol stats for %s", caption))
+	t.AppendHeader(table.Row{"Protocol", "Counter", "Packets"})
+
+	t.AppendRows([]table.Row{
+		{"TCP", "Segments in", stat.TCP.InSegs},
+		{"TCP", "Segments out", stat.TCP.OutSegs},
+		{"TCP", "Retransmitted segments", stat.TCP.RetransSegs},
+		{"TCP", "SYN retransmits", stat.TCP.SynRetrans},
+		{"TCP", "Timeouts", stat.TCP.Timeouts},
+		{"TCP", "Established resets", stat.TCP.EstabResets},
+		{"TCP", "Resets sent", stat.TCP.OutRsts},
+		{"TCP", "Errors in", stat.TCP.InErrs},
+	})
+	t.AppendSeparator()
+	t.AppendRows([]table.Row{
+		{"UDP", "Datagrams in", stat.UDP.InDatagrams},
+		{"UDP", "Datagrams out", stat.UDP.OutDatagrams},
+		{"UDP", "No port", stat.UDP.NoPorts},
+		{"UDP", "Errors in", stat.UDP.InErrors},
+		{"UDP", "Receive buffer errors", stat.UDP.RcvbufErrors},
+		{"UDP", "Send buffer errors", stat.UDP.SndbufErrors},
+	})
+	t.AppendSeparator()
+	t.AppendRows([]table.Row{
+		{"ICMP", "Messages in", stat.ICMP.InMsgs},
+		{"ICMP", "Messages out", stat.ICMP.OutMsgs},
+		{"ICMP", "Errors in", stat.ICMP.InErrors},
+	})
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package stats
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/omarabdelaz1z/go-monitor/cmd/bootstrap"
+	"github.com/omarabdelaz1z/go-monitor/cmd/config"
+)
+
+// Config of the statistics, see Flags.
+type Config struct {
+	base *config.Config
+
+	source, host, iface string
+}
+
+// Flags registers the statistics' flags on the flag set, the shared ones
+// included, and returns the configuration they are parsed into.
+func Flags(fs *flag.FlagSet, base *config.Config) *Config {
+	cfg := &Config{base: base}
+
+	bootstrap.Flags(fs, base)
+
+	fs.StringVar(&cfg.source, "source", "", "only report traffic of this source, e.g. local or pcap:capture.pcap (default all)")
+	fs.StringVar(&cfg.host, "host", "", "only report traffic captured by this host (default all)")
+	fs.StringVar(&cfg.iface, "interface", "", "only report this interface of polled devices (default the sums of all interfaces)")
+
+	return cfg
+}
+
+// Main browses the statistics interactively. It exits on failure.
+func Main(cfg *Config) {
+	app, err := bootstrap.New("statistics", cfg.base, os.Stdout)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	defer app.Close()
+
+	if err = app.OpenDatabase(context.Background()); err != nil {
+		app.Fatal(err, "failed to open database")
+	}
+
+	service := &Service{
+		snapshots:     app.Snapshots.WithSource(cfg.source).WithHost(cfg.host).WithInterface(cfg.iface),
+		config:        cfg.base,
+		logger:        app.Logger,
+		monthSafeList: []string{},
+	}
+
+	if err = service.Run(); err != nil {
+		app.Fatal(err, "error occrred while running service")
+	}
+
+	app.Logger.Info().Msg("service stopped successfully")
+}
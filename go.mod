@@ -11,10 +11,11 @@ require (
 
 require (
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
+	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.16 // indirect
 	github.com/mattn/go-runewidth v0.0.13 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
+	golang.org/x/net v0.0.0-20200425230154-ff2c4b7c35a0 // indirect
 )
 
 require (
@@ -25,6 +26,11 @@ require (
 )
 
 require (
+	github.com/eclipse/paho.mqtt.golang v1.4.2
+	github.com/golang/snappy v0.0.4
+	github.com/gosnmp/gosnmp v1.35.0
+	github.com/mattn/go-isatty v0.0.16
 	github.com/shirou/gopsutil/v3 v3.22.7
-	golang.org/x/sys v0.0.0-20220928140112-f11e5e49a4ec // indirect
+	golang.org/x/sys v0.0.0-20220928140112-f11e5e49a4ec
+	google.golang.org/protobuf v1.28.1
 )
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// Prefix is where the API is served.
+const Prefix = "/api/v1"
+
+//go:embed openapi.json
+var openAPI []byte
+
+type Usage struct {
+	Sent     uint64 `json:"sent"`
+	Received uint64 `json:"received"`
+	Total    uint64 `json:"total"`
+}
+
+// A Bucket is the usage of one hour, day or month.
+type Bucket struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Snapshots int       `json:"snapshots"`
+	Usage
+}
+
+// A Report is the answer to every usage query.
+type Report struct {
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Bucket    string     `json:"bucket"`
+	Interface *string    `json:"interface,omitempty"`
+	Buckets   []Bucket   `json:"buckets"`
+	Total     Usage      `json:"total"`
+}
+
+type handler struct {
+	snapshots *model.SnapshotModel
+	logger    zerolog.Logger
+	location  *time.Location
+	now       func() time.Time
+}
+
+// A handler answering usage queries over the model under Prefix. Periods are
+// bucketed in the model's time zone.
+func NewHandler(snapshots *model.SnapshotModel, logger zerolog.Logger) http.Handler {
+	h := &handler{snapshots: snapshots, logger: logger, location: snapshots.Location(), now: time.Now}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(Prefix+"/openapi.json", h.get(func(r *http.Request) ([]byte, bool, error) {
+		return openAPI, true, nil
+	}))
+
+	mux.HandleFunc(Prefix+"/today", h.get(h.report(h.today, model.PeriodHour)))
+	mux.HandleFunc(Prefix+"/date/", h.get(h.report(h.date, model.PeriodHour)))
+	mux.HandleFunc(Prefix+"/month/", h.get(h.report(h.month, model.PeriodDay)))
+	mux.HandleFunc(Prefix+"/range", h.get(h.report(h.between, model.PeriodDay)))
+	mux.HandleFunc(Prefix+"/all", h.get(h.report(h.all, model.PeriodMonth)))
+
+	return mux
+}
+
+var errBadRequest = errors.New("bad request")
+
+func badRequest(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errBadRequest, fmt.Sprintf(format, args...))
+}
+
+// Adapt an endpoint returning a body, and whether it can no longer change,
+// setting the caching headers and answering conditional requests.
+func (h *handler) get(endpoint func(r *http.Request) ([]byte, bool, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, final, err := endpoint(r)
+
+		if err != nil {
+			status := http.StatusInternalServerError
+
+			switch {
+			case errors.Is(err, errBadRequest):
+				status = http.StatusBadRequest
+			case errors.Is(err, model.ErrTimedOut):
+				status = http.StatusServiceUnavailable
+			default:
+				h.logger.Error().Err(err).Str("path", r.URL.Path).Msg("failed to serve api request")
+			}
+
+			http.Error(w, err.Error(), status)
+
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+		w.Header().Set("ETag", etag)
+
+		// Periods still being captured are revalidated on every request. Past
+		// ones change too, e.g. on late agent flushes, imports or merges, so
+		// they are only kept shortly and by the client alone.
+		if final {
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		if match := r.Header.Get("If-None-Match"); match != "" && matchETag(match, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve the [from, to) range a request asks for.
+type rangeFunc func(r *http.Request) (from, to time.Time, err error)
+
+// An endpoint reporting the usage in the range of a request, by the bucket
+// given as query parameter or the endpoint's default.
+func (h *handler) report(resolve rangeFunc, defaultBucket string) func(r *http.Request) ([]byte, bool, error) {
+	return func(r *http.Request) ([]byte, bool, error) {
+		from, to, err := resolve(r)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		query := r.URL.Query()
+
+		bucket := query.Get("bucket")
+
+		if bucket == "" {
+			bucket = defaultBucket
+		}
+
+		layout, ok := model.PeriodLayout(bucket)
+
+		if !ok {
+			return nil, false, badRequest("bucket must be hour, day or month")
+		}
+
+		report := Report{Bucket: bucket, Buckets: []Bucket{}}
+
+		if !from.IsZero() {
+			report.From, report.To = &from, &to
+		}
+
+		var iface *string
+
+		if values, ok := query["interface"]; ok {
+			name := values[0]
+			report.Interface = &values[0]
+
+			// "all" is the sum of the interfaces, stored without name.
+			if name == "all" {
+				name = ""
+			}
+
+			iface = &name
+		}
+
+		start, end := int64(0), int64(math.MaxInt64)
+
+		if !from.IsZero() {
+			start, end = from.Unix(), to.Unix()
+		}
+
+		stats, err := h.snapshots.GetPeriodStats(r.Context(), start, end, bucket, iface)
+
+		if err = h.fill(&report, stats, err, layout); err != nil {
+			return nil, false, err
+		}
+
+		body, err := json.Marshal(report)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		return body, !to.IsZero() && to.Before(h.now()), nil
+	}
+}
+
+func (h *handler) fill(report *Report, stats []model.PeriodStat, err error, layout string) error {
+	if errors.Is(err, model.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	for _, s := range stats {
+		start, err := time.ParseInLocation(layout, s.Period, h.location)
+
+		if err != nil {
+			return fmt.Errorf("failed to parse period %q: %w", s.Period, err)
+		}
+
+		report.Buckets = append(report.Buckets, Bucket{
+			Start:     start,
+			End:       periodEnd(start, report.Bucket),
+			Snapshots: s.Snapshots,
+			Usage:     Usage{Sent: s.Sent, Received: s.Received, Total: s.Total},
+		})
+
+		report.Total.Sent += s.Sent
+		report.Total.Received += s.Received
+		report.Total.Total += s.Total
+	}
+
+	return nil
+}
+
+func periodEnd(start time.Time, period string) time.Time {
+	switch period {
+	case model.PeriodHour:
+		return start.Add(time.Hour)
+	case model.PeriodDay:
+		return start.AddDate(0, 0, 1)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+func (h *handler) today(r *http.Request) (time.Time, time.Time, error) {
+	now := h.now().In(h.location)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, h.location)
+
+	return start, start.AddDate(0, 0, 1), nil
+}
+
+func (h *handler) date(r *http.Request) (time.Time, time.Time, error) {
+	value := strings.TrimPrefix(r.URL.Path, Prefix+"/date/")
+	start, err := time.ParseInLocation("2006-01-02", value, h.location)
+
+	if err != nil {
+		return start, start, badRequest("date must be YYYY-MM-DD")
+	}
+
+	return start, start.AddDate(0, 0, 1), nil
+}
+
+func (h *handler) month(r *http.Request) (time.Time, time.Time, error) {
+	value := strings.TrimPrefix(r.URL.Path, Prefix+"/month/")
+	start, err := time.ParseInLocation("2006-01", value, h.location)
+
+	if err != nil {
+		return start, start, badRequest("month must be YYYY-MM")
+	}
+
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+func (h *handler) between(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+
+	if err != nil {
+		return from, from, badRequest("from must be an RFC 3339 time")
+	}
+
+	// Up to now, including the current second.
+	to := h.now().Truncate(time.Second).Add(time.Second)
+
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			return from, to, badRequest("to must be an RFC 3339 time")
+		}
+	}
+
+	if !to.After(from) {
+		return from, to, badRequest("to must be after from")
+	}
+
+	return from.In(h.location), to.In(h.location), nil
+}
+
+// Everything, the zero times stand for an open range.
+func (h *handler) all(r *http.Request) (time.Time, time.Time, error) {
+	return time.Time{}, time.Time{}, nil
+}
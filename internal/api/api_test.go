@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *model.SnapshotModel) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = model.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots := model.NewSnapshotModel(db)
+	server := httptest.NewServer(NewHandler(snapshots, zerolog.Nop()))
+
+	t.Cleanup(server.Close)
+
+	return server, snapshots
+}
+
+func get(t *testing.T, url string, header http.Header) (*http.Response, Report) {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	var report Report
+
+	if response.StatusCode == http.StatusOK {
+		if err = json.NewDecoder(response.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return response, report
+}
+
+func TestReports(t *testing.T) {
+	server, snapshots := newTestServer(t)
+	ctx := context.Background()
+
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.Local)
+
+	for _, s := range []model.Snapshot{
+		{Timestamp: day.Add(time.Hour).Unix(), Stat: model.Stat{Sent: 1, Received: 2, Total: 3}},
+		{Timestamp: day.Add(time.Hour + 30*time.Minute).Unix(), Stat: model.Stat{Sent: 1, Received: 2, Total: 3}},
+		{Timestamp: day.Add(5 * time.Hour).Unix(), Source: "snmp:core", Interface: "Gi0/1", Stat: model.Stat{Sent: 100, Received: 100, Total: 200}},
+		{Timestamp: day.AddDate(0, 0, 3).Unix(), Stat: model.Stat{Sent: 10, Received: 10, Total: 20}},
+		{Timestamp: day.AddDate(0, 1, 0).Unix(), Stat: model.Stat{Sent: 7, Received: 7, Total: 14}},
+	} {
+		s := s
+
+		if err := snapshots.Insert(ctx, &s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	response, report := get(t, server.URL+"/api/v1/date/2022-08-01", nil)
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("got %d, expected the day's report", response.StatusCode)
+	}
+
+	if report.Bucket != "hour" || len(report.Buckets) != 1 || report.Total.Total != 6 || !report.From.Equal(day) || !report.To.Equal(day.AddDate(0, 0, 1)) {
+		t.Fatalf("got %+v, expected an hour of 6 bytes, without the polled interface", report)
+	}
+
+	if b := report.Buckets[0]; !b.Start.Equal(day.Add(time.Hour)) || !b.End.Equal(day.Add(2*time.Hour)) || b.Snapshots != 2 || b.Total != 6 {
+		t.Errorf("got %+v, expected 6 bytes in 2 snapshots from 01:00 to 02:00", b)
+	}
+
+	_, report = get(t, server.URL+"/api/v1/month/2022-08?interface=all", nil)
+
+	if report.Bucket != "day" || len(report.Buckets) != 2 || report.Total.Total != 26 || report.Interface == nil || *report.Interface != "all" {
+		t.Errorf("got %+v, expected 2 days summing up to 26 bytes without the polled interface", report)
+	}
+
+	_, report = get(t, server.URL+"/api/v1/month/2022-08?interface=Gi0/1&bucket=month", nil)
+
+	if len(report.Buckets) != 1 || report.Total.Total != 200 || !report.Buckets[0].End.Equal(day.AddDate(0, 1, 0)) {
+		t.Errorf("got %+v, expected a single month with Gi0/1 only", report)
+	}
+
+	from, to := day.Add(2*time.Hour).Format(time.RFC3339), day.AddDate(0, 0, 4).Format(time.RFC3339)
+	_, report = get(t, server.URL+"/api/v1/range?from="+from+"&to="+to, nil)
+
+	if len(report.Buckets) != 1 || report.Total.Total != 20 {
+		t.Errorf("got %+v, expected 20 bytes between %s and %s", report, from, to)
+	}
+
+	_, report = get(t, server.URL+"/api/v1/all", nil)
+
+	if report.From != nil || len(report.Buckets) != 2 || report.Buckets[1].Total != 14 || report.Total.Total != 40 {
+		t.Errorf("got %+v, expected August and September", report)
+	}
+
+	_, report = get(t, server.URL+"/api/v1/date/2021-01-01", nil)
+
+	if report.Buckets == nil || len(report.Buckets) != 0 {
+		t.Errorf("got %+v, expected an empty report", report)
+	}
+
+	for _, path := range []string{
+		"/api/v1/date/2022-13-01",
+		"/api/v1/month/2022",
+		"/api/v1/today?bucket=week",
+		"/api/v1/range",
+		"/api/v1/range?from=" + to + "&to=" + from,
+	} {
+		if response, _ := get(t, server.URL+path, nil); response.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: got %d, expected %d", path, response.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestCaching(t *testing.T) {
+	server, snapshots := newTestServer(t)
+
+	response, _ := get(t, server.URL+"/api/v1/month/2022-08", nil)
+	etag := response.Header.Get("ETag")
+
+	if etag == "" || response.Header.Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("got %q and %q, expected a past month to be cached shortly", etag, response.Header.Get("Cache-Control"))
+	}
+
+	if response, _ = get(t, server.URL+"/api/v1/month/2022-08", http.Header{"If-None-Match": {`"other", ` + etag}}); response.StatusCode != http.StatusNotModified {
+		t.Errorf("got %d, expected the matching etag to yield %d", response.StatusCode, http.StatusNotModified)
+	}
+
+	// A past month changes when snapshots arrive late.
+	late := time.Date(2022, 8, 20, 0, 0, 0, 0, time.Local).Unix()
+
+	if err := snapshots.Insert(context.Background(), &model.Snapshot{Timestamp: late, Stat: model.Stat{Sent: 1, Received: 1, Total: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if response, _ = get(t, server.URL+"/api/v1/month/2022-08", http.Header{"If-None-Match": {etag}}); response.StatusCode != http.StatusOK {
+		t.Errorf("got %d, expected a late snapshot to change the etag", response.StatusCode)
+	}
+
+	response, _ = get(t, server.URL+"/api/v1/today", nil)
+	etag = response.Header.Get("ETag")
+
+	if response.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("got %q, expected today to be revalidated", response.Header.Get("Cache-Control"))
+	}
+
+	if err := snapshots.Insert(context.Background(), &model.Snapshot{Timestamp: time.Now().Unix(), Stat: model.Stat{Sent: 1, Received: 1, Total: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, report := get(t, server.URL+"/api/v1/today", http.Header{"If-None-Match": {etag}})
+
+	if response.StatusCode != http.StatusOK || report.Total.Total != 2 {
+		t.Errorf("got %d %+v, expected a new snapshot to change the etag", response.StatusCode, report)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	response, err := http.Get(server.URL + "/api/v1/openapi.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	var description struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+
+	if err = json.NewDecoder(response.Body).Decode(&description); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/today", "/date/{date}", "/month/{month}", "/range", "/all"} {
+		if _, ok := description.Paths[path]; !ok {
+			t.Errorf("%s is not described", path)
+		}
+	}
+}
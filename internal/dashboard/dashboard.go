@@ -0,0 +1,231 @@
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/api"
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+//go:embed web
+var assets embed.FS
+
+// Usage is a byte count, in raw bytes.
+type Usage struct {
+	Sent     uint64 `json:"sent"`
+	Received uint64 `json:"received"`
+	Total    uint64 `json:"total"`
+}
+
+type Day struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Usage
+}
+
+type MonthRef struct {
+	Month string `json:"month"` // MM
+	Name  string `json:"name"`
+}
+
+type Month struct {
+	MonthRef
+	Days       []Day `json:"days"`
+	Cumulative Usage `json:"cumulative"`
+}
+
+type Today struct {
+	Date           string `json:"date"`
+	HoursMonitored int    `json:"hours_monitored"`
+	Usage
+}
+
+type handler struct {
+	snapshots *model.SnapshotModel
+	logger    zerolog.Logger
+	now       func() time.Time
+}
+
+// A handler serving the dashboard and its JSON API over the model, next to the
+// query API. The live chart streams the events of the monitor at monitorURL,
+// if any.
+func NewHandler(snapshots *model.SnapshotModel, monitorURL string, logger zerolog.Logger) (http.Handler, error) {
+	h := &handler{snapshots: snapshots, logger: logger, now: time.Now}
+
+	web, err := fs.Sub(assets, "web")
+
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+
+	mux.Handle("/", http.FileServer(http.FS(web)))
+	mux.HandleFunc("/api/months", h.get(h.months))
+	mux.HandleFunc("/api/months/", h.get(h.month))
+	mux.HandleFunc("/api/days", h.get(h.days))
+	mux.HandleFunc("/api/today", h.get(h.today))
+	mux.Handle(api.Prefix+"/", api.NewHandler(snapshots, logger))
+
+	if monitorURL == "" {
+		mux.HandleFunc("/api/live", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "no monitor configured", http.StatusNotFound)
+		})
+
+		return mux, nil
+	}
+
+	target, err := url.Parse(monitorURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid monitor url: %w", err)
+	}
+
+	live := httputil.NewSingleHostReverseProxy(target)
+	director := live.Director
+
+	live.Director = func(r *http.Request) {
+		director(r)
+		r.URL.Path = strings.TrimSuffix(target.Path, "/") + EventsPath
+		r.URL.RawPath = ""
+	}
+
+	live.FlushInterval = -1
+
+	mux.Handle("/api/live", live)
+
+	return mux, nil
+}
+
+// Adapt an API endpoint, writing its result as JSON and its error as a status.
+func (h *handler) get(endpoint func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		result, err := endpoint(r)
+
+		if err != nil {
+			status := http.StatusInternalServerError
+
+			switch {
+			case errors.Is(err, model.ErrNoRows):
+				status = http.StatusNotFound
+			case errors.Is(err, model.ErrTimedOut):
+				status = http.StatusServiceUnavailable
+			case errors.Is(err, errBadRequest):
+				status = http.StatusBadRequest
+			default:
+				h.logger.Error().Err(err).Str("path", r.URL.Path).Msg("failed to serve api request")
+			}
+
+			http.Error(w, err.Error(), status)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+var errBadRequest = errors.New("bad request")
+
+func (h *handler) months(r *http.Request) (interface{}, error) {
+	months, err := h.snapshots.GetMonthsInYear(r.Context(), strconv.Itoa(h.now().In(h.snapshots.Location()).Year()))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get months: %w", err)
+	}
+
+	refs := make([]MonthRef, 0, len(months))
+
+	for _, month := range months {
+		refs = append(refs, monthRef(month))
+	}
+
+	return refs, nil
+}
+
+func monthRef(month string) MonthRef {
+	n, _ := strconv.Atoi(month)
+
+	return MonthRef{Month: month, Name: time.Month(n).String()}
+}
+
+func (h *handler) month(r *http.Request) (interface{}, error) {
+	month := strings.TrimPrefix(r.URL.Path, "/api/months/")
+
+	if n, err := strconv.Atoi(month); err != nil || len(month) != 2 || n < 1 || n > 12 {
+		return nil, fmt.Errorf("%w: month must be MM", errBadRequest)
+	}
+
+	stats, err := h.snapshots.GetStatsByMonth(r.Context(), month)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats by month: %w", err)
+	}
+
+	if len(stats) == 0 {
+		return nil, model.ErrNoRows
+	}
+
+	// The last row sums up the month, like the statistics table footer.
+	result := Month{
+		MonthRef:   monthRef(month),
+		Days:       days(stats[:len(stats)-1], h.snapshots.Location()),
+		Cumulative: usage(stats[len(stats)-1].Stat),
+	}
+
+	return result, nil
+}
+
+func (h *handler) days(r *http.Request) (interface{}, error) {
+	stats, err := h.snapshots.GetAllStats(r.Context())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	return days(stats, h.snapshots.Location()), nil
+}
+
+func (h *handler) today(r *http.Request) (interface{}, error) {
+	date := h.now().In(h.snapshots.Location()).Format("2006-01-02")
+
+	stat, err := h.snapshots.GetStatByDate(r.Context(), date)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get today's stat: %w", err)
+	}
+
+	return Today{Date: date, HoursMonitored: stat.HoursMonitored, Usage: usage(stat.Stat)}, nil
+}
+
+// The days of the stats, whose timestamps are midnights in loc.
+func days(stats []model.Snapshot, loc *time.Location) []Day {
+	out := make([]Day, 0, len(stats))
+
+	for _, s := range stats {
+		out = append(out, Day{Date: time.Unix(s.Timestamp, 0).In(loc).Format("2006-01-02"), Usage: usage(s.Stat)})
+	}
+
+	return out
+}
+
+func usage(s model.Stat) Usage {
+	return Usage{Sent: s.Sent, Received: s.Received, Total: s.Total}
+}
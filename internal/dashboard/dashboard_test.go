@@ -0,0 +1,181 @@
+package dashboard
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+func newTestModel(t *testing.T) *model.SnapshotModel {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = model.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return model.NewSnapshotModel(db)
+}
+
+func getJSON(t *testing.T, url string, target interface{}) int {
+	t.Helper()
+
+	response, err := http.Get(url)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusOK {
+		if err = json.NewDecoder(response.Body).Decode(target); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return response.StatusCode
+}
+
+func TestAPI(t *testing.T) {
+	snapshots := newTestModel(t)
+	now := time.Now()
+
+	for host, stat := range map[string]model.Stat{"": {Sent: 10, Received: 20, Total: 30}, "web-1": {Sent: 1, Received: 2, Total: 3}} {
+		if err := snapshots.Insert(context.Background(), &model.Snapshot{Timestamp: now.Unix(), Host: host, Stat: stat}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler, err := NewHandler(snapshots, "", zerolog.Nop())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var months []MonthRef
+
+	if status := getJSON(t, server.URL+"/api/months", &months); status != http.StatusOK || len(months) != 1 || months[0].Month != now.Format("01") {
+		t.Fatalf("got %d %+v, expected the current month", status, months)
+	}
+
+	var month Month
+
+	if status := getJSON(t, server.URL+"/api/months/"+months[0].Month, &month); status != http.StatusOK {
+		t.Fatalf("got %d, expected the month's stats", status)
+	}
+
+	if len(month.Days) != 1 || month.Days[0].Date != now.Format("2006-01-02") || month.Days[0].Total != 33 || month.Cumulative.Sent != 11 {
+		t.Errorf("got %+v, expected one day summing up to 33 bytes", month)
+	}
+
+	var days []Day
+
+	if status := getJSON(t, server.URL+"/api/days", &days); status != http.StatusOK || len(days) != 1 || days[0].Received != 22 {
+		t.Errorf("got %d %+v, expected one day with 22 bytes received", status, days)
+	}
+
+	var today Today
+
+	if status := getJSON(t, server.URL+"/api/today", &today); status != http.StatusOK || today.HoursMonitored != 2 || today.Total != 33 {
+		t.Errorf("got %d %+v, expected 2 snapshots summing up to 33 bytes", status, today)
+	}
+
+	for path, expected := range map[string]int{
+		"/api/months/13": http.StatusBadRequest,
+		"/api/months/1":  http.StatusBadRequest,
+		"/api/live":      http.StatusNotFound,
+	} {
+		if status := getJSON(t, server.URL+path, nil); status != expected {
+			t.Errorf("%s: got %d, expected %d", path, status, expected)
+		}
+	}
+
+	response, err := http.Get(server.URL + "/")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(response.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("got %d %s, expected the embedded index", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+}
+
+func TestLiveEvents(t *testing.T) {
+	events := NewEvents()
+
+	mux := http.NewServeMux()
+	mux.Handle(EventsPath, events)
+
+	monitor := httptest.NewServer(mux)
+	defer monitor.Close()
+
+	handler, err := NewHandler(newTestModel(t), monitor.URL, zerolog.Nop())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/api/live")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	if ct := response.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("got %q, expected an event stream", ct)
+	}
+
+	lines := bufio.NewScanner(response.Body)
+
+	// The stream is open once the comment announcing it arrives.
+	if !lines.Scan() || lines.Text() != ": connected" {
+		t.Fatalf("got %q, expected the stream to be connected", lines.Text())
+	}
+
+	events.Publish([]byte(`{"total_rate":42}`))
+
+	for lines.Scan() {
+		if lines.Text() == "" {
+			continue
+		}
+
+		if lines.Text() != `data: {"total_rate":42}` {
+			t.Errorf("got %q, expected the published tick", lines.Text())
+		}
+
+		break
+	}
+
+	if events.Clients() != 1 {
+		t.Errorf("got %d clients, expected 1", events.Clients())
+	}
+}
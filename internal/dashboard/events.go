@@ -0,0 +1,93 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// EventsPath is where a monitor serves its live ticks.
+const EventsPath = "/events"
+
+// Events broadcasts messages to every connected client as server-sent events.
+// A client that cannot keep up misses messages rather than slowing down the
+// publisher.
+type Events struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+func NewEvents() *Events {
+	return &Events{clients: map[chan []byte]struct{}{}}
+}
+
+// Send a message, a single line of JSON, to the connected clients.
+func (e *Events) Publish(message []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for client := range e.clients {
+		select {
+		case client <- message:
+		default:
+		}
+	}
+}
+
+// The number of connected clients.
+func (e *Events) Clients() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.clients)
+}
+
+func (e *Events) subscribe() chan []byte {
+	client := make(chan []byte, 16)
+
+	e.mu.Lock()
+	e.clients[client] = struct{}{}
+	e.mu.Unlock()
+
+	return client
+}
+
+func (e *Events) unsubscribe(client chan []byte) {
+	e.mu.Lock()
+	delete(e.clients, client)
+	e.mu.Unlock()
+}
+
+func (e *Events) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := e.subscribe()
+	defer e.unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Let the client know it is connected before the first tick.
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message := <-client:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", message); err != nil {
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,261 @@
+"use strict";
+
+// Same formatting as util.ByteCountSI.
+function byteCountSI(bytes) {
+	const unit = 1000;
+
+	if (bytes < unit) {
+		return bytes + " B";
+	}
+
+	let div = unit, exp = 0;
+
+	for (let n = bytes / unit; n >= unit; n /= unit) {
+		div *= unit;
+		exp++;
+	}
+
+	return (bytes / div).toFixed(1) + " " + "kMGTPE"[exp] + "B";
+}
+
+async function getJSON(path) {
+	const response = await fetch(path);
+
+	if (response.status === 404) {
+		return null;
+	}
+
+	if (!response.ok) {
+		throw new Error(path + ": " + response.status + " " + (await response.text()));
+	}
+
+	return response.json();
+}
+
+function color(name) {
+	return getComputedStyle(document.documentElement).getPropertyValue(name).trim();
+}
+
+// Size the canvas for the device pixel ratio and return its context and size.
+function prepare(canvas) {
+	const ratio = window.devicePixelRatio || 1;
+	const width = canvas.clientWidth;
+	const height = canvas.height / (canvas.dataset.ratio || 1);
+
+	canvas.dataset.ratio = ratio;
+	canvas.width = width * ratio;
+	canvas.height = height * ratio;
+
+	const ctx = canvas.getContext("2d");
+	ctx.scale(ratio, ratio);
+	ctx.clearRect(0, 0, width, height);
+	ctx.font = "11px system-ui, sans-serif";
+
+	return { ctx, width, height };
+}
+
+const margin = { top: 10, right: 10, bottom: 24, left: 70 };
+
+function drawAxis(ctx, width, height, max, format) {
+	ctx.strokeStyle = color("--grid");
+	ctx.fillStyle = color("--muted");
+	ctx.textAlign = "right";
+	ctx.textBaseline = "middle";
+
+	const plot = height - margin.top - margin.bottom;
+
+	for (let i = 0; i <= 4; i++) {
+		const y = margin.top + plot - (plot * i) / 4;
+
+		ctx.beginPath();
+		ctx.moveTo(margin.left, y);
+		ctx.lineTo(width - margin.right, y);
+		ctx.stroke();
+		ctx.fillText(format((max * i) / 4), margin.left - 6, y);
+	}
+}
+
+// Stacked bars of sent and received bytes.
+function barChart(canvas, labels, sent, received) {
+	const { ctx, width, height } = prepare(canvas);
+	const max = Math.max(1, ...sent.map((s, i) => s + received[i]));
+	const plot = height - margin.top - margin.bottom;
+	const step = (width - margin.left - margin.right) / Math.max(1, labels.length);
+	const bar = Math.max(1, step * 0.7);
+
+	drawAxis(ctx, width, height, max, byteCountSI);
+
+	ctx.textAlign = "center";
+	ctx.textBaseline = "top";
+
+	labels.forEach((label, i) => {
+		const x = margin.left + step * i + (step - bar) / 2;
+		const r = (received[i] / max) * plot;
+		const s = (sent[i] / max) * plot;
+
+		ctx.fillStyle = color("--received");
+		ctx.fillRect(x, margin.top + plot - r, bar, r);
+		ctx.fillStyle = color("--sent");
+		ctx.fillRect(x, margin.top + plot - r - s, bar, s);
+
+		// Skip labels that would overlap.
+		if (labels.length <= 16 || i % Math.ceil(labels.length / 16) === 0) {
+			ctx.fillStyle = color("--muted");
+			ctx.fillText(label, x + bar / 2, height - margin.bottom + 6);
+		}
+	});
+}
+
+function lineChart(canvas, points) {
+	const { ctx, width, height } = prepare(canvas);
+	const max = Math.max(1, ...points.map((p) => Math.max(p.sent, p.received)));
+	const plot = height - margin.top - margin.bottom;
+	const step = (width - margin.left - margin.right) / Math.max(1, liveWindow - 1);
+
+	drawAxis(ctx, width, height, max, (v) => byteCountSI(Math.round(v)) + "/s");
+
+	for (const [key, name] of [["sent", "--sent"], ["received", "--received"]]) {
+		ctx.strokeStyle = color(name);
+		ctx.lineWidth = 2;
+		ctx.beginPath();
+
+		points.forEach((p, i) => {
+			const x = margin.left + step * (liveWindow - points.length + i);
+			const y = margin.top + plot - (p[key] / max) * plot;
+
+			i === 0 ? ctx.moveTo(x, y) : ctx.lineTo(x, y);
+		});
+
+		ctx.stroke();
+		ctx.lineWidth = 1;
+	}
+}
+
+function row(cells, tag) {
+	const tr = document.createElement("tr");
+
+	for (const cell of cells) {
+		const td = document.createElement(tag || "td");
+		td.textContent = cell;
+		tr.appendChild(td);
+	}
+
+	return tr;
+}
+
+function usageCells(u) {
+	return [byteCountSI(u.sent), byteCountSI(u.received), byteCountSI(u.total)];
+}
+
+async function showMonth(month) {
+	const stats = await getJSON("/api/months/" + month);
+	const tbody = document.querySelector("#month-table tbody");
+	const tfoot = document.querySelector("#month-table tfoot");
+
+	tbody.replaceChildren();
+	tfoot.replaceChildren();
+
+	if (!stats) {
+		barChart(document.getElementById("daily"), [], [], []);
+		return;
+	}
+
+	const days = stats.days.slice().reverse();
+
+	barChart(
+		document.getElementById("daily"),
+		days.map((d) => d.date.slice(8)),
+		days.map((d) => d.sent),
+		days.map((d) => d.received),
+	);
+
+	for (const day of stats.days) {
+		tbody.appendChild(row([day.date, ...usageCells(day)]));
+	}
+
+	tfoot.appendChild(row(["Cumulative", ...usageCells(stats.cumulative)]));
+}
+
+async function showMonths() {
+	const months = (await getJSON("/api/months")) || [];
+	const select = document.getElementById("month");
+
+	select.replaceChildren();
+
+	for (const m of months) {
+		const option = document.createElement("option");
+		option.value = m.month;
+		option.textContent = m.name;
+		select.appendChild(option);
+	}
+
+	select.onchange = () => showMonth(select.value);
+
+	const stats = (await Promise.all(months.map((m) => getJSON("/api/months/" + m.month)))).filter(Boolean).reverse();
+
+	barChart(
+		document.getElementById("monthly"),
+		stats.map((s) => s.name.slice(0, 3)),
+		stats.map((s) => s.cumulative.sent),
+		stats.map((s) => s.cumulative.received),
+	);
+
+	if (months.length > 0) {
+		await showMonth(months[0].month);
+	}
+}
+
+async function showAll() {
+	const days = (await getJSON("/api/days")) || [];
+	const tbody = document.querySelector("#all-table tbody");
+
+	tbody.replaceChildren();
+
+	for (const day of days) {
+		tbody.appendChild(row([day.date, ...usageCells(day)]));
+	}
+}
+
+async function showToday() {
+	const today = await getJSON("/api/today");
+	const element = document.getElementById("today");
+
+	element.textContent = today
+		? `Today: ${byteCountSI(today.total)} (${byteCountSI(today.sent)} up, ${byteCountSI(today.received)} down)`
+		: "Today: nothing recorded yet";
+}
+
+const liveWindow = 120;
+const livePoints = [];
+
+function showLive() {
+	const status = document.getElementById("live-status");
+	const canvas = document.getElementById("live");
+	const events = new EventSource("/api/live");
+
+	lineChart(canvas, livePoints);
+
+	events.onopen = () => (status.textContent = "connected");
+	events.onerror = () => (status.textContent = "disconnected, retrying");
+
+	events.onmessage = (event) => {
+		const tick = JSON.parse(event.data);
+
+		livePoints.push({ sent: tick.sent_rate, received: tick.received_rate });
+
+		if (livePoints.length > liveWindow) {
+			livePoints.shift();
+		}
+
+		status.textContent = byteCountSI(Math.round(tick.total_rate)) + "/s";
+		lineChart(canvas, livePoints);
+	};
+}
+
+function refresh() {
+	Promise.all([showToday(), showMonths(), showAll()]).catch((err) => console.error(err));
+}
+
+showLive();
+refresh();
+setInterval(showToday, 60 * 1000);
@@ -0,0 +1,44 @@
+// Package fleet streams the snapshots captured by agents to a central server.
+package fleet
+
+import "github.com/omarabdelaz1z/go-monitor/internal/model"
+
+// The path a server accepts batches of snapshots on.
+const SnapshotsPath = "/api/v1/snapshots"
+
+// A Snapshot is the wire form of a model.Snapshot.
+type Snapshot struct {
+	Timestamp int64  `json:"timestamp"`
+	Source    string `json:"source,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	Sent      uint64 `json:"sent"`
+	Received  uint64 `json:"received"`
+	Total     uint64 `json:"total"`
+}
+
+// A Batch carries snapshots captured by one host.
+type Batch struct {
+	Host      string     `json:"host"`
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+func fromModel(s model.Snapshot) Snapshot {
+	return Snapshot{
+		Timestamp: s.Timestamp,
+		Source:    s.Source,
+		Interface: s.Interface,
+		Sent:      s.Stat.Sent,
+		Received:  s.Stat.Received,
+		Total:     s.Stat.Total,
+	}
+}
+
+func (s Snapshot) toModel(host string) model.Snapshot {
+	return model.Snapshot{
+		Timestamp: s.Timestamp,
+		Source:    s.Source,
+		Interface: s.Interface,
+		Host:      host,
+		Stat:      model.Stat{Sent: s.Sent, Received: s.Received, Total: s.Total},
+	}
+}
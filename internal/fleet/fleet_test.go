@@ -0,0 +1,155 @@
+package fleet
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+func newTestServer(t *testing.T, token string) (*httptest.Server, *model.SnapshotModel, *int32) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = model.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots := model.NewSnapshotModel(db)
+	handler := NewHandler(snapshots, token, zerolog.Nop())
+
+	// The server is unreachable while down is set.
+	down := new(int32)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(down) == 1 {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, snapshots, down
+}
+
+func TestForwarderBuffersWhileServerIsDown(t *testing.T) {
+	ctx := context.Background()
+	server, snapshots, down := newTestServer(t, "secret")
+
+	now := time.Now().Unix()
+	f := NewForwarder(server.URL, "web-1", "secret", 2)
+
+	atomic.StoreInt32(down, 1)
+
+	for i := int64(0); i < 3; i++ {
+		f.Enqueue(model.Snapshot{Timestamp: now + i, Stat: model.Stat{Sent: 1, Received: 1, Total: 2}})
+
+		if err := f.Flush(ctx); err == nil || !strings.Contains(err.Error(), "503") {
+			t.Fatalf("got %v, expected the server to be unreachable", err)
+		}
+	}
+
+	if pending, dropped := f.Stats(); pending != 2 || dropped != 1 {
+		t.Errorf("got %d pending and %d dropped, expected the oldest snapshot to be dropped", pending, dropped)
+	}
+
+	atomic.StoreInt32(down, 0)
+
+	if err := f.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if pending, _ := f.Stats(); pending != 0 {
+		t.Errorf("got %d pending, expected the buffer to be drained", pending)
+	}
+
+	stats, err := snapshots.GetHostStatsByDate(ctx, time.Unix(now, 0).Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 || stats[0].Host != "web-1" || stats[0].Total != 4 {
+		t.Errorf("got %+v, expected two snapshots of web-1", stats)
+	}
+}
+
+func TestHandlerRejectsUnauthorized(t *testing.T) {
+	server, _, _ := newTestServer(t, "secret")
+
+	err := NewForwarder(server.URL, "web-1", "wrong", 10).send(context.Background(), []Snapshot{{Timestamp: 1}})
+
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("got %v, expected the batch to be unauthorized", err)
+	}
+
+	res, err := http.Post(server.URL+SnapshotsPath, "application/json", strings.NewReader(`{"snapshots": []}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got %s, expected 401 without token", res.Status)
+	}
+}
+
+func TestHandlerStoresRetriedBatchOnce(t *testing.T) {
+	ctx := context.Background()
+	server, snapshots, _ := newTestServer(t, "secret")
+
+	now := time.Now().Unix()
+	f := NewForwarder(server.URL, "web-1", "secret", 10)
+
+	// The response to the first post is lost, the agent sends the batch again.
+	batch := []Snapshot{
+		{Timestamp: now, Sent: 1, Received: 2, Total: 3},
+		{Timestamp: now, Source: "snmp:core", Interface: "Gi0/1", Sent: 10, Received: 20, Total: 30},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := f.send(ctx, batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := snapshots.GetHostStatsByDate(ctx, time.Unix(now, 0).Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 || stats[0].Total != 3 {
+		t.Errorf("got %+v, expected the snapshot of web-1 to be stored once", stats)
+	}
+
+	ports, err := snapshots.WithInterface("Gi0/1").GetHostStatsByDate(ctx, time.Unix(now, 0).Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ports) != 1 || ports[0].Total != 30 {
+		t.Errorf("got %+v, expected the snapshot of Gi0/1 to be stored once", ports)
+	}
+}
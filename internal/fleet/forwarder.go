@@ -0,0 +1,165 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+)
+
+const maxBatchSize = 500
+
+// A Forwarder streams snapshots to a server, buffering them while the server
+// is unreachable. Once the buffer is full the oldest snapshots are dropped.
+type Forwarder struct {
+	url, host, token string
+	client           *http.Client
+	limit            int
+
+	mu      sync.Mutex
+	pending []Snapshot
+	dropped uint64
+
+	notify chan struct{}
+}
+
+func NewForwarder(server, host, token string, limit int) *Forwarder {
+	return &Forwarder{
+		url:    strings.TrimSuffix(server, "/") + SnapshotsPath,
+		host:   host,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+		limit:  limit,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Queue a snapshot to be sent as soon as possible.
+func (f *Forwarder) Enqueue(s model.Snapshot) {
+	f.mu.Lock()
+	f.push(fromModel(s))
+	f.mu.Unlock()
+
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Append to the buffer, dropping the oldest snapshots beyond the limit.
+func (f *Forwarder) push(snapshots ...Snapshot) {
+	f.pending = append(f.pending, snapshots...)
+
+	if over := len(f.pending) - f.limit; f.limit > 0 && over > 0 {
+		f.pending = append(f.pending[:0:0], f.pending[over:]...)
+		f.dropped += uint64(over)
+	}
+}
+
+// The number of snapshots waiting to be sent, and dropped so far.
+func (f *Forwarder) Stats() (pending int, dropped uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.pending), f.dropped
+}
+
+// Send every buffered snapshot, keeping those the server did not accept.
+func (f *Forwarder) Flush(ctx context.Context) error {
+	for {
+		f.mu.Lock()
+		n := len(f.pending)
+
+		if n > maxBatchSize {
+			n = maxBatchSize
+		}
+
+		batch := append([]Snapshot(nil), f.pending[:n]...)
+		f.pending = f.pending[n:]
+		f.mu.Unlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := f.send(ctx, batch); err != nil {
+			f.mu.Lock()
+			rest := f.pending
+			f.pending = nil
+			f.push(append(batch, rest...)...)
+			f.mu.Unlock()
+
+			return err
+		}
+	}
+}
+
+func (f *Forwarder) send(ctx context.Context, snapshots []Snapshot) error {
+	body, err := json.Marshal(Batch{Host: f.host, Snapshots: snapshots})
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+
+	res, err := f.client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("failed to send snapshots: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("server rejected snapshots: %s: %s", res.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
+
+// Send snapshots as they are queued until ctx is done, retrying every retry
+// interval while the server is unreachable. A last attempt is made on exit.
+func (f *Forwarder) Run(ctx context.Context, retry time.Duration, onError func(error)) {
+	ticker := time.NewTicker(retry)
+	defer ticker.Stop()
+
+	flush := func(ctx context.Context) {
+		if err := f.Flush(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			flush(final)
+			cancel()
+
+			return
+		case <-f.notify:
+			flush(ctx)
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
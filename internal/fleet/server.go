@@ -0,0 +1,68 @@
+package fleet
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+const maxBatchBytes = 8 << 20
+
+// A handler storing the batches agents post into the model, keyed by host.
+// Requests must carry the token as a bearer token unless it is empty.
+func NewHandler(snapshots *model.SnapshotModel, token string, logger zerolog.Logger) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(SnapshotsPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var batch Batch
+
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&batch); err != nil {
+			http.Error(w, "invalid batch: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if batch.Host == "" {
+			http.Error(w, "invalid batch: missing host", http.StatusBadRequest)
+			return
+		}
+
+		rows := make([]model.Snapshot, 0, len(batch.Snapshots))
+
+		for _, s := range batch.Snapshots {
+			rows = append(rows, s.toModel(batch.Host))
+		}
+
+		if err := snapshots.InsertSnapshots(r.Context(), rows); err != nil {
+			status := http.StatusInternalServerError
+
+			if errors.Is(err, model.ErrTimedOut) {
+				status = http.StatusServiceUnavailable
+			}
+
+			logger.Error().Err(err).Str("host", batch.Host).Msg("failed to store batch")
+			http.Error(w, "failed to store batch", status)
+
+			return
+		}
+
+		logger.Debug().Str("host", batch.Host).Int("snapshots", len(rows)).Msg("batch stored")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return mux
+}
@@ -0,0 +1,291 @@
+// Package logfile writes logs to a file rotated by size and age, keeping a
+// number of compressed backups next to it.
+package logfile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layout of the time a backup was rotated at, in its name.
+const backupTimeLayout = "2006-01-02T15-04-05.000"
+
+type Options struct {
+	Path string
+
+	MaxSize    int64         // bytes written before the file is rotated, 0 for no limit
+	MaxAge     time.Duration // age at which the file is rotated, 0 for no limit
+	MaxBackups int           // rotated files kept, 0 keeps them all
+	Compress   bool          // gzip rotated files
+}
+
+// A File is an io.Writer appending to the file at Options.Path, which is moved
+// aside as path-<time>.ext once it grows too large or too old.
+type File struct {
+	options Options
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+
+	// Backups are compressed and pruned in the background, one pass at a time.
+	mill sync.Mutex
+	wg   sync.WaitGroup
+}
+
+// Open the log file, creating it and its directory as needed.
+func Open(options Options) (*File, error) {
+	if options.Path == "" {
+		return nil, fmt.Errorf("no log file path given")
+	}
+
+	f := &File{options: options, now: time.Now}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.options.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(f.options.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	f.file, f.size, f.opened = file, info.Size(), f.now()
+
+	// A file left by a previous run is as old as its last write.
+	if info.Size() > 0 {
+		f.opened = info.ModTime()
+	}
+
+	return nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Whether writing n more bytes calls for a new file. A single write larger
+// than the limit still goes to an empty file.
+func (f *File) due(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+
+	if f.options.MaxSize > 0 && f.size+n > f.options.MaxSize {
+		return true
+	}
+
+	return f.options.MaxAge > 0 && f.now().Sub(f.opened) >= f.options.MaxAge
+}
+
+// Rotate moves the current file aside and starts a new one.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+
+	return f.rotate()
+}
+
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+
+	f.file = nil
+
+	if err := os.Rename(f.options.Path, f.backupName(f.now())); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+
+	go func() {
+		defer f.wg.Done()
+		f.millBackups()
+	}()
+
+	return nil
+}
+
+// Close the file once the backups being compressed are done.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.wg.Wait()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
+
+func (f *File) backupName(at time.Time) string {
+	dir, name := filepath.Split(f.options.Path)
+	ext := filepath.Ext(name)
+
+	return filepath.Join(dir, strings.TrimSuffix(name, ext)+"-"+at.Format(backupTimeLayout)+ext)
+}
+
+type backup struct {
+	path string
+	at   time.Time
+}
+
+// The backups of the file, newest first.
+func (f *File) backups() ([]backup, error) {
+	dir, name := filepath.Split(f.options.Path)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+
+	for _, entry := range entries {
+		stamp := strings.TrimSuffix(entry.Name(), ".gz")
+
+		if entry.IsDir() || !strings.HasPrefix(stamp, prefix) || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+
+		at, err := time.ParseInLocation(backupTimeLayout, strings.TrimSuffix(strings.TrimPrefix(stamp, prefix), ext), time.Local)
+
+		if err != nil {
+			continue // another file sharing the prefix
+		}
+
+		backups = append(backups, backup{path: filepath.Join(dir, entry.Name()), at: at})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].at.After(backups[j].at)
+	})
+
+	return backups, nil
+}
+
+// Remove the backups beyond the retention count and compress the others.
+// Failures are reported on stderr, there is no log left to report them to.
+func (f *File) millBackups() {
+	f.mill.Lock()
+	defer f.mill.Unlock()
+
+	backups, err := f.backups()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logfile: failed to list backups: %v\n", err)
+		return
+	}
+
+	for i, b := range backups {
+		if f.options.MaxBackups > 0 && i >= f.options.MaxBackups {
+			if err := os.Remove(b.path); err != nil {
+				fmt.Fprintf(os.Stderr, "logfile: failed to remove backup: %v\n", err)
+			}
+
+			continue
+		}
+
+		if f.options.Compress && !strings.HasSuffix(b.path, ".gz") {
+			if err := compress(b.path); err != nil {
+				fmt.Fprintf(os.Stderr, "logfile: failed to compress backup: %v\n", err)
+			}
+		}
+	}
+}
+
+// Replace the file by its gzipped copy.
+func compress(path string) error {
+	in, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,124 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	var r io.Reader = file
+
+	if strings.HasSuffix(path, ".gz") {
+		if r, err = gzip.NewReader(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "monitoor.log")
+
+	f, err := Open(Options{Path: path, MaxSize: 10, MaxBackups: 2, Compress: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2022, 8, 1, 10, 0, 0, 0, time.Local)
+	f.now = func() time.Time { at = at.Add(time.Second); return at }
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := read(t, path); got != "fourth\n" {
+		t.Errorf("got %q, expected the last line in the current file", got)
+	}
+
+	backups, err := f.backups()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != 2 {
+		t.Fatalf("got %v, expected the 2 newest backups only", backups)
+	}
+
+	for i, expected := range []string{"third\n", "second\n"} {
+		if !strings.HasSuffix(backups[i].path, ".log.gz") || read(t, backups[i].path) != expected {
+			t.Errorf("got %s, expected %q compressed", backups[i].path, expected)
+		}
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statistics.log")
+
+	f, err := Open(Options{Path: path, MaxAge: time.Hour})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	at := time.Now()
+	f.now = func() time.Time { return at }
+
+	f.Write([]byte("old\n"))
+
+	at = at.Add(30 * time.Minute)
+	f.Write([]byte("recent\n"))
+
+	at = at.Add(time.Hour)
+	f.Write([]byte("new\n"))
+
+	if got := read(t, path); got != "new\n" {
+		t.Errorf("got %q, expected a new file after an hour", got)
+	}
+
+	backups, _ := f.backups()
+
+	if len(backups) != 1 || read(t, backups[0].path) != "old\nrecent\n" {
+		t.Errorf("got %v, expected one uncompressed backup", backups)
+	}
+
+	// Unrelated files sharing the prefix are left alone.
+	other := filepath.Join(filepath.Dir(path), "statistics-old.log")
+	os.WriteFile(other, nil, 0644)
+
+	if backups, _ = f.backups(); len(backups) != 1 {
+		t.Errorf("got %v, expected %s not to be taken for a backup", backups, other)
+	}
+}
@@ -0,0 +1,204 @@
+// Package maintenance keeps the snapshot database healthy: backups, integrity
+// checks, repairs and compaction.
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// DefaultMaxBytes is the most bytes a snapshot is believed to count, a
+// petabyte being more than a terabit link carries in an hour.
+const DefaultMaxBytes = 1 << 50
+
+// Backups written to a directory are named after the time they were taken.
+const (
+	backupPrefix = "snapshots-"
+	backupLayout = "2006-01-02T15-04-05"
+	backupExt    = ".db"
+)
+
+// Options select the maintenance tasks, run in the order of the fields.
+type Options struct {
+	Backup   string // file, or directory, the database is backed up to first, none if empty
+	Keep     int    // backups kept in a Backup directory, 0 keeps them all
+	Check    bool   // check the integrity, stopping before any write if it fails
+	Repair   bool   // repair the anomalies rather than only count them
+	MaxBytes int64  // byte counts above are anomalies, DefaultMaxBytes if 0
+	Vacuum   bool
+	Analyze  bool
+}
+
+// A Report tells what a maintenance run did.
+type Report struct {
+	Backup    string   // the file written, if any
+	Problems  []string // found by the integrity check
+	Anomalies []model.AnomalyStat
+	Repaired  bool
+	Vacuumed  bool
+	Analyzed  bool
+}
+
+var ErrCorrupt = errors.New("integrity check failed")
+
+// Run the maintenance of the database, which must be SQLite.
+func Run(ctx context.Context, db *sql.DB, opts Options) (Report, error) {
+	var report Report
+
+	maxBytes := opts.MaxBytes
+
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	// Twice the limit must not overflow when totals are compared.
+	if maxBytes < 0 || maxBytes > 1<<62 {
+		return report, fmt.Errorf("invalid max bytes %d, must be between 1 and 2^62", maxBytes)
+	}
+
+	if opts.Backup != "" {
+		path, err := backup(ctx, db, opts.Backup, opts.Keep)
+
+		if err != nil {
+			return report, err
+		}
+
+		report.Backup = path
+	}
+
+	if opts.Check {
+		problems, err := model.CheckIntegrity(ctx, db)
+
+		if err != nil {
+			return report, fmt.Errorf("failed to check integrity: %w", err)
+		}
+
+		if report.Problems = problems; len(problems) > 0 {
+			return report, fmt.Errorf("%w: %s", ErrCorrupt, strings.Join(problems, "; "))
+		}
+	}
+
+	var err error
+
+	if opts.Repair {
+		report.Anomalies, err = model.RepairAnomalies(ctx, db, maxBytes)
+		report.Repaired = err == nil
+	} else {
+		report.Anomalies, err = model.FindAnomalies(ctx, db, maxBytes)
+	}
+
+	if err != nil {
+		return report, fmt.Errorf("failed to look for anomalies: %w", err)
+	}
+
+	if opts.Vacuum {
+		if err = model.Vacuum(ctx, db); err != nil {
+			return report, fmt.Errorf("failed to vacuum: %w", err)
+		}
+
+		report.Vacuumed = true
+	}
+
+	if opts.Analyze {
+		if err = model.Analyze(ctx, db); err != nil {
+			return report, fmt.Errorf("failed to analyze: %w", err)
+		}
+
+		report.Analyzed = true
+	}
+
+	return report, nil
+}
+
+// Back the database up to a file, or to a new file of a directory whose
+// oldest backups beyond keep are removed, returning the file written.
+func backup(ctx context.Context, db *sql.DB, target string, keep int) (string, error) {
+	info, err := os.Stat(target)
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if err != nil || !info.IsDir() {
+		return target, model.Backup(ctx, db, target)
+	}
+
+	path := filepath.Join(target, backupPrefix+time.Now().Format(backupLayout)+backupExt)
+
+	if err = model.Backup(ctx, db, path); err != nil {
+		return "", err
+	}
+
+	if keep > 0 {
+		if err = prune(target, keep); err != nil {
+			return path, fmt.Errorf("failed to remove old backups: %w", err)
+		}
+	}
+
+	return path, nil
+}
+
+func prune(dir string, keep int) error {
+	backups, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*"+backupExt))
+
+	if err != nil {
+		return err
+	}
+
+	// The names sort by the time they were taken.
+	sort.Strings(backups)
+
+	for len(backups) > keep {
+		if err = os.Remove(backups[0]); err != nil {
+			return err
+		}
+
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// Log what a maintenance run did, warning of the anomalies left.
+func LogReport(logger zerolog.Logger, report Report) {
+	if report.Backup != "" {
+		logger.Info().Str("file", report.Backup).Msg("database backed up")
+	}
+
+	for _, problem := range report.Problems {
+		logger.Error().Str("problem", problem).Msg("database integrity check failed")
+	}
+
+	for _, a := range report.Anomalies {
+		if a.Rows() == 0 {
+			continue
+		}
+
+		event := logger.Warn()
+		msg := "rows with anomalous byte counts found, left as is"
+
+		if report.Repaired {
+			event, msg = logger.Info(), "rows with anomalous byte counts repaired"
+		}
+
+		event.Str("table", a.Table).Int("negative", a.Negative).Int("oversized", a.Oversized).Int("mismatched", a.Mismatched).Msg(msg)
+	}
+
+	if report.Vacuumed {
+		logger.Info().Msg("database vacuumed")
+	}
+
+	if report.Analyzed {
+		logger.Info().Msg("database analyzed")
+	}
+}
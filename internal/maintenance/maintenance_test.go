@@ -0,0 +1,170 @@
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+)
+
+func newTestDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = model.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func count(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+
+	if err := db.QueryRow(`SELECT COUNT(*) FROM snapshots`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestBackupWhileWriting(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDB(t, filepath.Join(dir, "monitor.db?_busy_timeout=5000"))
+	ctx := context.Background()
+	snapshots := model.NewSnapshotModel(db)
+
+	stored := make([]model.Snapshot, 2000)
+
+	for i := range stored {
+		stored[i] = model.Snapshot{Timestamp: int64(i + 1), Stat: model.Stat{Sent: 1, Received: 1, Total: 2}}
+	}
+
+	if err := snapshots.InsertSnapshots(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 2001; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				snapshots.Insert(ctx, &model.Snapshot{Timestamp: int64(i), Stat: model.Stat{Sent: 1, Received: 1, Total: 2}})
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	backups := filepath.Join(dir, "backups")
+
+	if err := os.Mkdir(backups, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, old := range []string{"snapshots-2022-01-01T00-00-00.db", "snapshots-2022-01-02T00-00-00.db"} {
+		if err := os.WriteFile(filepath.Join(backups, old), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Run(ctx, db, Options{Backup: backups, Keep: 2, Check: true})
+
+	close(stop)
+	wg.Wait()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copied := newTestDB(t, report.Backup)
+
+	if problems, err := model.CheckIntegrity(ctx, copied); err != nil || len(problems) > 0 {
+		t.Fatalf("got %v, %v, expected a sound backup", problems, err)
+	}
+
+	if n := count(t, copied); n < 2000 {
+		t.Errorf("got %d snapshots, expected at least the 2000 stored before the backup", n)
+	}
+
+	left, _ := filepath.Glob(filepath.Join(backups, "*"))
+
+	if len(left) != 2 || filepath.Base(left[0]) != "snapshots-2022-01-02T00-00-00.db" || left[1] != report.Backup {
+		t.Errorf("got %v, expected the oldest backup to be removed", left)
+	}
+}
+
+func TestAnomalies(t *testing.T) {
+	db := newTestDB(t, filepath.Join(t.TempDir(), "monitor.db"))
+	ctx := context.Background()
+
+	for i, row := range [][3]int64{
+		{1, 2, 3},               // fine
+		{-5, 2, -3},             // a counter reset, stored from an underflowed uint64
+		{1, 1 << 55, 1 + 1<<55}, // absurd
+		{1, 2, 4},               // mismatched total
+	} {
+		if _, err := db.Exec(`INSERT INTO snapshots (timestamp, sent, received, total) VALUES (?, ?, ?, ?)`, i, row[0], row[1], row[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.Exec(`INSERT INTO unit_snapshots (timestamp, unit, sent, received, total) VALUES (1, 'sshd.service', -1, 0, -1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Run(ctx, db, Options{Check: true, Analyze: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := model.AnomalyStat{Table: "snapshots", Negative: 1, Oversized: 1, Mismatched: 1}
+
+	if report.Anomalies[0] != expected || report.Anomalies[1].Negative != 1 || report.Repaired || !report.Analyzed {
+		t.Errorf("got %+v, expected the anomalies to be flagged only", report)
+	}
+
+	if report, err = Run(ctx, db, Options{Repair: true, Vacuum: true}); err != nil || !report.Repaired || !report.Vacuumed || report.Anomalies[0] != expected {
+		t.Fatalf("got %+v, %v, expected the anomalies to be repaired", report, err)
+	}
+
+	var sent, received, total int64
+
+	if err = db.QueryRow(`SELECT SUM(sent), SUM(received), SUM(total) FROM snapshots`).Scan(&sent, &received, &total); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent != 3 || received != 6 || total != 9 {
+		t.Errorf("got %d, %d and %d bytes, expected the absurd counts zeroed and the totals summed", sent, received, total)
+	}
+
+	if report, _ = Run(ctx, db, Options{}); report.Anomalies[0].Rows()+report.Anomalies[1].Rows() != 0 {
+		t.Errorf("got %+v, expected no anomaly left", report.Anomalies)
+	}
+
+	if _, err = Run(ctx, db, Options{MaxBytes: -1}); err == nil {
+		t.Error("expected a negative limit to fail")
+	}
+}
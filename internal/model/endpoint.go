@@ -0,0 +1,123 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type EndpointStat struct {
+	Source   string // where the traffic was observed, LocalSource if empty
+	Protocol string
+	Address  string
+	Port     int
+	Hostname string
+	Stat
+}
+
+func (m *SnapshotModel) InsertEndpoints(ctx context.Context, timestamp int64, endpoints []EndpointStat) error {
+	query := `INSERT INTO endpoint_snapshots (timestamp, source, host, protocol, address, port, hostname, sent, received, total) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(timeout, nil)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrTimedOut
+		}
+
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, e := range endpoints {
+		args := []interface{}{timestamp, sourceOrLocal(e.Source), m.host, e.Protocol, e.Address, e.Port, e.Hostname, e.Stat.Sent, e.Stat.Received, e.Stat.Total}
+
+		if _, err = tx.ExecContext(timeout, query, args...); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ErrTimedOut
+			}
+
+			return fmt.Errorf("failed to insert endpoint %s: %w", e.Address, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *SnapshotModel) GetTopEndpointsByDate(ctx context.Context, date string, limit int) ([]EndpointStat, error) {
+	filter, filterArgs := m.scopeFilter()
+	period, periodArgs := dateCondition(date, m.location)
+
+	query := `SELECT protocol, address, port, MAX(hostname), SUM(sent), SUM(received), SUM(total)
+	FROM endpoint_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY protocol, address, port
+	ORDER BY SUM(total) DESC
+	LIMIT ?`
+
+	return m.queryEndpointStats(ctx, query, append(append(periodArgs, filterArgs...), limit)...)
+}
+
+func (m *SnapshotModel) GetTopEndpointsByMonth(ctx context.Context, month string, limit int) ([]EndpointStat, error) {
+	period, periodArgs, err := m.monthCondition(ctx, "endpoint_snapshots", month)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filter, filterArgs := m.scopeFilter()
+
+	query := `SELECT protocol, address, port, MAX(hostname), SUM(sent), SUM(received), SUM(total)
+	FROM endpoint_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY protocol, address, port
+	ORDER BY SUM(total) DESC
+	LIMIT ?`
+
+	return m.queryEndpointStats(ctx, query, append(append(periodArgs, filterArgs...), limit)...)
+}
+
+func (m *SnapshotModel) queryEndpointStats(ctx context.Context, query string, args ...interface{}) ([]EndpointStat, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.db.QueryContext(timeout, query, args...)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrTimedOut
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var stats []EndpointStat
+
+	for rows.Next() {
+		var s EndpointStat
+
+		if err = rows.Scan(&s.Protocol, &s.Address, &s.Port, &s.Hostname, &s.Stat.Sent, &s.Stat.Received, &s.Stat.Total); err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return stats, nil
+}
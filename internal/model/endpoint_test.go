@@ -0,0 +1,45 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTopEndpoints(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	now := time.Now()
+
+	intervals := [][]EndpointStat{
+		{
+			{Protocol: "tcp", Address: "93.184.216.34", Port: 443, Hostname: "example.com", Stat: Stat{Sent: 10, Received: 90, Total: 100}},
+			{Protocol: "udp", Address: "1.1.1.1", Port: 53, Stat: Stat{Sent: 5, Received: 5, Total: 10}},
+		},
+		{
+			{Protocol: "tcp", Address: "140.82.112.3", Port: 443, Stat: Stat{Sent: 50, Received: 50, Total: 100}},
+			{Protocol: "tcp", Address: "93.184.216.34", Port: 443, Hostname: "example.com", Stat: Stat{Sent: 1, Received: 9, Total: 10}},
+		},
+	}
+
+	for i, endpoints := range intervals {
+		if err := m.InsertEndpoints(ctx, now.Unix()+int64(i), endpoints); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := m.GetTopEndpointsByDate(ctx, now.Format("2006-01-02"), 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 || stats[0].Address != "93.184.216.34" || stats[0].Total != 110 || stats[0].Hostname != "example.com" {
+		t.Errorf("got %+v, expected example.com with 110 first", stats)
+	}
+
+	if stats[1].Address != "140.82.112.3" {
+		t.Errorf("got %s, expected 140.82.112.3 second", stats[1].Address)
+	}
+}
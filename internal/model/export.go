@@ -0,0 +1,147 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// A SnapshotFilter narrows raw snapshots down, its zero value matches them all.
+type SnapshotFilter struct {
+	From, To  int64   // [From, To) in unix seconds, a zero To has no end
+	Interface *string // nil for every interface, empty for their sum
+}
+
+// EachSnapshot calls fn with the raw snapshots in scope matching the filter,
+// oldest first, until it returns an error. Unlike the reports, it is not
+// bounded in time as it streams entire databases.
+func (m *SnapshotModel) EachSnapshot(ctx context.Context, f SnapshotFilter, fn func(Snapshot) error) error {
+	filter, filterArgs := m.scopeFilter()
+
+	to := f.To
+
+	if to == 0 {
+		to = math.MaxInt64
+	}
+
+	iface := ""
+
+	if f.Interface != nil {
+		iface = *f.Interface
+	}
+
+	query := `SELECT timestamp, source, interface, host, sent, received, total
+	FROM snapshots
+	WHERE timestamp >= ? AND timestamp < ? AND (? = 0 OR interface = ?) AND ` + filter + `
+	ORDER BY timestamp, source, interface, host`
+
+	args := append([]interface{}{f.From, to, f.Interface != nil, iface}, filterArgs...)
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Snapshot
+
+		if err = rows.Scan(&s.Timestamp, &s.Source, &s.Interface, &s.Host, &s.Stat.Sent, &s.Stat.Received, &s.Stat.Total); err != nil {
+			return err
+		}
+
+		if err = fn(s); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// A SnapshotConflict is a snapshot that was not imported as one with other
+// counts is stored at its timestamp and source already.
+type SnapshotConflict struct {
+	Index    int // of the snapshot in the imported batch
+	Existing Stat
+}
+
+// The outcome of ImportSnapshots, a snapshot being either inserted, a
+// duplicate or a conflict.
+type ImportResult struct {
+	Inserted   int
+	Duplicates int
+	Conflicts  []SnapshotConflict
+}
+
+// ImportSnapshots inserts the snapshots that are not stored yet, all or none.
+// A snapshot is identified by its timestamp and source, and by its interface
+// and host which tell the rows of a single capture apart. Identical snapshots
+// are skipped as duplicates, those with other counts as conflicts.
+func (m *SnapshotModel) ImportSnapshots(ctx context.Context, snapshots []Snapshot) (ImportResult, error) {
+	var result ImportResult
+
+	lookup := `SELECT sent, received, total FROM snapshots
+	WHERE timestamp = ? AND source = ? AND interface = ? AND host = ?
+	LIMIT 1`
+
+	insert := `INSERT INTO snapshots (timestamp, source, interface, host, sent, received, total) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(timeout, nil)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return result, ErrTimedOut
+		}
+
+		return result, err
+	}
+
+	defer tx.Rollback()
+
+	for i, s := range snapshots {
+		key := []interface{}{s.Timestamp, sourceOrLocal(s.Source), s.Interface, m.hostOr(s.Host)}
+
+		var existing Stat
+
+		err = tx.QueryRowContext(timeout, lookup, key...).Scan(&existing.Sent, &existing.Received, &existing.Total)
+
+		switch {
+		case err == nil && existing == s.Stat:
+			result.Duplicates++
+			continue
+		case err == nil:
+			result.Conflicts = append(result.Conflicts, SnapshotConflict{Index: i, Existing: existing})
+			continue
+		case !errors.Is(err, sql.ErrNoRows):
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ImportResult{}, ErrTimedOut
+			}
+
+			return ImportResult{}, err
+		}
+
+		if _, err = tx.ExecContext(timeout, insert, append(key, s.Stat.Sent, s.Stat.Received, s.Stat.Total)...); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ImportResult{}, ErrTimedOut
+			}
+
+			return ImportResult{}, fmt.Errorf("failed to insert snapshot at %d: %w", s.Timestamp, err)
+		}
+
+		result.Inserted++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ImportResult{}, err
+	}
+
+	return result, nil
+}
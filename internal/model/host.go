@@ -0,0 +1,120 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type HostStat struct {
+	Host string
+	Stat
+}
+
+// Insert the snapshots an agent streamed at once, all or none.
+func (m *SnapshotModel) InsertSnapshots(ctx context.Context, snapshots []Snapshot) error {
+	// Agents send a batch again when they don't hear back, those stored already
+	// are ignored.
+	query := `INSERT OR IGNORE INTO snapshots (timestamp, source, interface, host, sent, received, total) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(timeout, nil)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrTimedOut
+		}
+
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, s := range snapshots {
+		args := []interface{}{s.Timestamp, sourceOrLocal(s.Source), s.Interface, m.hostOr(s.Host), s.Stat.Sent, s.Stat.Received, s.Stat.Total}
+
+		if _, err = tx.ExecContext(timeout, query, args...); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ErrTimedOut
+			}
+
+			return fmt.Errorf("failed to insert snapshot of %s: %w", s.Host, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *SnapshotModel) GetHostStatsByDate(ctx context.Context, date string) ([]HostStat, error) {
+	filter, filterArgs := m.snapshotFilter()
+	period, periodArgs := dateCondition(date, m.location)
+
+	query := `SELECT host, SUM(sent), SUM(received), SUM(total)
+	FROM snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY host
+	ORDER BY SUM(total) DESC`
+
+	return m.queryHostStats(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) GetHostStatsByMonth(ctx context.Context, month string) ([]HostStat, error) {
+	period, periodArgs, err := m.monthCondition(ctx, "snapshots", month)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filter, filterArgs := m.snapshotFilter()
+
+	query := `SELECT host, SUM(sent), SUM(received), SUM(total)
+	FROM snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY host
+	ORDER BY SUM(total) DESC`
+
+	return m.queryHostStats(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) queryHostStats(ctx context.Context, query string, args ...interface{}) ([]HostStat, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.db.QueryContext(timeout, query, args...)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrTimedOut
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var stats []HostStat
+
+	for rows.Next() {
+		var s HostStat
+
+		if err = rows.Scan(&s.Host, &s.Stat.Sent, &s.Stat.Received, &s.Stat.Total); err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return stats, nil
+}
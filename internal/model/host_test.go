@@ -0,0 +1,52 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostStats(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	now := time.Now()
+
+	if err := m.WithHost("server").Insert(ctx, &Snapshot{Timestamp: now.Unix(), Stat: Stat{Sent: 1, Received: 1, Total: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.WithHost("server").InsertSnapshots(ctx, []Snapshot{
+		{Timestamp: now.Unix(), Host: "web-1", Stat: Stat{Sent: 10, Received: 20, Total: 30}},
+		{Timestamp: now.Unix() + 1, Host: "web-1", Stat: Stat{Sent: 5, Received: 5, Total: 10}},
+		{Timestamp: now.Unix(), Host: "db-1", Stat: Stat{Sent: 3, Received: 3, Total: 6}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.GetHostStatsByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 3 || stats[0].Host != "web-1" || stats[0].Total != 40 || stats[2].Host != "server" {
+		t.Errorf("got %+v, expected web-1 with 40, db-1 then server", stats)
+	}
+
+	stat, err := m.WithHost("db-1").GetStatByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Total != 6 {
+		t.Errorf("got %d, expected db-1 only", stat.Total)
+	}
+
+	if _, err = m.GetHostStatsByMonth(ctx, "13"); err != ErrNoRows {
+		t.Errorf("got %v, expected ErrNoRows", err)
+	}
+}
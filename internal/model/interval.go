@@ -0,0 +1,160 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// An Interval is the usage a source observed during one interval, stored at
+// once with its breakdowns. Every row takes the snapshot's timestamp and
+// source.
+type Interval struct {
+	Snapshot  Snapshot
+	Protocols []ProtocolUsage
+	Endpoints []EndpointStat
+	Subnets   []SubnetStat
+}
+
+// What storing an interval did.
+type IntervalOutcome int
+
+const (
+	IntervalInserted  IntervalOutcome = iota
+	IntervalDuplicate                 // stored already with the same counts, nothing changed
+	IntervalConflict                  // stored already with other counts, nothing changed
+	IntervalMerged                    // added to the interval stored already
+)
+
+// StoreInterval stores an interval in a single transaction, all or none. If
+// the source has a snapshot of it already, the interval is added to it when
+// merge is set, e.g. for flows exported late, and left out otherwise.
+func (m *SnapshotModel) StoreInterval(ctx context.Context, in Interval, merge bool) (IntervalOutcome, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	outcome, err := m.storeInterval(timeout, in, merge)
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return outcome, ErrTimedOut
+	}
+
+	return outcome, err
+}
+
+func (m *SnapshotModel) storeInterval(ctx context.Context, in Interval, merge bool) (IntervalOutcome, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	s := in.Snapshot
+	source, host := sourceOrLocal(s.Source), m.hostOr(s.Host)
+	key := []interface{}{s.Timestamp, source, s.Interface, host}
+
+	lookup := `SELECT sent, received, total FROM snapshots WHERE timestamp = ? AND source = ? AND interface = ? AND host = ?`
+
+	var existing Stat
+
+	err = tx.QueryRowContext(ctx, lookup, key...).Scan(&existing.Sent, &existing.Received, &existing.Total)
+
+	outcome := IntervalInserted
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		insert := `INSERT INTO snapshots (timestamp, source, interface, host, sent, received, total) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+		if _, err = tx.ExecContext(ctx, insert, append(key, s.Stat.Sent, s.Stat.Received, s.Stat.Total)...); err != nil {
+			return 0, fmt.Errorf("failed to insert snapshot at %d: %w", s.Timestamp, err)
+		}
+	case err != nil:
+		return 0, err
+	case !merge && existing == s.Stat:
+		return IntervalDuplicate, nil
+	case !merge:
+		return IntervalConflict, nil
+	default:
+		outcome = IntervalMerged
+
+		update := `UPDATE snapshots SET sent = sent + ?, received = received + ?, total = total + ?
+		WHERE timestamp = ? AND source = ? AND interface = ? AND host = ?`
+
+		if _, err = tx.ExecContext(ctx, update, append([]interface{}{s.Stat.Sent, s.Stat.Received, s.Stat.Total}, key...)...); err != nil {
+			return 0, fmt.Errorf("failed to merge snapshot at %d: %w", s.Timestamp, err)
+		}
+	}
+
+	// The rows of the breakdowns are added to those of the same key, if any.
+	for _, p := range in.Protocols {
+		err = addRow(ctx, tx, "protocol_usage_snapshots", []string{"timestamp", "source", "host", "protocol"},
+			[]interface{}{s.Timestamp, source, m.host, p.Protocol}, nil, p.Stat)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to store protocol %s: %w", p.Protocol, err)
+		}
+	}
+
+	for _, e := range in.Endpoints {
+		err = addRow(ctx, tx, "endpoint_snapshots", []string{"timestamp", "source", "host", "protocol", "address", "port"},
+			[]interface{}{s.Timestamp, source, m.host, e.Protocol, e.Address, e.Port}, map[string]interface{}{"hostname": e.Hostname}, e.Stat)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to store endpoint %s: %w", e.Address, err)
+		}
+	}
+
+	for _, n := range in.Subnets {
+		err = addRow(ctx, tx, "subnet_snapshots", []string{"timestamp", "source", "host", "subnet"},
+			[]interface{}{s.Timestamp, source, m.host, n.Subnet}, nil, n.Stat)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to store subnet %s: %w", n.Subnet, err)
+		}
+	}
+
+	return outcome, tx.Commit()
+}
+
+// Add the counts of stat to the row of a table with the given key, inserting
+// it with the extra columns if there is none.
+func addRow(ctx context.Context, tx *sql.Tx, table string, columns []string, key []interface{}, extra map[string]interface{}, stat Stat) error {
+	conditions := make([]string, len(columns))
+
+	for i, column := range columns {
+		conditions[i] = column + " = ?"
+	}
+
+	update := `UPDATE ` + table + ` SET sent = sent + ?, received = received + ?, total = total + ?
+	WHERE ` + strings.Join(conditions, " AND ")
+
+	result, err := tx.ExecContext(ctx, update, append([]interface{}{stat.Sent, stat.Received, stat.Total}, key...)...)
+
+	if err != nil {
+		return err
+	}
+
+	if updated, _ := result.RowsAffected(); updated > 0 {
+		return nil
+	}
+
+	args := append(append([]interface{}{}, key...), stat.Sent, stat.Received, stat.Total)
+	names := append(append([]string{}, columns...), "sent", "received", "total")
+
+	for column, value := range extra {
+		names = append(names, column)
+		args = append(args, value)
+	}
+
+	placeholders := "?" + strings.Repeat(", ?", len(names)-1)
+	insert := `INSERT INTO ` + table + ` (` + strings.Join(names, ", ") + `) VALUES (` + placeholders + `)`
+
+	_, err = tx.ExecContext(ctx, insert, args...)
+
+	return err
+}
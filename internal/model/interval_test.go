@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStoreInterval(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t).WithSource("netflow:192.0.2.1")
+
+	now := time.Now()
+
+	interval := Interval{
+		Snapshot:  Snapshot{Timestamp: now.Unix(), Source: "netflow:192.0.2.1", Stat: Stat{Sent: 10, Received: 20, Total: 30}},
+		Protocols: []ProtocolUsage{{Protocol: "tcp", Stat: Stat{Sent: 10, Received: 20, Total: 30}}},
+		Endpoints: []EndpointStat{{Protocol: "tcp", Address: "192.0.2.10", Port: 443, Stat: Stat{Sent: 10, Received: 20, Total: 30}}},
+		Subnets:   []SubnetStat{{Subnet: "192.168.1.0/24", Stat: Stat{Sent: 10, Received: 20, Total: 30}}},
+	}
+
+	late := Interval{
+		Snapshot:  Snapshot{Timestamp: now.Unix(), Source: "netflow:192.0.2.1", Stat: Stat{Sent: 1, Received: 1, Total: 2}},
+		Protocols: []ProtocolUsage{{Protocol: "udp", Stat: Stat{Sent: 1, Received: 1, Total: 2}}},
+		Subnets:   []SubnetStat{{Subnet: "192.168.1.0/24", Stat: Stat{Sent: 1, Received: 1, Total: 2}}},
+	}
+
+	steps := []struct {
+		interval Interval
+		merge    bool
+		expected IntervalOutcome
+	}{
+		{interval, false, IntervalInserted},
+		{interval, false, IntervalDuplicate},
+		{late, false, IntervalConflict},
+		{late, true, IntervalMerged},
+	}
+
+	for i, step := range steps {
+		outcome, err := m.StoreInterval(ctx, step.interval, step.merge)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if outcome != step.expected {
+			t.Errorf("step %d: got %d, expected %d", i, outcome, step.expected)
+		}
+	}
+
+	periods, err := m.GetPeriodStats(ctx, 0, now.Unix()+1, PeriodHour, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(periods) != 1 || periods[0].Snapshots != 1 || periods[0].Total != 32 {
+		t.Errorf("got %+v, expected one snapshot with the late traffic added", periods)
+	}
+
+	usage, err := m.GetProtocolUsageByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usage) != 2 || usage[0].Total != 30 || usage[1].Total != 2 {
+		t.Errorf("got %+v, expected tcp stored once and udp added", usage)
+	}
+
+	subnets, err := m.GetSubnetStatsByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(subnets) != 1 || subnets[0].Total != 32 {
+		t.Errorf("got %+v, expected the subnet's row summed", subnets)
+	}
+
+	endpoints, err := m.GetTopEndpointsByDate(ctx, now.Format("2006-01-02"), 10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(endpoints) != 1 || endpoints[0].Total != 30 {
+		t.Errorf("got %+v, expected the endpoint once", endpoints)
+	}
+}
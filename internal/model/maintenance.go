@@ -0,0 +1,234 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Pages copied by a backup step, between which writers get the database.
+const (
+	backupPages = 256
+	backupPause = 10 * time.Millisecond
+)
+
+// Backup copies a consistent snapshot of db, which must be SQLite, to path
+// with the SQLite backup API while it is being written to. The copy is
+// written next to path and renamed once complete.
+func Backup(ctx context.Context, db *sql.DB, path string) error {
+	partial := path + ".partial"
+
+	if err := os.Remove(partial); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	dest, err := sql.Open("sqlite3", partial)
+
+	if err != nil {
+		return err
+	}
+
+	defer dest.Close()
+
+	destConn, err := dest.Conn(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	defer destConn.Close()
+
+	srcConn, err := db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer srcConn.Close()
+
+	err = destConn.Raw(func(destDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			to, ok := destDriver.(*sqlite3.SQLiteConn)
+			from, ok2 := srcDriver.(*sqlite3.SQLiteConn)
+
+			if !ok || !ok2 {
+				return errors.New("only sqlite3 databases can be backed up")
+			}
+
+			return backup(ctx, to, from)
+		})
+	})
+
+	if err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	if err = destConn.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partial, path)
+}
+
+// Copy the database a few pages at a time. The backup API starts over when
+// another connection writes in between, and picks up the writes of this one.
+func backup(ctx context.Context, to, from *sqlite3.SQLiteConn) error {
+	b, err := to.Backup("main", from, "main")
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		done, err := b.Step(backupPages)
+
+		if err != nil {
+			b.Close()
+			return err
+		}
+
+		if done {
+			return b.Finish()
+		}
+
+		select {
+		case <-ctx.Done():
+			b.Close()
+			return ctx.Err()
+		case <-time.After(backupPause):
+		}
+	}
+}
+
+// CheckIntegrity runs SQLite's integrity check, returning the problems found,
+// none if the database is sound.
+func CheckIntegrity(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var problems []string
+
+	for rows.Next() {
+		var problem string
+
+		if err = rows.Scan(&problem); err != nil {
+			return nil, err
+		}
+
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems, rows.Err()
+}
+
+// Vacuum rebuilds the database, reclaiming the space of deleted rows.
+func Vacuum(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "VACUUM")
+
+	return err
+}
+
+// Analyze gathers the statistics the query planner picks indexes with.
+func Analyze(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "ANALYZE")
+
+	return err
+}
+
+// The tables holding byte counts.
+var countTables = []string{"snapshots", "unit_snapshots", "endpoint_snapshots", "subnet_snapshots", "protocol_usage_snapshots"}
+
+// An AnomalyStat counts the rows of a table whose byte counts can't be right:
+// negative ones, like a counter reset underflowing to a huge number once
+// stored, ones above the limit, and totals that are not sent plus received.
+type AnomalyStat struct {
+	Table      string
+	Negative   int
+	Oversized  int
+	Mismatched int
+}
+
+func (a AnomalyStat) Rows() int {
+	return a.Negative + a.Oversized + a.Mismatched
+}
+
+// FindAnomalies counts the rows with byte counts below 0 or above limit, and
+// those whose total is off.
+func FindAnomalies(ctx context.Context, db *sql.DB, limit int64) ([]AnomalyStat, error) {
+	return anomalies(ctx, db, limit)
+}
+
+// RepairAnomalies zeroes the byte counts below 0 or above limit, as what was
+// transferred is unknown, and sets totals to sent plus received. It returns
+// the anomalies it repaired.
+func RepairAnomalies(ctx context.Context, db *sql.DB, limit int64) ([]AnomalyStat, error) {
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	stats, err := anomalies(ctx, tx, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range countTables {
+		repair := `UPDATE ` + table + ` SET
+			sent = CASE WHEN sent < 0 OR sent > ?1 THEN 0 ELSE sent END,
+			received = CASE WHEN received < 0 OR received > ?1 THEN 0 ELSE received END
+		WHERE sent < 0 OR sent > ?1 OR received < 0 OR received > ?1`
+
+		if _, err = tx.ExecContext(ctx, repair, limit); err != nil {
+			return nil, fmt.Errorf("failed to repair %s: %w", table, err)
+		}
+
+		if _, err = tx.ExecContext(ctx, `UPDATE `+table+` SET total = sent + received WHERE total != sent + received`); err != nil {
+			return nil, fmt.Errorf("failed to repair totals of %s: %w", table, err)
+		}
+	}
+
+	return stats, tx.Commit()
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func anomalies(ctx context.Context, q queryer, limit int64) ([]AnomalyStat, error) {
+	stats := make([]AnomalyStat, 0, len(countTables))
+
+	for _, table := range countTables {
+		query := `SELECT
+			COUNT(CASE WHEN sent < 0 OR received < 0 OR total < 0 THEN 1 END),
+			COUNT(CASE WHEN sent >= 0 AND received >= 0 AND total >= 0 AND (sent > ?1 OR received > ?1 OR total > ?1) THEN 1 END),
+			COUNT(CASE WHEN sent BETWEEN 0 AND ?1 AND received BETWEEN 0 AND ?1 AND total BETWEEN 0 AND ?1 AND total != sent + received THEN 1 END)
+		FROM ` + table
+
+		stat := AnomalyStat{Table: table}
+
+		if err := q.QueryRowContext(ctx, query, limit).Scan(&stat.Negative, &stat.Oversized, &stat.Mismatched); err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", table, err)
+		}
+
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
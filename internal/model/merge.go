@@ -0,0 +1,469 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A table merged from other databases. A row is identified by its key, the
+// columns but timestamp being the series it belongs to. Rows with the same key
+// are duplicates if their counts are equal, and conflicts otherwise.
+type mergeTable struct {
+	name   string
+	key    []string
+	counts []string
+	extra  []string // copied as is
+}
+
+var mergeTables = []mergeTable{
+	{
+		name:   "snapshots",
+		key:    []string{"timestamp", "source", "interface", "host"},
+		counts: []string{"sent", "received", "total"},
+	},
+	{
+		name:   "unit_snapshots",
+		key:    []string{"timestamp", "source", "host", "unit"},
+		counts: []string{"sent", "received", "total"},
+	},
+	{
+		name:   "endpoint_snapshots",
+		key:    []string{"timestamp", "source", "host", "protocol", "address", "port"},
+		counts: []string{"sent", "received", "total"},
+		extra:  []string{"hostname"},
+	},
+	{
+		name: "protocol_snapshots",
+		key:  []string{"timestamp", "source", "host"},
+		counts: []string{
+			"tcp_in_segs", "tcp_out_segs", "tcp_retrans_segs", "tcp_syn_retrans", "tcp_timeouts", "tcp_estab_resets", "tcp_out_rsts", "tcp_in_errs",
+			"udp_in_datagrams", "udp_out_datagrams", "udp_no_ports", "udp_in_errors", "udp_rcvbuf_errors", "udp_sndbuf_errors",
+			"icmp_in_msgs", "icmp_out_msgs", "icmp_in_errors",
+		},
+	},
+	{
+		name:   "subnet_snapshots",
+		key:    []string{"timestamp", "source", "host", "subnet"},
+		counts: []string{"sent", "received", "total"},
+	},
+	{
+		name:   "protocol_usage_snapshots",
+		key:    []string{"timestamp", "source", "host", "protocol"},
+		counts: []string{"sent", "received", "total"},
+	},
+}
+
+func (t mergeTable) columns() []string {
+	return append(append(append([]string{}, t.key...), t.counts...), t.extra...)
+}
+
+// The condition matching the columns of two aliases.
+func match(a, b string, columns []string) string {
+	conditions := make([]string, len(columns))
+
+	for i, column := range columns {
+		conditions[i] = a + "." + column + " = " + b + "." + column
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// The values of columns older databases lack.
+var columnDefaults = map[string]string{
+	"source":    "'" + LocalSource + "'",
+	"interface": "''",
+	"host":      "''",
+	"hostname":  "''",
+}
+
+// The outcome of merging a database into a table. Every row of the database
+// is either inserted, a duplicate, a conflict or an overlap.
+type MergeStat struct {
+	Table      string
+	Inserted   int
+	Duplicates int
+	Conflicts  int
+	Overlaps   int
+}
+
+// A SeriesRange is a run of snapshots of a single source, host and interface.
+type SeriesRange struct {
+	Source    string
+	Host      string
+	Interface string
+	From, To  int64 // the first and last timestamp
+	Snapshots int
+}
+
+// What merging a database did, or would do.
+type MergeReport struct {
+	Path      string
+	Tables    []MergeStat
+	Conflicts []SeriesRange // snapshots stored with other counts already
+	Overlaps  []SeriesRange // snapshots of intervals another capture covered already
+}
+
+type MergeOptions struct {
+	Host   string // the host of rows without one
+	Source string // the source of the local rows
+	DryRun bool   // report what would be merged, and roll it back
+}
+
+// Merge the snapshots of other SQLite databases into db, which must be SQLite
+// and migrated. The databases are only read, their rows are tagged as the
+// options tell and inserted unless they are stored already: duplicates with
+// equal counts, conflicts with other counts, and overlaps when the interval a
+// snapshot covers, from the previous one of its series, overlaps that of a
+// stored snapshot that is not in the merged database, i.e. another capture
+// covered it. The databases are merged in order, all or none.
+func Merge(ctx context.Context, db *sql.DB, paths []string, opts MergeOptions) ([]MergeReport, error) {
+	conn, err := db.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	reports := make([]MergeReport, len(paths))
+
+	defer func() {
+		for i := range paths {
+			for _, t := range mergeTables {
+				conn.ExecContext(context.Background(), "DROP TABLE IF EXISTS temp."+stagedName(t, i))
+			}
+
+			conn.ExecContext(context.Background(), "DROP TABLE IF EXISTS temp."+spansName(i))
+		}
+	}()
+
+	// Databases can't be attached in a transaction, so they are all staged
+	// in temporary tables first.
+	for i, path := range paths {
+		reports[i].Path = path
+
+		if err = stage(ctx, conn, i, path, opts); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	for i := range paths {
+		if err = mergeStaged(ctx, tx, i, &reports[i]); err != nil {
+			return nil, fmt.Errorf("failed to merge %s: %w", paths[i], err)
+		}
+	}
+
+	if opts.DryRun {
+		return reports, nil
+	}
+
+	return reports, tx.Commit()
+}
+
+func stagedName(t mergeTable, i int) string {
+	return fmt.Sprintf("merge_%d_%s", i, t.name)
+}
+
+func spansName(i int) string {
+	return fmt.Sprintf("merge_%d_spans", i)
+}
+
+// Copy the rows of a database into temporary tables, tagged and with a status
+// column, filling the columns it lacks in.
+func stage(ctx context.Context, conn *sql.Conn, i int, path string, opts MergeOptions) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS merged", "file:"+path+"?mode=ro"); err != nil {
+		return err
+	}
+
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE merged")
+
+	for _, t := range mergeTables {
+		existing, err := mergedColumns(ctx, conn, t.name)
+
+		if err != nil {
+			return err
+		}
+
+		selected := make([]string, 0, len(t.columns()))
+		var args []interface{}
+
+		for _, column := range t.columns() {
+			value := column
+
+			if !existing[column] {
+				value = columnDefaults[column]
+
+				if value == "" {
+					value = "0"
+				}
+			}
+
+			switch {
+			case column == "host" && opts.Host != "":
+				value = fmt.Sprintf("CASE WHEN %s = '' THEN ? ELSE %s END", value, value)
+				args = append(args, opts.Host)
+			case column == "source" && opts.Source != "":
+				value = fmt.Sprintf("CASE WHEN %s = '%s' THEN ? ELSE %s END", value, LocalSource, value)
+				args = append(args, opts.Source)
+			}
+
+			selected = append(selected, value+" AS "+column)
+		}
+
+		from := " FROM merged." + t.name
+
+		// Tables added since the database was created have nothing to merge.
+		if len(existing) == 0 {
+			from = " WHERE 0"
+		}
+
+		name := stagedName(t, i)
+
+		query := `CREATE TEMP TABLE ` + name + ` AS
+		SELECT ` + strings.Join(selected, ", ") + `, '' AS status` + from
+
+		if _, err = conn.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", t.name, err)
+		}
+
+		index := `CREATE INDEX temp.` + name + `_key ON ` + name + ` (` + strings.Join(t.key, ", ") + `)`
+
+		if _, err = conn.ExecContext(ctx, index); err != nil {
+			return fmt.Errorf("failed to index %s: %w", t.name, err)
+		}
+	}
+
+	return nil
+}
+
+// The columns of a table of the attached database, none if it does not exist.
+func mergedColumns(ctx context.Context, conn *sql.Conn, table string) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT name FROM pragma_table_info(?, 'merged')", table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	columns := map[string]bool{}
+
+	for rows.Next() {
+		var name string
+
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
+
+// Classify the staged rows of a database against those stored so far, and
+// insert the new ones.
+func mergeStaged(ctx context.Context, tx *sql.Tx, i int, report *MergeReport) error {
+	if err := stageSpans(ctx, tx, i); err != nil {
+		return err
+	}
+
+	for _, t := range mergeTables {
+		name := stagedName(t, i)
+
+		// The rows of other tables are stored along with the sum of all
+		// interfaces.
+		iface := "''"
+
+		if t.name == "snapshots" {
+			iface = "s.interface"
+		}
+
+		classify := `UPDATE ` + name + ` AS s SET status = CASE
+			WHEN EXISTS (SELECT 1 FROM main.` + t.name + ` m WHERE ` + match("m", "s", t.key) + ` AND ` + match("m", "s", t.counts) + `) THEN 'duplicate'
+			WHEN EXISTS (SELECT 1 FROM main.` + t.name + ` m WHERE ` + match("m", "s", t.key) + `) THEN 'conflict'
+			WHEN EXISTS (
+				SELECT 1 FROM ` + spansName(i) + ` p
+				WHERE p.overlap AND p.timestamp = s.timestamp AND p.source = s.source AND p.interface = ` + iface + ` AND p.host = s.host
+			) THEN 'overlap'
+			ELSE 'new'
+		END`
+
+		if _, err := tx.ExecContext(ctx, classify); err != nil {
+			return fmt.Errorf("failed to compare %s: %w", t.name, err)
+		}
+
+		stat, err := mergeStat(ctx, tx, t, name)
+
+		if err != nil {
+			return err
+		}
+
+		report.Tables = append(report.Tables, stat)
+
+		if t.name == "snapshots" {
+			if report.Conflicts, err = seriesRanges(ctx, tx, name, "conflict"); err != nil {
+				return err
+			}
+
+			if report.Overlaps, err = seriesRanges(ctx, tx, name, "overlap"); err != nil {
+				return err
+			}
+		}
+
+		columns := strings.Join(t.columns(), ", ")
+
+		// Databases stored before snapshots were unique may repeat rows.
+		insert := `INSERT OR IGNORE INTO main.` + t.name + ` (` + columns + `)
+		SELECT ` + columns + ` FROM ` + name + ` WHERE status = 'new'`
+
+		if _, err = tx.ExecContext(ctx, insert); err != nil {
+			return fmt.Errorf("failed to insert into %s: %w", t.name, err)
+		}
+	}
+
+	return nil
+}
+
+// The interval a series of snapshots covers at a timestamp, when its spacing is
+// unknown: the default capture time.
+const defaultSpacing = 3600
+
+// Stage the intervals the staged snapshots cover, and flag those another
+// capture of their series covered already. A snapshot covers the interval from
+// the previous one of its series up to its timestamp; the first one of a
+// series is assumed to be as far from the previous as from the next.
+func stageSpans(ctx context.Context, tx *sql.Tx, i int) error {
+	spans := spansName(i)
+	series := []string{"source", "interface", "host"}
+
+	create := `CREATE TEMP TABLE ` + spans + ` AS
+	SELECT source, interface, host, timestamp,
+		timestamp - COALESCE(timestamp - LAG(timestamp) OVER w, LEAD(timestamp) OVER w - timestamp, ?) AS since,
+		NULL AS next,
+		0 AS overlap
+	FROM (SELECT DISTINCT source, interface, host, timestamp FROM ` + stagedName(mergeTables[0], i) + `)
+	WINDOW w AS (PARTITION BY source, interface, host ORDER BY timestamp)`
+
+	if _, err := tx.ExecContext(ctx, create, defaultSpacing); err != nil {
+		return fmt.Errorf("failed to stage intervals: %w", err)
+	}
+
+	index := `CREATE INDEX temp.` + spans + `_key ON ` + spans + ` (source, interface, host, timestamp)`
+
+	if _, err := tx.ExecContext(ctx, index); err != nil {
+		return fmt.Errorf("failed to index intervals: %w", err)
+	}
+
+	// The first stored snapshot after each staged one.
+	next := `UPDATE ` + spans + ` AS s SET next = (
+		SELECT MIN(m.timestamp) FROM main.snapshots m WHERE ` + match("m", "s", series) + ` AND m.timestamp > s.timestamp
+	)`
+
+	if _, err := tx.ExecContext(ctx, next); err != nil {
+		return fmt.Errorf("failed to compare intervals: %w", err)
+	}
+
+	// Two intervals overlap if either ends within the other: a stored snapshot
+	// of another capture within the interval, or the next stored snapshot
+	// being of another capture whose interval started before the end of this
+	// one.
+	overlap := `UPDATE ` + spans + ` AS s SET overlap = 1
+	WHERE EXISTS (
+		SELECT 1 FROM main.snapshots m
+		WHERE ` + match("m", "s", series) + ` AND m.timestamp > s.since AND m.timestamp <= s.timestamp
+		AND NOT EXISTS (SELECT 1 FROM ` + spans + ` o WHERE ` + match("o", "m", series) + ` AND o.timestamp = m.timestamp)
+	) OR (
+		s.next IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM ` + spans + ` o WHERE ` + match("o", "s", series) + ` AND o.timestamp = s.next)
+		AND COALESCE(
+			(SELECT MAX(m.timestamp) FROM main.snapshots m WHERE ` + match("m", "s", series) + ` AND m.timestamp < s.next),
+			s.next - COALESCE((SELECT MIN(m.timestamp) FROM main.snapshots m WHERE ` + match("m", "s", series) + ` AND m.timestamp > s.next) - s.next, ?)
+		) < s.timestamp
+	)`
+
+	if _, err := tx.ExecContext(ctx, overlap, defaultSpacing); err != nil {
+		return fmt.Errorf("failed to compare intervals: %w", err)
+	}
+
+	return nil
+}
+
+func mergeStat(ctx context.Context, tx *sql.Tx, t mergeTable, name string) (MergeStat, error) {
+	stat := MergeStat{Table: t.name}
+
+	rows, err := tx.QueryContext(ctx, `SELECT status, COUNT(*) FROM `+name+` GROUP BY status`)
+
+	if err != nil {
+		return stat, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var n int
+
+		if err = rows.Scan(&status, &n); err != nil {
+			return stat, err
+		}
+
+		switch status {
+		case "new":
+			stat.Inserted = n
+		case "duplicate":
+			stat.Duplicates = n
+		case "conflict":
+			stat.Conflicts = n
+		case "overlap":
+			stat.Overlaps = n
+		}
+	}
+
+	return stat, rows.Err()
+}
+
+func seriesRanges(ctx context.Context, tx *sql.Tx, name, status string) ([]SeriesRange, error) {
+	query := `SELECT source, host, interface, MIN(timestamp), MAX(timestamp), COUNT(*)
+	FROM ` + name + `
+	WHERE status = ?
+	GROUP BY source, host, interface
+	ORDER BY source, host, interface`
+
+	rows, err := tx.QueryContext(ctx, query, status)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ranges []SeriesRange
+
+	for rows.Next() {
+		var r SeriesRange
+
+		if err = rows.Scan(&r.Source, &r.Host, &r.Interface, &r.From, &r.To, &r.Snapshots); err != nil {
+			return nil, err
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, rows.Err()
+}
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// A database file holding the given snapshots, with the schema of the first
+// releases if legacy.
+func newMergedDatabase(t *testing.T, legacy bool, snapshots ...Snapshot) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "monitor.db")
+
+	db, err := sql.Open("sqlite3", path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if legacy {
+		if _, err = db.ExecContext(ctx, schema[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range snapshots {
+			if _, err = db.ExecContext(ctx, `INSERT INTO snapshots VALUES (?, ?, ?, ?)`, s.Timestamp, s.Sent, s.Received, s.Total); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return path
+	}
+
+	if _, err = Migrate(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = NewSnapshotModel(db).InsertSnapshots(ctx, snapshots); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = NewSnapshotModel(db).InsertUnits(ctx, 3600, []UnitStat{{Unit: "nginx.service", Stat: Stat{Sent: 1, Received: 1, Total: 2}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestMerge(t *testing.T) {
+	m := newTestModel(t)
+	db := m.db
+	ctx := context.Background()
+
+	stat := Stat{Sent: 1, Received: 2, Total: 3}
+
+	err := m.InsertSnapshots(ctx, []Snapshot{
+		{Timestamp: 3600, Host: "laptop", Stat: stat},
+		{Timestamp: 7200, Host: "laptop", Stat: stat},
+		{Timestamp: 3 * 3600, Host: "other", Stat: stat},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Synced up to 7200 before going offline, then captured an hour the
+	// server had from another capture.
+	offline := newMergedDatabase(t, false,
+		Snapshot{Timestamp: 3600, Host: "laptop", Stat: stat},
+		Snapshot{Timestamp: 7200, Host: "laptop", Stat: Stat{Sent: 5, Received: 5, Total: 10}},
+		Snapshot{Timestamp: 7200 + 1800, Host: "laptop", Stat: stat},
+		Snapshot{Timestamp: 3*3600 + 600, Host: "other", Stat: stat},
+		Snapshot{Timestamp: 4 * 3600, Host: "laptop", Stat: stat},
+	)
+
+	// Captured before hosts were tagged.
+	legacy := newMergedDatabase(t, true,
+		Snapshot{Timestamp: 5 * 3600, Stat: stat},
+		Snapshot{Timestamp: 6 * 3600, Stat: stat},
+	)
+
+	reports, err := Merge(ctx, db, []string{offline, legacy, offline}, MergeOptions{Host: "laptop", DryRun: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := count(t, db); got != 3 {
+		t.Fatalf("got %d snapshots, expected a dry run to insert none", got)
+	}
+
+	expected := []MergeStat{
+		{Table: "snapshots", Inserted: 2, Duplicates: 1, Conflicts: 1, Overlaps: 1},
+		{Table: "snapshots", Inserted: 2},
+		{Table: "snapshots", Duplicates: 3, Conflicts: 1, Overlaps: 1},
+	}
+
+	for i, report := range reports {
+		if report.Tables[0] != expected[i] {
+			t.Errorf("%d: got %+v, expected %+v", i, report.Tables[0], expected[i])
+		}
+	}
+
+	if r := reports[0]; len(r.Overlaps) != 1 || r.Overlaps[0].Host != "other" || r.Overlaps[0].From != 3*3600+600 || len(r.Conflicts) != 1 || r.Conflicts[0].To != 7200 {
+		t.Errorf("got %+v and %+v, expected the other host's hour to overlap and 7200 to conflict", r.Overlaps, r.Conflicts)
+	}
+
+	if r := reports[0].Tables[1]; r.Table != "unit_snapshots" || r.Inserted != 1 {
+		t.Errorf("got %+v, expected the unit snapshot to be merged", r)
+	}
+
+	if _, err = Merge(ctx, db, []string{offline, legacy}, MergeOptions{Host: "laptop"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := count(t, db); got != 7 {
+		t.Errorf("got %d snapshots, expected 7", got)
+	}
+
+	tagged := newTestModel(t)
+
+	if _, err = Merge(ctx, tagged.db, []string{legacy}, MergeOptions{Host: "laptop", Source: "home"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+
+	if err = tagged.db.QueryRow(`SELECT COUNT(*) FROM snapshots WHERE source = 'home' AND host = 'laptop'`).Scan(&n); err != nil || n != 2 {
+		t.Errorf("got %d, %v, expected the legacy snapshots to be tagged", n, err)
+	}
+
+	if _, err = Merge(ctx, db, []string{filepath.Join(t.TempDir(), "missing.db")}, MergeOptions{}); err == nil {
+		t.Error("expected a missing database to fail")
+	}
+}
+
+func TestMergeOverlaps(t *testing.T) {
+	ctx := context.Background()
+	stat := Stat{Sent: 1, Received: 2, Total: 3}
+
+	// A capture of the laptop with snapshots at the given minutes of the day.
+	capture := func(minutes ...int64) []Snapshot {
+		snapshots := make([]Snapshot, len(minutes))
+
+		for i, minute := range minutes {
+			snapshots[i] = Snapshot{Timestamp: minute * 60, Host: "laptop", Stat: stat}
+		}
+
+		return snapshots
+	}
+
+	table := []struct {
+		name              string
+		stored, merged    []Snapshot
+		inserted, overlap int
+	}{{
+		// Hourly from 8:30 to 10:30, then hourly from 11:10: the first merged
+		// snapshot covers 10:10 to 11:10.
+		name:     "unaligned hours",
+		stored:   capture(8*60+30, 9*60+30, 10*60+30),
+		merged:   capture(11*60+10, 12*60+10),
+		inserted: 1,
+		overlap:  1,
+	}, {
+		name:     "unaligned hours merged the other way",
+		stored:   capture(11*60+10, 12*60+10),
+		merged:   capture(8*60+30, 9*60+30, 10*60+30),
+		inserted: 2,
+		overlap:  1,
+	}, {
+		name:     "hourly within a longer interval",
+		stored:   capture(9*60, 11*60),
+		merged:   capture(9*60+50, 10*60+50),
+		inserted: 0,
+		overlap:  2,
+	}, {
+		// Every 5 minutes up to 10:25, then from 10:35 on in the same hour.
+		name:     "minutes apart",
+		stored:   capture(10*60+5, 10*60+10, 10*60+15, 10*60+20, 10*60+25),
+		merged:   capture(10*60+35, 10*60+40, 10*60+45, 10*60+50, 10*60+55),
+		inserted: 5,
+	}, {
+		name:     "minutes apart merged the other way",
+		stored:   capture(10*60+35, 10*60+40, 10*60+45, 10*60+50, 10*60+55),
+		merged:   capture(10*60+5, 10*60+10, 10*60+15, 10*60+20, 10*60+25),
+		inserted: 5,
+	}}
+
+	for _, v := range table {
+		m := newTestModel(t)
+
+		if err := m.InsertSnapshots(ctx, v.stored); err != nil {
+			t.Fatal(err)
+		}
+
+		reports, err := Merge(ctx, m.db, []string{newMergedDatabase(t, false, v.merged...)}, MergeOptions{})
+
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+
+		if stat := reports[0].Tables[0]; stat.Inserted != v.inserted || stat.Overlaps != v.overlap {
+			t.Errorf("%s: got %+v, expected %d inserted and %d overlaps", v.name, stat, v.inserted, v.overlap)
+		}
+	}
+}
+
+func count(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+
+	if err := db.QueryRow(`SELECT COUNT(*) FROM snapshots`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The periods snapshots can be summed up by.
+const (
+	PeriodHour  = "hour"
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// The local time layouts of each period, in SQLite and Go notation.
+var periodLayouts = map[string][2]string{
+	PeriodHour:  {"%Y-%m-%dT%H:00:00", "2006-01-02T15:04:05"},
+	PeriodDay:   {"%Y-%m-%d", "2006-01-02"},
+	PeriodMonth: {"%Y-%m", "2006-01"},
+}
+
+// A PeriodStat sums up the snapshots of one hour, day or month.
+type PeriodStat struct {
+	Period    string // the start of the period in the model's time zone, e.g. 2022-08-01 for a day
+	Snapshots int
+	Stat
+}
+
+// The Go layout of a period's start, to parse PeriodStat.Period with.
+func PeriodLayout(period string) (string, bool) {
+	layouts, ok := periodLayouts[period]
+
+	return layouts[1], ok
+}
+
+// Sum up the snapshots taken in [from, to), unix seconds, by period in the
+// model's time zone. A non-nil iface only considers the snapshots of that
+// interface, "" being the sum of all, instead of the model's interface.
+func (m *SnapshotModel) GetPeriodStats(ctx context.Context, from, to int64, period string, iface *string) ([]PeriodStat, error) {
+	layouts, ok := periodLayouts[period]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown period %q", period)
+	}
+
+	span, err := m.timestampSpan(ctx, "snapshots")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if span == nil {
+		return nil, ErrNoRows
+	}
+
+	// The offset only matters where there are snapshots.
+	offsetFrom, offsetTo := span.From.Unix(), span.To.Unix()+1
+
+	if from > offsetFrom {
+		offsetFrom = from
+	}
+
+	if to < offsetTo {
+		offsetTo = to
+	}
+
+	if iface != nil {
+		m = m.WithInterface(*iface)
+	}
+
+	filter, filterArgs := m.snapshotFilter()
+	offset, offsetArgs := zoneOffset(offsetFrom, offsetTo, m.location)
+
+	query := `SELECT strftime('` + layouts[0] + `', timestamp + ` + offset + `, 'unixepoch') AS period,
+		COUNT(*), SUM(sent), SUM(received), SUM(total)
+	FROM snapshots
+	WHERE timestamp >= ? AND timestamp < ? AND ` + filter + `
+	GROUP BY period
+	ORDER BY period`
+
+	args := append(append(offsetArgs, from, to), filterArgs...)
+
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.db.QueryContext(timeout, query, args...)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrTimedOut
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var stats []PeriodStat
+
+	for rows.Next() {
+		var s PeriodStat
+
+		if err = rows.Scan(&s.Period, &s.Snapshots, &s.Stat.Sent, &s.Stat.Received, &s.Stat.Total); err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPeriodStats(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.Local)
+
+	snapshots := []*Snapshot{
+		{Timestamp: day.Add(time.Hour).Unix(), Stat: Stat{Sent: 1, Received: 2, Total: 3}},
+		{Timestamp: day.Add(time.Hour + time.Minute).Unix(), Stat: Stat{Sent: 1, Received: 2, Total: 3}},
+		{Timestamp: day.Add(3 * time.Hour).Unix(), Stat: Stat{Sent: 10, Received: 10, Total: 20}},
+		{Timestamp: day.Add(3 * time.Hour).Unix(), Source: "snmp:core", Interface: "Gi0/1", Stat: Stat{Sent: 5, Received: 5, Total: 10}},
+		{Timestamp: day.AddDate(0, 0, 1).Unix(), Stat: Stat{Sent: 7, Received: 7, Total: 14}},
+	}
+
+	for _, s := range snapshots {
+		if err := m.Insert(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	end := day.AddDate(0, 0, 1).Unix()
+
+	stats, err := m.GetPeriodStats(ctx, day.Unix(), end, PeriodHour, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 || stats[0].Period != "2022-08-01T01:00:00" || stats[0].Snapshots != 2 || stats[0].Total != 6 || stats[1].Total != 20 {
+		t.Errorf("got %+v, expected 6 bytes at 01:00 and 20 at 03:00, without the polled interface", stats)
+	}
+
+	all := ""
+
+	stats, err = m.GetPeriodStats(ctx, day.Unix(), end, PeriodDay, &all)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 || stats[0].Period != "2022-08-01" || stats[0].Total != 26 {
+		t.Errorf("got %+v, expected the sum of all interfaces only", stats)
+	}
+
+	stats, err = m.GetPeriodStats(ctx, 0, day.AddDate(1, 0, 0).Unix(), PeriodMonth, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 || stats[0].Period != "2022-08" || stats[0].Snapshots != 4 || stats[0].Total != 40 {
+		t.Errorf("got %+v, expected the sums of August", stats)
+	}
+
+	if layout, _ := PeriodLayout(PeriodHour); layout != "2006-01-02T15:04:05" {
+		t.Errorf("got %s, expected the hour layout", layout)
+	}
+
+	if _, err = m.GetPeriodStats(ctx, end+1, end+2, PeriodDay, nil); err != ErrNoRows {
+		t.Errorf("got %v, expected ErrNoRows", err)
+	}
+
+	if _, err = m.GetPeriodStats(ctx, day.Unix(), end, "week", nil); err == nil {
+		t.Error("expected an error for an unknown period")
+	}
+}
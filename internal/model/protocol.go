@@ -0,0 +1,125 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type TCPStat struct {
+	InSegs, OutSegs         uint64
+	RetransSegs, SynRetrans uint64
+	Timeouts                uint64
+	EstabResets, OutRsts    uint64
+	InErrs                  uint64
+}
+
+type UDPStat struct {
+	InDatagrams, OutDatagrams  uint64
+	NoPorts, InErrors          uint64
+	RcvbufErrors, SndbufErrors uint64
+}
+
+type ICMPStat struct {
+	InMsgs, OutMsgs, InErrors uint64
+}
+
+// A ProtocolStat holds packet counts per protocol, not bytes.
+type ProtocolStat struct {
+	TCP  TCPStat
+	UDP  UDPStat
+	ICMP ICMPStat
+}
+
+const protocolColumns = `tcp_in_segs, tcp_out_segs, tcp_retrans_segs, tcp_syn_retrans, tcp_timeouts, tcp_estab_resets, tcp_out_rsts, tcp_in_errs,
+	udp_in_datagrams, udp_out_datagrams, udp_no_ports, udp_in_errors, udp_rcvbuf_errors, udp_sndbuf_errors,
+	icmp_in_msgs, icmp_out_msgs, icmp_in_errors`
+
+const protocolSums = `SUM(tcp_in_segs), SUM(tcp_out_segs), SUM(tcp_retrans_segs), SUM(tcp_syn_retrans), SUM(tcp_timeouts), SUM(tcp_estab_resets), SUM(tcp_out_rsts), SUM(tcp_in_errs),
+	SUM(udp_in_datagrams), SUM(udp_out_datagrams), SUM(udp_no_ports), SUM(udp_in_errors), SUM(udp_rcvbuf_errors), SUM(udp_sndbuf_errors),
+	SUM(icmp_in_msgs), SUM(icmp_out_msgs), SUM(icmp_in_errors)`
+
+func (p *ProtocolStat) fields() []interface{} {
+	return []interface{}{
+		&p.TCP.InSegs, &p.TCP.OutSegs, &p.TCP.RetransSegs, &p.TCP.SynRetrans, &p.TCP.Timeouts, &p.TCP.EstabResets, &p.TCP.OutRsts, &p.TCP.InErrs,
+		&p.UDP.InDatagrams, &p.UDP.OutDatagrams, &p.UDP.NoPorts, &p.UDP.InErrors, &p.UDP.RcvbufErrors, &p.UDP.SndbufErrors,
+		&p.ICMP.InMsgs, &p.ICMP.OutMsgs, &p.ICMP.InErrors,
+	}
+}
+
+func (m *SnapshotModel) InsertProtocols(ctx context.Context, timestamp int64, p ProtocolStat) error {
+	query := `INSERT INTO protocol_snapshots (timestamp, host, ` + protocolColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{timestamp, m.host}
+
+	for _, field := range p.fields() {
+		args = append(args, *field.(*uint64))
+	}
+
+	_, err := m.db.ExecContext(timeout, query, args...)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrTimedOut
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (m *SnapshotModel) GetProtocolStatByDate(ctx context.Context, date string) (ProtocolStat, error) {
+	filter, filterArgs := m.scopeFilter()
+	period, periodArgs := dateCondition(date, m.location)
+
+	query := `SELECT ` + protocolSums + `
+	FROM protocol_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	HAVING COUNT(*) > 0`
+
+	return m.queryProtocolStat(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) GetProtocolStatByMonth(ctx context.Context, month string) (ProtocolStat, error) {
+	period, periodArgs, err := m.monthCondition(ctx, "protocol_snapshots", month)
+
+	if err != nil {
+		return ProtocolStat{}, err
+	}
+
+	filter, filterArgs := m.scopeFilter()
+
+	query := `SELECT ` + protocolSums + `
+	FROM protocol_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	HAVING COUNT(*) > 0`
+
+	return m.queryProtocolStat(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) queryProtocolStat(ctx context.Context, query string, args ...interface{}) (ProtocolStat, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	var p ProtocolStat
+
+	if err := m.db.QueryRowContext(timeout, query, args...).Scan(p.fields()...); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return p, ErrTimedOut
+		}
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return p, ErrNoRows
+		}
+
+		return p, err
+	}
+
+	return p, nil
+}
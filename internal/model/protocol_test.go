@@ -0,0 +1,40 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProtocolStats(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		err := m.InsertProtocols(ctx, now.Unix()+int64(i), ProtocolStat{
+			TCP:  TCPStat{InSegs: 100, OutSegs: 80, RetransSegs: 2, OutRsts: 1},
+			UDP:  UDPStat{InDatagrams: 10, RcvbufErrors: 3},
+			ICMP: ICMPStat{OutMsgs: 1},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := m.GetProtocolStatByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.TCP.InSegs != 200 || p.TCP.RetransSegs != 4 || p.TCP.OutRsts != 2 || p.UDP.RcvbufErrors != 6 || p.ICMP.OutMsgs != 2 {
+		t.Errorf("got %+v, expected every counter to be summed", p)
+	}
+
+	if _, err = m.GetProtocolStatByDate(ctx, "1999-01-01"); err != ErrNoRows {
+		t.Errorf("got %v, expected ErrNoRows", err)
+	}
+}
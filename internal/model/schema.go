@@ -0,0 +1,242 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS snapshots (
+		timestamp INTEGER NOT NULL,
+		sent      INTEGER NOT NULL,
+		received  INTEGER NOT NULL,
+		total     INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS unit_snapshots (
+		timestamp INTEGER NOT NULL,
+		unit      TEXT    NOT NULL,
+		sent      INTEGER NOT NULL,
+		received  INTEGER NOT NULL,
+		total     INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS endpoint_snapshots (
+		timestamp INTEGER NOT NULL,
+		protocol  TEXT    NOT NULL,
+		address   TEXT    NOT NULL,
+		port      INTEGER NOT NULL,
+		hostname  TEXT    NOT NULL DEFAULT '',
+		sent      INTEGER NOT NULL,
+		received  INTEGER NOT NULL,
+		total     INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS protocol_snapshots (
+		timestamp         INTEGER NOT NULL,
+		tcp_in_segs       INTEGER NOT NULL,
+		tcp_out_segs      INTEGER NOT NULL,
+		tcp_retrans_segs  INTEGER NOT NULL,
+		tcp_syn_retrans   INTEGER NOT NULL,
+		tcp_timeouts      INTEGER NOT NULL,
+		tcp_estab_resets  INTEGER NOT NULL,
+		tcp_out_rsts      INTEGER NOT NULL,
+		tcp_in_errs       INTEGER NOT NULL,
+		udp_in_datagrams  INTEGER NOT NULL,
+		udp_out_datagrams INTEGER NOT NULL,
+		udp_no_ports      INTEGER NOT NULL,
+		udp_in_errors     INTEGER NOT NULL,
+		udp_rcvbuf_errors INTEGER NOT NULL,
+		udp_sndbuf_errors INTEGER NOT NULL,
+		icmp_in_msgs      INTEGER NOT NULL,
+		icmp_out_msgs     INTEGER NOT NULL,
+		icmp_in_errors    INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS subnet_snapshots (
+		timestamp INTEGER NOT NULL,
+		source    TEXT    NOT NULL DEFAULT 'local',
+		subnet    TEXT    NOT NULL,
+		sent      INTEGER NOT NULL,
+		received  INTEGER NOT NULL,
+		total     INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS protocol_usage_snapshots (
+		timestamp INTEGER NOT NULL,
+		source    TEXT    NOT NULL DEFAULT 'local',
+		host      TEXT    NOT NULL DEFAULT '',
+		protocol  TEXT    NOT NULL,
+		sent      INTEGER NOT NULL,
+		received  INTEGER NOT NULL,
+		total     INTEGER NOT NULL
+	)`,
+}
+
+// Columns added after their table was first released.
+var columns = []struct {
+	table, name, definition string
+}{
+	{"snapshots", "source", "TEXT NOT NULL DEFAULT 'local'"},
+	{"unit_snapshots", "source", "TEXT NOT NULL DEFAULT 'local'"},
+	{"endpoint_snapshots", "source", "TEXT NOT NULL DEFAULT 'local'"},
+	{"protocol_snapshots", "source", "TEXT NOT NULL DEFAULT 'local'"},
+	{"snapshots", "interface", "TEXT NOT NULL DEFAULT ''"},
+	{"snapshots", "host", "TEXT NOT NULL DEFAULT ''"},
+	{"unit_snapshots", "host", "TEXT NOT NULL DEFAULT ''"},
+	{"endpoint_snapshots", "host", "TEXT NOT NULL DEFAULT ''"},
+	{"protocol_snapshots", "host", "TEXT NOT NULL DEFAULT ''"},
+	{"subnet_snapshots", "host", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// Indexes created once every column exists.
+var indexes = []string{
+	// Covers the queries summing up periods, and replaces the index on
+	// (timestamp, source) only.
+	`CREATE INDEX IF NOT EXISTS snapshots_timestamp_totals ON snapshots (timestamp, source, host, interface, sent, received, total)`,
+	`DROP INDEX IF EXISTS snapshots_timestamp_source`,
+	`CREATE INDEX IF NOT EXISTS unit_snapshots_timestamp_source ON unit_snapshots (timestamp, source)`,
+	`CREATE INDEX IF NOT EXISTS endpoint_snapshots_timestamp_source ON endpoint_snapshots (timestamp, source)`,
+	`CREATE INDEX IF NOT EXISTS protocol_snapshots_timestamp_source ON protocol_snapshots (timestamp, source)`,
+	`CREATE INDEX IF NOT EXISTS subnet_snapshots_timestamp_source ON subnet_snapshots (timestamp, source)`,
+	`CREATE INDEX IF NOT EXISTS protocol_usage_snapshots_timestamp_source ON protocol_usage_snapshots (timestamp, source)`,
+}
+
+// What Migrate changed in the rows stored before it.
+type MigrateReport struct {
+	// Snapshots stored more than once, e.g. batches an agent sent again,
+	// deleted.
+	Duplicates int
+	// Snapshots stored at the same timestamp as another of their source,
+	// interface and host with other counts, e.g. two monitors sharing the
+	// database, added to the first one.
+	Summed int
+}
+
+// Create the tables the models rely on, add the columns they miss and index
+// them.
+func Migrate(ctx context.Context, db *sql.DB) (MigrateReport, error) {
+	var report MigrateReport
+
+	for _, stmt := range schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return report, fmt.Errorf("failed to migrate schema: %w", err)
+		}
+	}
+
+	for _, column := range columns {
+		exists, err := hasColumn(ctx, db, column.table, column.name)
+
+		if err != nil {
+			return report, fmt.Errorf("failed to inspect table %s: %w", column.table, err)
+		}
+
+		if exists {
+			continue
+		}
+
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.table, column.name, column.definition)
+
+		if _, err = db.ExecContext(ctx, stmt); err != nil {
+			return report, fmt.Errorf("failed to add column %s.%s: %w", column.table, column.name, err)
+		}
+	}
+
+	for _, stmt := range indexes {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return report, fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	return uniqueSnapshots(ctx, db)
+}
+
+// Batches may be delivered more than once, a snapshot is stored once per
+// timestamp, source, interface and host. The snapshots stored before are
+// folded first: copies are deleted, and those with other counts are added to
+// the first of their key, so that no usage is lost. Merges look up the
+// neighbours of a snapshot in its series with the index.
+func uniqueSnapshots(ctx context.Context, db *sql.DB) (MigrateReport, error) {
+	var report MigrateReport
+	var exists bool
+
+	query := `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'index' AND name = 'snapshots_key'`
+
+	if err := db.QueryRowContext(ctx, query).Scan(&exists); err != nil {
+		return report, fmt.Errorf("failed to inspect indexes: %w", err)
+	}
+
+	if exists {
+		return report, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return report, err
+	}
+
+	defer tx.Rollback()
+
+	duplicates := `DELETE FROM snapshots WHERE rowid NOT IN (
+		SELECT MIN(rowid) FROM snapshots GROUP BY timestamp, source, interface, host, sent, received, total
+	)`
+
+	result, err := tx.ExecContext(ctx, duplicates)
+
+	if err != nil {
+		return report, fmt.Errorf("failed to delete duplicate snapshots: %w", err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	report.Duplicates = int(deleted)
+
+	sum := `UPDATE snapshots AS s SET (sent, received, total) = (
+		SELECT SUM(o.sent), SUM(o.received), SUM(o.total) FROM snapshots o
+		WHERE o.timestamp = s.timestamp AND o.source = s.source AND o.interface = s.interface AND o.host = s.host
+	)
+	WHERE rowid IN (SELECT MIN(rowid) FROM snapshots GROUP BY timestamp, source, interface, host HAVING COUNT(*) > 1)`
+
+	if _, err = tx.ExecContext(ctx, sum); err != nil {
+		return report, fmt.Errorf("failed to sum snapshots: %w", err)
+	}
+
+	summed := `DELETE FROM snapshots WHERE rowid NOT IN (
+		SELECT MIN(rowid) FROM snapshots GROUP BY timestamp, source, interface, host
+	)`
+
+	if result, err = tx.ExecContext(ctx, summed); err != nil {
+		return report, fmt.Errorf("failed to delete summed snapshots: %w", err)
+	}
+
+	deleted, _ = result.RowsAffected()
+	report.Summed = int(deleted)
+
+	index := `CREATE UNIQUE INDEX IF NOT EXISTS snapshots_key ON snapshots (source, interface, host, timestamp)`
+
+	if _, err = tx.ExecContext(ctx, index); err != nil {
+		return report, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	return report, tx.Commit()
+}
+
+func hasColumn(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+
+		if err = rows.Scan(&name); err != nil {
+			return false, err
+		}
+
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
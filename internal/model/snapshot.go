@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -18,8 +19,13 @@ type Stat struct {
 	Total    uint64
 }
 
+const LocalSource = "local"
+
 type Snapshot struct {
 	Timestamp int64
+	Source    string // where the snapshot comes from, LocalSource if empty
+	Interface string // the interface of a polled device, empty for the sum of all
+	Host      string // the machine that captured the snapshot, the model's host if empty
 	Stat
 }
 
@@ -36,19 +42,85 @@ type DateStat struct {
 
 type SnapshotModel struct {
 	db *sql.DB
+
+	source   string
+	host     string
+	iface    string
+	location *time.Location
 }
 
 func NewSnapshotModel(db *sql.DB) *SnapshotModel {
-	return &SnapshotModel{db: db}
+	return &SnapshotModel{db: db, location: time.Local}
+}
+
+// A model whose queries only consider rows of the given source. An empty
+// source considers every row.
+func (m *SnapshotModel) WithSource(source string) *SnapshotModel {
+	return &SnapshotModel{db: m.db, source: source, host: m.host, iface: m.iface, location: m.location}
+}
+
+// A model whose queries only consider rows captured by the given host, and
+// whose inserts are attributed to it. An empty host considers every row.
+func (m *SnapshotModel) WithHost(host string) *SnapshotModel {
+	return &SnapshotModel{db: m.db, source: m.source, host: host, iface: m.iface, location: m.location}
+}
+
+// A model whose reports bucket snapshots by the dates and hours of loc rather
+// than those of the machine running them.
+func (m *SnapshotModel) WithLocation(loc *time.Location) *SnapshotModel {
+	return &SnapshotModel{db: m.db, source: m.source, host: m.host, iface: m.iface, location: loc}
+}
+
+// A model whose reports of snapshots only consider the given interface of
+// polled devices. By default they only consider the sums of all interfaces,
+// leaving out those of devices, whose traffic is counted again at every port
+// it crosses.
+func (m *SnapshotModel) WithInterface(iface string) *SnapshotModel {
+	return &SnapshotModel{db: m.db, source: m.source, host: m.host, iface: iface, location: m.location}
+}
+
+// The time zone the model's reports bucket snapshots in.
+func (m *SnapshotModel) Location() *time.Location {
+	return m.location
+}
+
+// The condition narrowing a query down to the model's source and host, with
+// its arguments.
+func (m *SnapshotModel) scopeFilter() (string, []interface{}) {
+	return `(? = '' OR source = ?) AND (? = '' OR host = ?)`, []interface{}{m.source, m.source, m.host, m.host}
+}
+
+// The scope filter of the snapshots table, also narrowed down to the model's
+// interface.
+func (m *SnapshotModel) snapshotFilter() (string, []interface{}) {
+	filter, args := m.scopeFilter()
+
+	return filter + ` AND interface = ?`, append(args, m.iface)
+}
+
+func (m *SnapshotModel) hostOr(host string) string {
+	if host == "" {
+		return m.host
+	}
+
+	return host
+}
+
+func sourceOrLocal(source string) string {
+	if source == "" {
+		return LocalSource
+	}
+
+	return source
 }
 
 func (m *SnapshotModel) Insert(ctx context.Context, s *Snapshot) error {
-	query := `INSERT INTO snapshots (timestamp, sent, received, total) VALUES (?, ?, ?, ?)`
+	query := `INSERT INTO snapshots (timestamp, source, interface, host, sent, received, total) VALUES (?, ?, ?, ?, ?, ?, ?)`
 
 	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{s.Timestamp, s.Stat.Sent, s.Stat.Received, s.Stat.Total}
+	args := []interface{}{s.Timestamp, sourceOrLocal(s.Source), s.Interface, m.hostOr(s.Host), s.Stat.Sent, s.Stat.Received, s.Stat.Total}
 
 	_, err := m.db.ExecContext(timeout, query, args...)
 
@@ -63,30 +135,37 @@ func (m *SnapshotModel) Insert(ctx context.Context, s *Snapshot) error {
 	return nil
 }
 
+// GetStatsByMonth sums up the snapshots of a month, MM, by day, latest first,
+// each day's timestamp being its midnight in the model's time zone. The last
+// row sums up the month, its timestamp being the month number.
 func (m *SnapshotModel) GetStatsByMonth(ctx context.Context, month string) ([]Snapshot, error) {
+	ranges, err := m.monthRanges(ctx, "snapshots", month)
+
+	if err != nil || len(ranges) == 0 {
+		return nil, err
+	}
+
+	filter, filterArgs := m.snapshotFilter()
+	period, periodArgs := rangesCondition(ranges)
+	offset, offsetArgs := zoneOffset(ranges[0].From.Unix(), ranges[len(ranges)-1].To.Unix(), m.location)
+
 	query := `
-	SELECT strftime('%s', strftime('%Y-%m-%d', timestamp, 'unixepoch', 'localtime')) AS unix,
+	SELECT ` + localDay(offset) + ` AS unix,
 		SUM(sent),
 		SUM(received),
 		SUM(total)
 	FROM snapshots
-	WHERE strftime('%m', timestamp, 'unixepoch', 'localtime') = ?
-	GROUP BY unix
-
-	UNION
-
-	SELECT strftime('%m', timestamp, 'unixepoch', 'localtime') AS unix, SUM(sent), SUM(received), SUM(total) 
-	FROM snapshots 
-	WHERE unix = ?
+	WHERE ` + period + ` AND ` + filter + `
 	GROUP BY unix
-
 	ORDER BY unix DESC`
 
 	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
 
 	defer cancel()
 
-	rows, err := m.db.QueryContext(timeout, query, month, month)
+	args := append(append(append([]interface{}{}, offsetArgs...), periodArgs...), filterArgs...)
+
+	rows, err := m.db.QueryContext(timeout, query, args...)
 
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -98,6 +177,8 @@ func (m *SnapshotModel) GetStatsByMonth(ctx context.Context, month string) ([]Sn
 
 	var stats []Snapshot
 
+	cumulative := Snapshot{Timestamp: int64(ranges[0].From.Month())}
+
 	defer rows.Close()
 
 	for rows.Next() {
@@ -107,22 +188,39 @@ func (m *SnapshotModel) GetStatsByMonth(ctx context.Context, month string) ([]Sn
 			return nil, err
 		}
 
+		s.Timestamp = dayStart(s.Timestamp, m.location)
 		stats = append(stats, s)
+
+		cumulative.Stat.Sent += s.Stat.Sent
+		cumulative.Stat.Received += s.Stat.Received
+		cumulative.Stat.Total += s.Stat.Total
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return stats, nil
+	if len(stats) == 0 {
+		return nil, nil
+	}
+
+	return append(stats, cumulative), nil
 }
 
 func (m *SnapshotModel) GetMonthStat(ctx context.Context, month string) (MonthStat, error) {
+	period, periodArgs, err := m.monthCondition(ctx, "snapshots", month)
+
+	if err != nil {
+		return MonthStat{}, err
+	}
+
+	filter, filterArgs := m.snapshotFilter()
+
 	query := `SELECT 
-		strftime('%m', timestamp, 'unixepoch') AS month, SUM(sent), SUM(received), SUM(total) 
+		? AS month, SUM(sent), SUM(received), SUM(total) 
 		FROM snapshots 
-		WHERE month = ?
-		GROUP BY month`
+		WHERE ` + period + ` AND ` + filter + `
+		HAVING COUNT(*) > 0`
 
 	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
 
@@ -130,7 +228,7 @@ func (m *SnapshotModel) GetMonthStat(ctx context.Context, month string) (MonthSt
 
 	var s MonthStat
 
-	if err := m.db.QueryRowContext(timeout, query, month).Scan(&s.Month, &s.Stat.Sent, &s.Stat.Received, &s.Stat.Total); err != nil {
+	if err = m.db.QueryRowContext(timeout, query, append(append([]interface{}{month}, periodArgs...), filterArgs...)...).Scan(&s.Month, &s.Stat.Sent, &s.Stat.Received, &s.Stat.Total); err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return s, ErrTimedOut
 		}
@@ -144,10 +242,22 @@ func (m *SnapshotModel) GetMonthStat(ctx context.Context, month string) (MonthSt
 	return s, nil
 }
 
+// GetAllStats sums up the snapshots by day, latest first, each day's
+// timestamp being its midnight in the model's time zone.
 func (m *SnapshotModel) GetAllStats(ctx context.Context) ([]Snapshot, error) {
+	span, err := m.timestampSpan(ctx, "snapshots")
+
+	if err != nil || span == nil {
+		return nil, err
+	}
+
+	filter, filterArgs := m.snapshotFilter()
+	offset, offsetArgs := zoneOffset(span.From.Unix(), span.To.Unix()+1, m.location)
+
 	query := `SELECT 
-					strftime('%s', strftime('%Y-%m-%d', timestamp, 'unixepoch', 'localtime')) AS day_unix, SUM(sent), SUM(received), SUM(total)
+					` + localDay(offset) + ` AS day_unix, SUM(sent), SUM(received), SUM(total)
 					FROM snapshots
+					WHERE ` + filter + `
 					GROUP BY day_unix
 					ORDER BY day_unix DESC`
 
@@ -155,7 +265,7 @@ func (m *SnapshotModel) GetAllStats(ctx context.Context) ([]Snapshot, error) {
 
 	defer cancel()
 
-	rows, err := m.db.QueryContext(timeout, query)
+	rows, err := m.db.QueryContext(timeout, query, append(offsetArgs, filterArgs...)...)
 
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -176,6 +286,7 @@ func (m *SnapshotModel) GetAllStats(ctx context.Context) ([]Snapshot, error) {
 			return nil, err
 		}
 
+		s.Timestamp = dayStart(s.Timestamp, m.location)
 		stats = append(stats, s)
 	}
 
@@ -187,16 +298,32 @@ func (m *SnapshotModel) GetAllStats(ctx context.Context) ([]Snapshot, error) {
 }
 
 func (m *SnapshotModel) GetMonthsInYear(ctx context.Context, year string) ([]string, error) {
-	query := `
-	SELECT DISTINCT strftime('%m', timestamp, 'unixepoch', 'localtime') AS month 
-	FROM snapshots
-	WHERE strftime('%Y', timestamp, 'unixepoch', 'localtime') = ?
-	ORDER BY month DESC`
+	filter, filterArgs := m.snapshotFilter()
+
+	// Whether each month has a snapshot is looked up in the index, latest
+	// month first.
+	periods := yearMonths(year, m.location)
+	selects := make([]string, len(periods))
+	var args []interface{}
+
+	for i := range periods {
+		month := periods[len(periods)-1-i]
+		period, periodArgs := rangesCondition([]timeRange{month})
+
+		selects[i] = `SELECT ? WHERE EXISTS (SELECT 1 FROM snapshots WHERE ` + period + ` AND ` + filter + `)`
+		args = append(append(append(args, month.From.Format("01")), periodArgs...), filterArgs...)
+	}
+
+	if len(selects) == 0 {
+		return nil, nil
+	}
+
+	query := strings.Join(selects, " UNION ALL ")
 
 	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
 
 	defer cancel()
-	rows, err := m.db.QueryContext(timeout, query, year)
+	rows, err := m.db.QueryContext(timeout, query, args...)
 
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -228,11 +355,14 @@ func (m *SnapshotModel) GetMonthsInYear(ctx context.Context, year string) ([]str
 }
 
 func (m *SnapshotModel) GetStatByDate(ctx context.Context, date string) (DateStat, error) {
-	query := `SELECT COUNT(*), SUM(sent), SUM(received), SUM(total)
+	filter, filterArgs := m.snapshotFilter()
+	period, periodArgs := dateCondition(date, m.location)
+
+	query := `SELECT COUNT(*), COALESCE(SUM(sent), 0), COALESCE(SUM(received), 0), COALESCE(SUM(total), 0)
 	FROM (
 		SELECT sent, received, total
 		FROM snapshots
-		WHERE strftime('%Y-%m-%d', timestamp, 'unixepoch', 'localtime') = ?
+		WHERE ` + period + ` AND ` + filter + `
 	)`
 
 	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
@@ -241,7 +371,7 @@ func (m *SnapshotModel) GetStatByDate(ctx context.Context, date string) (DateSta
 
 	var s DateStat
 
-	if err := m.db.QueryRowContext(timeout, query, date).Scan(&s.HoursMonitored, &s.Sent, &s.Received, &s.Total); err != nil {
+	if err := m.db.QueryRowContext(timeout, query, append(periodArgs, filterArgs...)...).Scan(&s.HoursMonitored, &s.Sent, &s.Received, &s.Total); err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			return s, ErrTimedOut
 		}
@@ -253,6 +383,10 @@ func (m *SnapshotModel) GetStatByDate(ctx context.Context, date string) (DateSta
 		return s, err
 	}
 
+	if s.HoursMonitored == 0 {
+		return s, ErrNoRows
+	}
+
 	return DateStat{
 		HoursMonitored: s.HoursMonitored,
 		Stat: Stat{
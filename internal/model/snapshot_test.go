@@ -0,0 +1,179 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestMigrateLegacyTable(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	if _, err = db.ExecContext(ctx, `CREATE TABLE snapshots (timestamp INTEGER, sent INTEGER, received INTEGER, total INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stored twice by an agent that sent its batch again, and once more by
+	// another monitor sharing the database.
+	if _, err = db.ExecContext(ctx, `INSERT INTO snapshots VALUES (1665000000, 1, 2, 3), (1665000000, 1, 2, 3), (1665000000, 10, 20, 30), (1665003600, 1, 1, 2)`); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []MigrateReport{{Duplicates: 1, Summed: 1}, {}}
+
+	for i := range expected {
+		report, err := Migrate(ctx, db)
+
+		if err != nil {
+			t.Fatalf("migration #%d: %v", i+1, err)
+		}
+
+		if report != expected[i] {
+			t.Errorf("migration #%d: got %+v, expected %+v", i+1, report, expected[i])
+		}
+	}
+
+	var source, iface string
+	var rows int
+	var stat Stat
+
+	query := `SELECT source, interface, COUNT(*), SUM(sent), SUM(received), SUM(total) FROM snapshots WHERE timestamp = 1665000000`
+
+	if err = db.QueryRowContext(ctx, query).Scan(&source, &iface, &rows, &stat.Sent, &stat.Received, &stat.Total); err != nil {
+		t.Fatal(err)
+	}
+
+	if source != LocalSource || iface != "" {
+		t.Errorf("got %q and interface %q, expected legacy rows to be the local sum", source, iface)
+	}
+
+	if rows != 1 || stat != (Stat{Sent: 11, Received: 22, Total: 33}) {
+		t.Errorf("got %d rows of %+v, expected the copy to be deleted and the other counts summed", rows, stat)
+	}
+
+	if _, err = db.ExecContext(ctx, `INSERT INTO snapshots (timestamp, sent, received, total) VALUES (1665000000, 1, 2, 3)`); err == nil {
+		t.Error("expected a snapshot to be stored once")
+	}
+}
+
+func TestWithSource(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	now := time.Now().Unix()
+
+	snapshots := []*Snapshot{
+		{Timestamp: now, Stat: Stat{Sent: 1, Received: 1, Total: 2}},
+		{Timestamp: now, Source: "pcap:office", Stat: Stat{Sent: 10, Received: 10, Total: 20}},
+	}
+
+	for _, s := range snapshots {
+		if err := m.Insert(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	today := time.Unix(now, 0).Format("2006-01-02")
+
+	table := []struct {
+		source string
+		total  uint64
+	}{
+		{"", 22},
+		{LocalSource, 2},
+		{"pcap:office", 20},
+	}
+
+	for _, v := range table {
+		stat, err := m.WithSource(v.source).GetStatByDate(ctx, today)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stat.Total != v.total {
+			t.Errorf("source %q: got %d, expected %d", v.source, stat.Total, v.total)
+		}
+	}
+	if _, err := m.WithSource("netflow:10.0.0.1").GetStatByDate(ctx, today); err != ErrNoRows {
+		t.Errorf("got %v, expected ErrNoRows for a source without snapshots", err)
+	}
+}
+
+func TestPolledInterfaces(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	now := time.Now()
+
+	// The host's own traffic, and the same flow crossing two ports of a switch.
+	snapshots := []Snapshot{
+		{Timestamp: now.Unix(), Stat: Stat{Sent: 1, Received: 2, Total: 3}},
+		{Timestamp: now.Unix(), Source: "snmp:core", Interface: "Gi0/1", Stat: Stat{Sent: 10, Received: 20, Total: 30}},
+		{Timestamp: now.Unix(), Source: "snmp:core", Interface: "Gi0/2", Stat: Stat{Sent: 20, Received: 10, Total: 30}},
+	}
+
+	if err := m.InsertSnapshots(ctx, snapshots); err != nil {
+		t.Fatal(err)
+	}
+
+	today, err := m.GetStatByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if today.HoursMonitored != 1 || today.Total != 3 {
+		t.Errorf("got %+v, expected the local snapshot only", today)
+	}
+
+	month, err := m.GetStatsByMonth(ctx, now.Format("01"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(month) != 2 || month[1].Total != 3 {
+		t.Errorf("got %+v, expected the local snapshot only", month)
+	}
+
+	all, err := m.GetAllStats(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 1 || all[0].Total != 3 {
+		t.Errorf("got %+v, expected the local snapshot only", all)
+	}
+
+	hosts, err := m.GetHostStatsByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hosts) != 1 || hosts[0].Total != 3 {
+		t.Errorf("got %+v, expected the local snapshot only", hosts)
+	}
+
+	port, err := m.WithInterface("Gi0/1").GetStatByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if port.HoursMonitored != 1 || port.Total != 30 {
+		t.Errorf("got %+v, expected the polled interface once asked for", port)
+	}
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SubnetStat struct {
+	Source string // where the traffic was observed, LocalSource if empty
+	Subnet string
+	Stat
+}
+
+func (m *SnapshotModel) InsertSubnets(ctx context.Context, timestamp int64, subnets []SubnetStat) error {
+	query := `INSERT INTO subnet_snapshots (timestamp, source, host, subnet, sent, received, total) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(timeout, nil)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrTimedOut
+		}
+
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, s := range subnets {
+		if _, err = tx.ExecContext(timeout, query, timestamp, sourceOrLocal(s.Source), m.host, s.Subnet, s.Stat.Sent, s.Stat.Received, s.Stat.Total); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ErrTimedOut
+			}
+
+			return fmt.Errorf("failed to insert subnet %s: %w", s.Subnet, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *SnapshotModel) GetSubnetStatsByDate(ctx context.Context, date string) ([]SubnetStat, error) {
+	filter, filterArgs := m.scopeFilter()
+	period, periodArgs := dateCondition(date, m.location)
+
+	query := `SELECT subnet, SUM(sent), SUM(received), SUM(total)
+	FROM subnet_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY subnet
+	ORDER BY SUM(total) DESC`
+
+	return m.querySubnetStats(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) GetSubnetStatsByMonth(ctx context.Context, month string) ([]SubnetStat, error) {
+	period, periodArgs, err := m.monthCondition(ctx, "subnet_snapshots", month)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filter, filterArgs := m.scopeFilter()
+
+	query := `SELECT subnet, SUM(sent), SUM(received), SUM(total)
+	FROM subnet_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY subnet
+	ORDER BY SUM(total) DESC`
+
+	return m.querySubnetStats(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) querySubnetStats(ctx context.Context, query string, args ...interface{}) ([]SubnetStat, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.db.QueryContext(timeout, query, args...)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrTimedOut
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var stats []SubnetStat
+
+	for rows.Next() {
+		var s SubnetStat
+
+		if err = rows.Scan(&s.Subnet, &s.Stat.Sent, &s.Stat.Received, &s.Stat.Total); err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return stats, nil
+}
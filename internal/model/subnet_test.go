@@ -0,0 +1,48 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubnetStats(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	now := time.Now()
+
+	err := m.InsertSubnets(ctx, now.Unix(), []SubnetStat{
+		{Source: "netflow:192.0.2.1", Subnet: "192.168.1.0/24", Stat: Stat{Sent: 10, Received: 20, Total: 30}},
+		{Source: "netflow:192.0.2.1", Subnet: "192.168.2.0/24", Stat: Stat{Sent: 1, Received: 1, Total: 2}},
+		{Source: "netflow:192.0.2.2", Subnet: "192.168.1.0/24", Stat: Stat{Sent: 5, Received: 5, Total: 10}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.GetSubnetStatsByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 || stats[0].Subnet != "192.168.1.0/24" || stats[0].Total != 40 || stats[1].Total != 2 {
+		t.Errorf("got %+v, expected 192.168.1.0/24 with 40 then 192.168.2.0/24 with 2", stats)
+	}
+
+	stats, err = m.WithSource("netflow:192.0.2.2").GetSubnetStatsByMonth(ctx, now.Format("01"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 || stats[0].Total != 10 {
+		t.Errorf("got %+v, expected only the second exporter's subnet", stats)
+	}
+
+	if _, err = m.GetSubnetStatsByDate(ctx, "1999-01-01"); err != ErrNoRows {
+		t.Errorf("got %v, expected ErrNoRows", err)
+	}
+}
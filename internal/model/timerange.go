@@ -0,0 +1,199 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Periods are selected by ranges of timestamps computed here rather than by
+// formatting every timestamp in SQL, so that the timestamp indexes are used,
+// and local times are found by adding the offset of the model's time zone,
+// which SQLite knows nothing about, instead of using 'localtime', which is the
+// time zone of the machine.
+
+// The condition matching no row, for periods that can't exist.
+const noPeriod = "0"
+
+// A range of time, [From, To).
+type timeRange struct {
+	From, To time.Time
+}
+
+// The condition matching the timestamps of any of the ranges and its arguments.
+func rangesCondition(ranges []timeRange) (string, []interface{}) {
+	if len(ranges) == 0 {
+		return noPeriod, nil
+	}
+
+	conditions := make([]string, len(ranges))
+	args := make([]interface{}, 0, 2*len(ranges))
+
+	for i, r := range ranges {
+		conditions[i] = "(timestamp >= ? AND timestamp < ?)"
+		args = append(args, r.From.Unix(), r.To.Unix())
+	}
+
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// The condition matching the timestamps of a date, YYYY-MM-DD, in loc.
+func dateCondition(date string, loc *time.Location) (string, []interface{}) {
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+
+	if err != nil {
+		return noPeriod, nil
+	}
+
+	return rangesCondition([]timeRange{{day, day.AddDate(0, 0, 1)}})
+}
+
+// The months of a year, YYYY, in loc.
+func yearMonths(year string, loc *time.Location) []timeRange {
+	start, err := time.ParseInLocation("2006", year, loc)
+
+	if err != nil {
+		return nil
+	}
+
+	months := make([]timeRange, 12)
+
+	for i := range months {
+		months[i] = timeRange{start.AddDate(0, i, 0), start.AddDate(0, i+1, 0)}
+	}
+
+	return months
+}
+
+// The first and last timestamp of table, nil if it is empty.
+func (m *SnapshotModel) timestampSpan(ctx context.Context, table string) (*timeRange, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	var first, last *int64
+
+	// Apart, MIN and MAX are read off the index.
+	query := `SELECT (SELECT MIN(timestamp) FROM ` + table + `), (SELECT MAX(timestamp) FROM ` + table + `)`
+
+	if err := m.db.QueryRowContext(timeout, query).Scan(&first, &last); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrTimedOut
+		}
+
+		return nil, err
+	}
+
+	if first == nil {
+		return nil, nil
+	}
+
+	return &timeRange{time.Unix(*first, 0), time.Unix(*last, 0)}, nil
+}
+
+// The ranges of a month, MM, in the model's time zone, in every year table has
+// snapshots of.
+func (m *SnapshotModel) monthRanges(ctx context.Context, table, month string) ([]timeRange, error) {
+	number, err := strconv.Atoi(month)
+
+	if err != nil || len(month) != 2 || number < 1 || number > 12 {
+		return nil, nil
+	}
+
+	span, err := m.timestampSpan(ctx, table)
+
+	if err != nil || span == nil {
+		return nil, err
+	}
+
+	var ranges []timeRange
+
+	for year := span.From.In(m.location).Year(); year <= span.To.In(m.location).Year(); year++ {
+		start := time.Date(year, time.Month(number), 1, 0, 0, 0, 0, m.location)
+		ranges = append(ranges, timeRange{start, start.AddDate(0, 1, 0)})
+	}
+
+	return ranges, nil
+}
+
+// The condition matching the timestamps of a month, MM, in the model's time
+// zone, in every year table has snapshots of.
+func (m *SnapshotModel) monthCondition(ctx context.Context, table, month string) (string, []interface{}, error) {
+	ranges, err := m.monthRanges(ctx, table, month)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	condition, args := rangesCondition(ranges)
+
+	return condition, args, nil
+}
+
+// The offset of loc from UTC, in seconds, at the timestamps in [from, to): an
+// SQL expression of timestamp and its arguments. It only changes at the
+// transitions of the zone, such as daylight saving time. Adding it to a
+// timestamp gives the local time as if it were UTC.
+func zoneOffset(from, to int64, loc *time.Location) (string, []interface{}) {
+	offset := offsetAt(from, loc)
+
+	expression := "CASE"
+	var args []interface{}
+
+	// Zones change their offset at most a few times a year, never twice a day.
+	for t := from; t < to; t += 24 * 60 * 60 {
+		next := t + 24*60*60
+
+		if next > to {
+			next = to
+		}
+
+		if offsetAt(next-1, loc) == offset {
+			continue
+		}
+
+		// The first second of the new offset.
+		low, high := t, next-1
+
+		for low < high {
+			mid := low + (high-low)/2
+
+			if offsetAt(mid, loc) == offset {
+				low = mid + 1
+			} else {
+				high = mid
+			}
+		}
+
+		expression += " WHEN timestamp < ? THEN ?"
+		args = append(args, low, offset)
+		offset = offsetAt(low, loc)
+	}
+
+	if len(args) == 0 {
+		return "?", []interface{}{offset}
+	}
+
+	return expression + " ELSE ? END", append(args, offset)
+}
+
+func offsetAt(timestamp int64, loc *time.Location) int {
+	_, offset := time.Unix(timestamp, 0).In(loc).Zone()
+
+	return offset
+}
+
+// SQL expression of a local day, the local time of its midnight as if it were
+// UTC, given that of the offset of the time zone.
+func localDay(offset string) string {
+	return "(timestamp + " + offset + ") / 86400 * 86400"
+}
+
+// The timestamp of the midnight in loc of a local day.
+func dayStart(day int64, loc *time.Location) int64 {
+	date := time.Unix(day, 0).UTC()
+
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc).Unix()
+}
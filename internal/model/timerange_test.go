@@ -0,0 +1,281 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPeriodBounds(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.Local)
+
+	snapshots := []*Snapshot{
+		{Timestamp: day.Add(-time.Second).Unix(), Stat: Stat{Sent: 1, Received: 0, Total: 1}},
+		{Timestamp: day.Unix(), Stat: Stat{Sent: 2, Received: 0, Total: 2}},
+		{Timestamp: day.AddDate(0, 0, 1).Add(-time.Second).Unix(), Stat: Stat{Sent: 4, Received: 0, Total: 4}},
+		{Timestamp: day.AddDate(0, 0, 1).Unix(), Stat: Stat{Sent: 8, Received: 0, Total: 8}},
+		{Timestamp: day.AddDate(-1, 0, 0).Unix(), Stat: Stat{Sent: 16, Received: 0, Total: 16}},
+	}
+
+	for _, s := range snapshots {
+		if err := m.Insert(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	date, err := m.GetStatByDate(ctx, "2022-08-01")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if date.HoursMonitored != 2 || date.Total != 6 {
+		t.Errorf("got %+v, expected the 2 snapshots from midnight to midnight", date)
+	}
+
+	hosts, err := m.GetHostStatsByMonth(ctx, "08")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hosts) != 1 || hosts[0].Total != 30 {
+		t.Errorf("got %+v, expected the snapshots of August in both years", hosts)
+	}
+
+	months, err := m.GetMonthsInYear(ctx, "2022")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(months, ",") != "08,07" {
+		t.Errorf("got months %v, expected 08 and 07", months)
+	}
+
+	for _, month := range []string{"8", "13", "ab"} {
+		if _, err = m.GetHostStatsByMonth(ctx, month); err != ErrNoRows {
+			t.Errorf("got %v for month %q, expected no rows", err, month)
+		}
+	}
+}
+
+func TestReportTimeZone(t *testing.T) {
+	ctx := context.Background()
+
+	newYork, err := time.LoadLocation("America/New_York")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The snapshots are taken in New York, and reported on a machine in Tokyo.
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = tokyo
+
+	m := newTestModel(t).WithLocation(newYork)
+
+	var snapshots []Snapshot
+
+	// A byte an hour around both changes of daylight saving time of 2022: the
+	// 13th of March lasts 23 hours, the 6th of November 25.
+	for _, day := range []time.Time{
+		time.Date(2022, 3, 12, 0, 0, 0, 0, newYork),
+		time.Date(2022, 11, 5, 0, 0, 0, 0, newYork),
+	} {
+		for at := day; at.Before(day.AddDate(0, 0, 3)); at = at.Add(time.Hour) {
+			snapshots = append(snapshots, Snapshot{Timestamp: at.Unix(), Stat: Stat{Sent: 1, Total: 1}})
+		}
+	}
+
+	// The last hours of 2022 in New York, of 2023 in UTC and Tokyo.
+	snapshots = append(snapshots, Snapshot{Timestamp: time.Date(2022, 12, 31, 22, 0, 0, 0, newYork).Unix(), Stat: Stat{Sent: 100, Total: 100}})
+
+	if err = m.InsertSnapshots(ctx, snapshots); err != nil {
+		t.Fatal(err)
+	}
+
+	for date, hours := range map[string]int{"2022-03-12": 24, "2022-03-13": 23, "2022-11-06": 25, "2022-11-07": 24} {
+		stat, err := m.GetStatByDate(ctx, date)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stat.HoursMonitored != hours {
+			t.Errorf("got %d hours on %s, expected %d", stat.HoursMonitored, date, hours)
+		}
+	}
+
+	days, err := m.GetStatsByMonth(ctx, "11")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(days) != 4 || days[1].Timestamp != time.Date(2022, 11, 6, 0, 0, 0, 0, newYork).Unix() || days[1].Total != 25 || days[3].Total != 73 {
+		t.Errorf("got %+v, expected the days of November in New York and their sum", days)
+	}
+
+	all, err := m.GetAllStats(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 7 || all[0].Timestamp != time.Date(2022, 12, 31, 0, 0, 0, 0, newYork).Unix() || all[0].Total != 100 {
+		t.Errorf("got %+v, expected New Year's Eve in New York first", all)
+	}
+
+	months, err := m.GetMonthsInYear(ctx, "2022")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(months, ",") != "12,11,03" {
+		t.Errorf("got months %v, expected 12, 11 and 03", months)
+	}
+
+	// Every query buckets the same way, the month total included.
+	month, err := m.GetMonthStat(ctx, "12")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if month.Total != 100 {
+		t.Errorf("got %+v, expected the 100 bytes of December in New York", month)
+	}
+
+	if _, err = m.GetMonthStat(ctx, "01"); err != ErrNoRows {
+		t.Errorf("got %v, expected nothing in January in New York", err)
+	}
+
+	start := time.Date(2022, 11, 6, 0, 0, 0, 0, newYork).Unix()
+
+	hours, err := m.GetPeriodStats(ctx, start, start+25*60*60, PeriodHour, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hour from 1 to 2 is repeated once clocks go back.
+	if len(hours) != 24 || hours[1].Period != "2022-11-06T01:00:00" || hours[1].Snapshots != 2 || hours[2].Period != "2022-11-06T02:00:00" {
+		t.Errorf("got %+v, expected 1 o'clock twice and 24 hours", hours)
+	}
+
+	periods, err := m.GetPeriodStats(ctx, 0, start+365*24*60*60, PeriodDay, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(periods) != 7 || periods[1].Period != "2022-03-13" || periods[1].Snapshots != 23 || periods[6].Period != "2022-12-31" {
+		t.Errorf("got %+v, expected the days in New York", periods)
+	}
+}
+
+// The snapshots of the benchmarks, one every 30 seconds for about a year up
+// to now.
+const benchmarkSnapshots = 1000000
+
+var (
+	benchmarkOnce  sync.Once
+	benchmarkModel *SnapshotModel
+)
+
+func seededModel(b *testing.B) *SnapshotModel {
+	b.Helper()
+
+	benchmarkOnce.Do(func() {
+		// Not closed, the next benchmarks use it too.
+		db, err := sql.Open("sqlite3", ":memory:")
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		db.SetMaxOpenConns(1)
+
+		if _, err = Migrate(context.Background(), db); err != nil {
+			b.Fatal(err)
+		}
+
+		m := NewSnapshotModel(db)
+
+		seed := `WITH RECURSIVE seq(i) AS (SELECT 0 UNION ALL SELECT i + 1 FROM seq WHERE i < ? - 1)
+		INSERT INTO snapshots (timestamp, source, interface, host, sent, received, total)
+		SELECT ? - i * 30, 'local', '', '', i % 1000, i % 700, i % 1000 + i % 700 FROM seq`
+
+		if _, err = m.db.Exec(seed, benchmarkSnapshots, time.Now().Unix()); err != nil {
+			b.Fatal(err)
+		}
+
+		if err = Analyze(context.Background(), m.db); err != nil {
+			b.Fatal(err)
+		}
+
+		benchmarkModel = m
+	})
+
+	if benchmarkModel == nil {
+		b.Fatal("failed to seed the benchmark database")
+	}
+
+	return benchmarkModel
+}
+
+func BenchmarkMonthView(b *testing.B) {
+	ctx := context.Background()
+	m := seededModel(b)
+	month := time.Now().Format("01")
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := m.GetStatsByMonth(ctx, month); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDayView(b *testing.B) {
+	ctx := context.Background()
+	m := seededModel(b)
+	date := time.Now().Format("2006-01-02")
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := m.GetStatByDate(ctx, date); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMonthsInYear(b *testing.B) {
+	ctx := context.Background()
+	m := seededModel(b)
+	year := time.Now().Format("2006")
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := m.GetMonthsInYear(ctx, year); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
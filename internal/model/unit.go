@@ -0,0 +1,115 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type UnitStat struct {
+	Unit string
+	Stat
+}
+
+func (m *SnapshotModel) InsertUnits(ctx context.Context, timestamp int64, units []UnitStat) error {
+	query := `INSERT INTO unit_snapshots (timestamp, host, unit, sent, received, total) VALUES (?, ?, ?, ?, ?, ?)`
+
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(timeout, nil)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrTimedOut
+		}
+
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, u := range units {
+		if _, err = tx.ExecContext(timeout, query, timestamp, m.host, u.Unit, u.Stat.Sent, u.Stat.Received, u.Stat.Total); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ErrTimedOut
+			}
+
+			return fmt.Errorf("failed to insert unit %s: %w", u.Unit, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *SnapshotModel) GetUnitStatsByDate(ctx context.Context, date string) ([]UnitStat, error) {
+	filter, filterArgs := m.scopeFilter()
+	period, periodArgs := dateCondition(date, m.location)
+
+	query := `SELECT unit, SUM(sent), SUM(received), SUM(total)
+	FROM unit_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY unit
+	ORDER BY SUM(total) DESC`
+
+	return m.queryUnitStats(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) GetUnitStatsByMonth(ctx context.Context, month string) ([]UnitStat, error) {
+	period, periodArgs, err := m.monthCondition(ctx, "unit_snapshots", month)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filter, filterArgs := m.scopeFilter()
+
+	query := `SELECT unit, SUM(sent), SUM(received), SUM(total)
+	FROM unit_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY unit
+	ORDER BY SUM(total) DESC`
+
+	return m.queryUnitStats(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) queryUnitStats(ctx context.Context, query string, args ...interface{}) ([]UnitStat, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.db.QueryContext(timeout, query, args...)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrTimedOut
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var stats []UnitStat
+
+	for rows.Next() {
+		var s UnitStat
+
+		if err = rows.Scan(&s.Unit, &s.Stat.Sent, &s.Stat.Received, &s.Stat.Total); err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return stats, nil
+}
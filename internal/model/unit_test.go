@@ -0,0 +1,66 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func newTestModel(t testing.TB) *SnapshotModel {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1) // every connection would get its own in-memory database
+
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSnapshotModel(db)
+}
+
+func TestUnitStats(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	now := time.Now()
+
+	err := m.InsertUnits(ctx, now.Unix(), []UnitStat{
+		{Unit: "nginx.service", Stat: Stat{Sent: 10, Received: 20, Total: 30}},
+		{Unit: "sshd.service", Stat: Stat{Sent: 1, Received: 1, Total: 2}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.InsertUnits(ctx, now.Unix()+1, []UnitStat{
+		{Unit: "nginx.service", Stat: Stat{Sent: 5, Received: 5, Total: 10}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.GetUnitStatsByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 || stats[0].Unit != "nginx.service" || stats[0].Total != 40 || stats[1].Total != 2 {
+		t.Errorf("got %+v, expected nginx.service with 40 then sshd.service with 2", stats)
+	}
+
+	if _, err = m.GetUnitStatsByDate(ctx, "1999-01-01"); err != ErrNoRows {
+		t.Errorf("got %v, expected ErrNoRows", err)
+	}
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A ProtocolUsage sums up the bytes of a protocol, e.g. tcp, in the traffic
+// of captures and flows.
+type ProtocolUsage struct {
+	Source   string // where the traffic was observed, LocalSource if empty
+	Protocol string
+	Stat
+}
+
+func (m *SnapshotModel) InsertProtocolUsage(ctx context.Context, timestamp int64, usage []ProtocolUsage) error {
+	query := `INSERT INTO protocol_usage_snapshots (timestamp, source, host, protocol, sent, received, total) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(timeout, nil)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrTimedOut
+		}
+
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, u := range usage {
+		if _, err = tx.ExecContext(timeout, query, timestamp, sourceOrLocal(u.Source), m.host, u.Protocol, u.Stat.Sent, u.Stat.Received, u.Stat.Total); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ErrTimedOut
+			}
+
+			return fmt.Errorf("failed to insert protocol %s: %w", u.Protocol, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *SnapshotModel) GetProtocolUsageByDate(ctx context.Context, date string) ([]ProtocolUsage, error) {
+	filter, filterArgs := m.scopeFilter()
+	period, periodArgs := dateCondition(date, m.location)
+
+	query := `SELECT protocol, SUM(sent), SUM(received), SUM(total)
+	FROM protocol_usage_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY protocol
+	ORDER BY SUM(total) DESC`
+
+	return m.queryProtocolUsage(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) GetProtocolUsageByMonth(ctx context.Context, month string) ([]ProtocolUsage, error) {
+	period, periodArgs, err := m.monthCondition(ctx, "protocol_usage_snapshots", month)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filter, filterArgs := m.scopeFilter()
+
+	query := `SELECT protocol, SUM(sent), SUM(received), SUM(total)
+	FROM protocol_usage_snapshots
+	WHERE ` + period + ` AND ` + filter + `
+	GROUP BY protocol
+	ORDER BY SUM(total) DESC`
+
+	return m.queryProtocolUsage(ctx, query, append(periodArgs, filterArgs...)...)
+}
+
+func (m *SnapshotModel) queryProtocolUsage(ctx context.Context, query string, args ...interface{}) ([]ProtocolUsage, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.db.QueryContext(timeout, query, args...)
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrTimedOut
+		}
+
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var usage []ProtocolUsage
+
+	for rows.Next() {
+		var u ProtocolUsage
+
+		if err = rows.Scan(&u.Protocol, &u.Stat.Sent, &u.Stat.Received, &u.Stat.Total); err != nil {
+			return nil, err
+		}
+
+		usage = append(usage, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(usage) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return usage, nil
+}
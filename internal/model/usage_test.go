@@ -0,0 +1,48 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProtocolUsage(t *testing.T) {
+	ctx := context.Background()
+	m := newTestModel(t)
+
+	now := time.Now()
+
+	err := m.InsertProtocolUsage(ctx, now.Unix(), []ProtocolUsage{
+		{Source: "pcap:office", Protocol: "tcp", Stat: Stat{Sent: 10, Received: 20, Total: 30}},
+		{Source: "pcap:office", Protocol: "udp", Stat: Stat{Sent: 1, Received: 1, Total: 2}},
+		{Source: "netflow:192.0.2.1", Protocol: "tcp", Stat: Stat{Sent: 5, Received: 5, Total: 10}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := m.GetProtocolUsageByDate(ctx, now.Format("2006-01-02"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usage) != 2 || usage[0].Protocol != "tcp" || usage[0].Total != 40 || usage[1].Total != 2 {
+		t.Errorf("got %+v, expected tcp with 40 then udp with 2", usage)
+	}
+
+	usage, err = m.WithSource("pcap:office").GetProtocolUsageByMonth(ctx, now.Format("01"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usage) != 2 || usage[0].Total != 30 {
+		t.Errorf("got %+v, expected only the capture's protocols", usage)
+	}
+
+	if _, err = m.GetProtocolUsageByDate(ctx, "1999-01-01"); err != ErrNoRows {
+		t.Errorf("got %v, expected ErrNoRows", err)
+	}
+}
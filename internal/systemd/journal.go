@@ -0,0 +1,113 @@
+package systemd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Where journald receives entries in its native protocol.
+var journalSocket = "/run/systemd/journal/socket"
+
+// Priorities of journal entries, as in syslog(3).
+const (
+	PriorityCrit    = 2
+	PriorityErr     = 3
+	PriorityWarning = 4
+	PriorityInfo    = 6
+	PriorityDebug   = 7
+)
+
+// A Journal sends structured entries to journald.
+type Journal struct {
+	conn       *net.UnixConn
+	identifier string
+}
+
+// Connect to journald, entries are tagged with the identifier.
+func NewJournal(identifier string) (*Journal, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+
+	return &Journal{conn: conn, identifier: identifier}, nil
+}
+
+// Send an entry, fields are named after their keys in upper case.
+func (j *Journal) Send(priority int, message string, fields map[string]string) error {
+	var entry bytes.Buffer
+
+	appendJournalField(&entry, "MESSAGE", message)
+	appendJournalField(&entry, "PRIORITY", fmt.Sprint(priority))
+	appendJournalField(&entry, "SYSLOG_IDENTIFIER", j.identifier)
+
+	keys := make([]string, 0, len(fields))
+
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if name := journalFieldName(key); name != "" {
+			appendJournalField(&entry, name, fields[key])
+		}
+	}
+
+	if _, err := j.conn.Write(entry.Bytes()); err != nil {
+		return fmt.Errorf("failed to write to journald: %w", err)
+	}
+
+	return nil
+}
+
+func (j *Journal) Close() error {
+	return j.conn.Close()
+}
+
+// Values spanning lines are sent with their length, see systemd.journal-fields(7).
+func appendJournalField(entry *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		entry.WriteString(name + "=" + value + "\n")
+		return
+	}
+
+	var size [8]byte
+
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+
+	entry.WriteString(name + "\n")
+	entry.Write(size[:])
+	entry.WriteString(value + "\n")
+}
+
+// Journal field names are upper case letters, digits and underscores, not
+// starting with an underscore, which marks fields set by journald itself.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+
+	trimmed := strings.TrimLeft(string(name), "_0123456789")
+
+	if len(trimmed) > 64 {
+		trimmed = trimmed[:64]
+	}
+
+	switch trimmed {
+	case "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER":
+		return "" // set from the entry itself
+	}
+
+	return trimmed
+}
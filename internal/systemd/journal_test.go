@@ -0,0 +1,51 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	defer func(socket string) { journalSocket = socket }(journalSocket)
+	journalSocket = path
+
+	journal, err := NewJournal("monitoor")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer journal.Close()
+
+	err = journal.Send(PriorityWarning, "two\nlines", map[string]string{"sent": "1 kB", "_pid": "1", "message": "ignored"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "MESSAGE\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\nPRIORITY=4\nSYSLOG_IDENTIFIER=monitoor\nPID=1\nSENT=1 kB\n"
+
+	if got := string(buffer[:n]); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,67 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The first file descriptor passed by socket activation, see sd_listen_fds(3).
+var listenFdsStart = 3
+
+// Listeners returns the sockets systemd passed to the process, by the
+// FileDescriptorName= of their socket unit, which defaults to the unit's name.
+// It returns an empty map when the process was not socket activated. The
+// environment is cleared so the sockets are not claimed twice.
+func Listeners() (map[string]net.Listener, error) {
+	listeners := map[string]net.Listener{}
+
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+
+	if pid == "" || fds == "" || pid != strconv.Itoa(os.Getpid()) {
+		return listeners, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	n, err := strconv.Atoi(fds)
+
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		name := "unknown"
+
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		closeOnExec(fd)
+
+		file := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(file)
+
+		// FileListener dups the descriptor.
+		file.Close()
+
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+
+			return nil, fmt.Errorf("failed to use activated socket %s: %w", name, err)
+		}
+
+		listeners[name] = listener
+	}
+
+	return listeners, nil
+}
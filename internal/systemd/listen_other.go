@@ -0,0 +1,6 @@
+//go:build !linux
+
+package systemd
+
+// Socket activation is linux only.
+func closeOnExec(fd int) {}
@@ -0,0 +1,76 @@
+//go:build linux
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestListeners(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+
+	if listeners, err := Listeners(); err != nil || len(listeners) != 0 {
+		t.Fatalf("got %v, %v, expected no listeners without activation", listeners, err)
+	}
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tcp.Close()
+
+	// Pass a copy of the socket like systemd would, at the first descriptor.
+	file, err := tcp.(*net.TCPListener).File()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(start int) { listenFdsStart = start }(listenFdsStart)
+	listenFdsStart = int(file.Fd())
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "live")
+
+	listeners, err := Listeners()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live, ok := listeners["live"]
+
+	if !ok || len(listeners) != 1 {
+		t.Fatalf("got %v, expected the live socket", listeners)
+	}
+
+	defer live.Close()
+
+	if live.Addr().String() != tcp.Addr().String() {
+		t.Errorf("got %s, expected the activated socket at %s", live.Addr(), tcp.Addr())
+	}
+
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("expected the environment to be cleared")
+	}
+
+	go func() {
+		if conn, err := net.Dial("tcp", tcp.Addr().String()); err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := live.Accept()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Close()
+}
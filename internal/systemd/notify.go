@@ -0,0 +1,74 @@
+// Package systemd speaks the parts of the systemd service protocol the
+// daemons need: readiness and status notifications, the watchdog and socket
+// activation. Outside of systemd every call is a no-op.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification states, see sd_notify(3).
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Status describes the service in systemctl status.
+func Status(format string, args ...interface{}) string {
+	return "STATUS=" + fmt.Sprintf(format, args...)
+}
+
+// Notify sends the states to the service manager in a single datagram. It
+// reports false without error when the process is not run by systemd with
+// Type=notify.
+func Notify(states ...string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+
+	if socket == "" {
+		return false, nil
+	}
+
+	// A leading @ is an abstract socket, which the net package handles.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, fmt.Errorf("failed to notify systemd: %w", err)
+	}
+
+	return true, nil
+}
+
+// WatchdogInterval is how often systemd expects to hear WATCHDOG=1 before it
+// considers the service hung, or 0 when the watchdog is disabled.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+
+	if usec == "" {
+		return 0, nil
+	}
+
+	// The watchdog may be meant for another process of the service.
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseInt(usec, 10, 64)
+
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+
+	return time.Duration(n) * time.Microsecond, nil
+}
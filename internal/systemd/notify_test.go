@@ -0,0 +1,78 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Listen like systemd does for a Type=notify service.
+func fakeNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	return conn
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	if notified, err := Notify(Ready); notified || err != nil {
+		t.Fatalf("got %v, %v, expected nothing to notify outside of systemd", notified, err)
+	}
+
+	conn := fakeNotifySocket(t)
+
+	notified, err := Notify(Ready, Status("%d kB/s", 12))
+
+	if err != nil || !notified {
+		t.Fatalf("got %v, %v, expected systemd to be notified", notified, err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(buffer[:n]); got != "READY=1\nSTATUS=12 kB/s" {
+		t.Errorf("got %q, expected readiness and status in one datagram", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	for _, test := range []struct {
+		usec, pid string
+		interval  time.Duration
+		fails     bool
+	}{
+		{"", "", 0, false},
+		{"30000000", "", 30 * time.Second, false},
+		{"30000000", strconv.Itoa(1), 0, false},
+		{"-1", "", 0, true},
+		{"soon", "", 0, true},
+	} {
+		t.Setenv("WATCHDOG_USEC", test.usec)
+		t.Setenv("WATCHDOG_PID", test.pid)
+
+		interval, err := WatchdogInterval()
+
+		if interval != test.interval || (err != nil) != test.fails {
+			t.Errorf("%q for pid %q: got %s, %v, expected %s", test.usec, test.pid, interval, err, test.interval)
+		}
+	}
+}
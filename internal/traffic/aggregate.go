@@ -0,0 +1,170 @@
+// Package traffic aggregates observed flows into snapshot-sized intervals.
+package traffic
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+)
+
+// A Bucket holds the usage observed during one interval, from the point of
+// view of the local network: bytes leaving it are sent, the rest received.
+type Bucket struct {
+	Start     time.Time
+	End       time.Time
+	Stat      model.Stat
+	Protocols map[string]model.Stat
+	Subnets   map[string]model.Stat // local subnets, when a subnet size is set
+	Endpoints map[EndpointKey]model.Stat
+}
+
+// An EndpointKey identifies the remote side of a flow.
+type EndpointKey struct {
+	Protocol string
+	Address  string
+	Port     int
+}
+
+// An Aggregator sums flows into fixed intervals.
+type Aggregator struct {
+	interval time.Duration
+	local    Locality
+
+	// Prefix lengths used to group local addresses into subnets, zero disables.
+	SubnetBitsV4, SubnetBitsV6 int
+
+	buckets map[int64]*Bucket
+}
+
+func NewAggregator(interval time.Duration, local Locality) *Aggregator {
+	return &Aggregator{
+		interval: interval,
+		local:    local,
+		buckets:  map[int64]*Bucket{},
+	}
+}
+
+// Account bytes of a flow observed at the given time.
+func (a *Aggregator) Add(at time.Time, protocol string, src, dst net.IP, srcPort, dstPort uint16, bytes uint64) {
+	start := at.Truncate(a.interval)
+	bucket, ok := a.buckets[start.Unix()]
+
+	if !ok {
+		bucket = &Bucket{
+			Start:     start,
+			End:       start.Add(a.interval),
+			Protocols: map[string]model.Stat{},
+			Subnets:   map[string]model.Stat{},
+			Endpoints: map[EndpointKey]model.Stat{},
+		}
+
+		a.buckets[start.Unix()] = bucket
+	}
+
+	var sent, received uint64
+
+	local, remote, remotePort := src, dst, dstPort
+
+	if a.local.Contains(src) {
+		sent = bytes
+	} else {
+		received = bytes
+		local, remote, remotePort = dst, src, srcPort
+	}
+
+	bucket.Stat = add(bucket.Stat, sent, received)
+	bucket.Protocols[protocol] = add(bucket.Protocols[protocol], sent, received)
+
+	key := EndpointKey{Protocol: protocol, Address: remote.String(), Port: int(remotePort)}
+	bucket.Endpoints[key] = add(bucket.Endpoints[key], sent, received)
+
+	if subnet := a.subnetOf(local); subnet != "" && a.local.Contains(local) {
+		bucket.Subnets[subnet] = add(bucket.Subnets[subnet], sent, received)
+	}
+}
+
+func (a *Aggregator) subnetOf(ip net.IP) string {
+	bits, size := a.SubnetBitsV6, 128
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits, size = ip4, a.SubnetBitsV4, 32
+	}
+
+	if bits <= 0 {
+		return ""
+	}
+
+	network := net.IPNet{IP: ip.Mask(net.CIDRMask(bits, size)), Mask: net.CIDRMask(bits, size)}
+
+	return network.String()
+}
+
+// The buckets in chronological order.
+func (a *Aggregator) Buckets() []Bucket {
+	buckets := make([]Bucket, 0, len(a.buckets))
+
+	for _, bucket := range a.buckets {
+		buckets = append(buckets, *bucket)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+
+	return buckets
+}
+
+// Remove and return, in chronological order, the buckets whose interval ended
+// by the given time. Live collectors use it to persist complete intervals only.
+func (a *Aggregator) Flush(before time.Time) []Bucket {
+	var buckets []Bucket
+
+	for key, bucket := range a.buckets {
+		if bucket.End.After(before) {
+			continue
+		}
+
+		buckets = append(buckets, *bucket)
+		delete(a.buckets, key)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+
+	return buckets
+}
+
+// Forget every bucket, e.g. after persisting them.
+func (a *Aggregator) Reset() {
+	a.buckets = map[int64]*Bucket{}
+}
+
+// The n remote endpoints of a bucket with the highest total usage.
+func (b Bucket) TopEndpoints(n int) []model.EndpointStat {
+	top := make([]model.EndpointStat, 0, len(b.Endpoints))
+
+	for key, stat := range b.Endpoints {
+		top = append(top, model.EndpointStat{Protocol: key.Protocol, Address: key.Address, Port: key.Port, Stat: stat})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Total == top[j].Total {
+			return top[i].Address < top[j].Address
+		}
+
+		return top[i].Total > top[j].Total
+	})
+
+	if n >= 0 && len(top) > n {
+		top = top[:n]
+	}
+
+	return top
+}
+
+func add(stat model.Stat, sent, received uint64) model.Stat {
+	return model.Stat{
+		Sent:     stat.Sent + sent,
+		Received: stat.Received + received,
+		Total:    stat.Total + sent + received,
+	}
+}
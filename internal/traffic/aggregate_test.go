@@ -0,0 +1,80 @@
+package traffic
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAggregator(t *testing.T) {
+	local, err := ParseLocality(DefaultLocalNets)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAggregator(time.Hour, local)
+	a.SubnetBitsV4 = 24
+
+	start := time.Unix(1665000000, 0).Truncate(time.Hour)
+	host, remote := net.ParseIP("192.168.1.10"), net.ParseIP("93.184.216.34")
+
+	a.Add(start.Add(time.Minute), "tcp", host, remote, 51000, 443, 1000)
+	a.Add(start.Add(2*time.Minute), "tcp", remote, host, 443, 51000, 5000)
+	a.Add(start.Add(3*time.Minute), "udp", host, net.ParseIP("1.1.1.1"), 53000, 53, 100)
+	a.Add(start.Add(time.Hour), "tcp", remote, host, 443, 51000, 42)
+
+	buckets := a.Buckets()
+
+	if len(buckets) != 2 {
+		t.Fatalf("got %d buckets, expected 2", len(buckets))
+	}
+
+	first := buckets[0]
+
+	if !first.Start.Equal(start) || !first.End.Equal(start.Add(time.Hour)) || first.Stat.Sent != 1100 || first.Stat.Received != 5000 || first.Stat.Total != 6100 {
+		t.Errorf("got %v %+v, expected %v with 1100 sent and 5000 received", first.Start, first.Stat, start)
+	}
+
+	if tcp := first.Protocols["tcp"]; tcp.Total != 6000 {
+		t.Errorf("got %d tcp bytes, expected 6000", tcp.Total)
+	}
+
+	if subnet := first.Subnets["192.168.1.0/24"]; subnet.Total != 6100 {
+		t.Errorf("got %d bytes for 192.168.1.0/24, expected 6100", subnet.Total)
+	}
+
+	top := first.TopEndpoints(1)
+
+	if len(top) != 1 || top[0].Address != "93.184.216.34" || top[0].Port != 443 || top[0].Sent != 1000 || top[0].Received != 5000 {
+		t.Errorf("got %+v, expected 93.184.216.34:443 with 1000 sent and 5000 received", top)
+	}
+
+	if buckets[1].Stat.Received != 42 {
+		t.Errorf("got %d, expected 42 received in the second bucket", buckets[1].Stat.Received)
+	}
+}
+
+func TestAggregatorFlush(t *testing.T) {
+	a := NewAggregator(time.Hour, Locality{})
+
+	start := time.Unix(1665000000, 0).Truncate(time.Hour)
+	src, dst := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+
+	a.Add(start, "tcp", src, dst, 1, 2, 10)
+	a.Add(start.Add(time.Hour), "tcp", src, dst, 1, 2, 20)
+
+	if flushed := a.Flush(start.Add(time.Hour - time.Second)); len(flushed) != 0 {
+		t.Errorf("got %d buckets, expected incomplete intervals to be kept", len(flushed))
+	}
+
+	flushed := a.Flush(start.Add(time.Hour))
+
+	if len(flushed) != 1 || !flushed[0].Start.Equal(start) {
+		t.Fatalf("got %+v, expected the first interval only", flushed)
+	}
+
+	if remaining := a.Buckets(); len(remaining) != 1 || remaining[0].Stat.Total != 20 {
+		t.Errorf("got %+v, expected the second interval to remain", remaining)
+	}
+}
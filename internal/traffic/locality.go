@@ -0,0 +1,44 @@
+package traffic
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Private, loopback and link-local ranges of both address families.
+const DefaultLocalNets = "10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,127.0.0.0/8,169.254.0.0/16,fc00::/7,fe80::/10,::1/128"
+
+// A Locality tells the addresses of the monitored network apart from remote ones.
+type Locality []*net.IPNet
+
+// Parse a comma separated list of CIDRs.
+func ParseLocality(cidrs string) (Locality, error) {
+	var locality Locality
+
+	for _, cidr := range strings.Split(cidrs, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid local network %q: %w", cidr, err)
+		}
+
+		locality = append(locality, network)
+	}
+
+	return locality, nil
+}
+
+func (l Locality) Contains(ip net.IP) bool {
+	for _, network := range l {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,225 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+)
+
+// Rows inserted in a single transaction on import.
+const importBatch = 500
+
+// A Conflict is an imported record that was skipped, as a snapshot with other
+// counts is stored at its timestamp and source already.
+type Conflict struct {
+	Row      int // of the record in the file, from 1
+	Record   Record
+	Existing model.Stat
+}
+
+func (c Conflict) Error() string {
+	return fmt.Sprintf("row %d: %d bytes at %d from %s are stored as %d bytes already",
+		c.Row, c.Record.Total, c.Record.Timestamp, c.Record.Source, c.Existing.Total)
+}
+
+// A Rejection is an imported record that was skipped as it is invalid.
+type Rejection struct {
+	Row int
+	Err error
+}
+
+func (r Rejection) Error() string {
+	return fmt.Sprintf("row %d: %v", r.Row, r.Err)
+}
+
+// The outcome of an import: every record is either inserted, a duplicate of a
+// stored snapshot, a conflict or rejected.
+type Result struct {
+	Inserted   int
+	Duplicates int
+	Conflicts  []Conflict
+	Rejected   []Rejection
+}
+
+// Import inserts the records of a raw export that are not stored yet. Records
+// are de-duplicated on their timestamp and source, see
+// model.SnapshotModel.ImportSnapshots. A malformed file stops the import, the
+// records before it staying imported.
+func Import(ctx context.Context, snapshots *model.SnapshotModel, r io.Reader, format string) (Result, error) {
+	var result Result
+
+	next, err := newDecoder(r, format)
+
+	if err != nil {
+		return result, err
+	}
+
+	records := make([]Record, 0, importBatch)
+	rows := make([]int, 0, importBatch)
+
+	flush := func() error {
+		batch := make([]model.Snapshot, len(records))
+
+		for i, record := range records {
+			batch[i] = record.snapshot()
+		}
+
+		imported, err := snapshots.ImportSnapshots(ctx, batch)
+
+		if err != nil {
+			return fmt.Errorf("failed to import snapshots: %w", err)
+		}
+
+		result.Inserted += imported.Inserted
+		result.Duplicates += imported.Duplicates
+
+		for _, c := range imported.Conflicts {
+			result.Conflicts = append(result.Conflicts, Conflict{Row: rows[c.Index], Record: records[c.Index], Existing: c.Existing})
+		}
+
+		records, rows = records[:0], rows[:0]
+
+		return nil
+	}
+
+	for row := 1; ; row++ {
+		record, err := next()
+
+		if err == io.EOF {
+			break
+		}
+
+		var typeErr *json.UnmarshalTypeError
+
+		if errors.As(err, &typeErr) || errors.Is(err, errInvalidField) {
+			result.Rejected = append(result.Rejected, Rejection{Row: row, Err: err})
+			continue
+		}
+
+		if err != nil {
+			return result, fmt.Errorf("malformed row %d: %w", row, err)
+		}
+
+		if err = record.validate(); err != nil {
+			result.Rejected = append(result.Rejected, Rejection{Row: row, Err: err})
+			continue
+		}
+
+		records, rows = append(records, record), append(rows, row)
+
+		if len(records) == importBatch {
+			if err = flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, flush()
+}
+
+// A decoder returns the next record of a file, io.EOF after the last. Records
+// with a field of the wrong type can be skipped, other errors are fatal.
+type decoder func() (Record, error)
+
+var errInvalidField = errors.New("invalid field")
+
+func newDecoder(r io.Reader, format string) (decoder, error) {
+	switch format {
+	case FormatCSV:
+		return csvDecoder(r)
+	case FormatJSON:
+		return jsonDecoder(r)
+	case FormatNDJSON:
+		d := json.NewDecoder(r)
+
+		return func() (record Record, err error) {
+			err = d.Decode(&record)
+			return record, err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+func jsonDecoder(r io.Reader) (decoder, error) {
+	d := json.NewDecoder(r)
+
+	if token, err := d.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New("a JSON export must be an array")
+	}
+
+	return func() (record Record, err error) {
+		if !d.More() {
+			if _, err = d.Token(); err != nil {
+				return record, err
+			}
+
+			return record, io.EOF
+		}
+
+		err = d.Decode(&record)
+
+		return record, err
+	}, nil
+}
+
+// Read CSV rows by the names of their header, which must include the
+// timestamp and counts. The other columns are empty if missing.
+func csvDecoder(r io.Reader) (decoder, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, name := range []string{"timestamp", "sent", "received", "total"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %s, only raw snapshots are imported", name)
+		}
+	}
+
+	return func() (record Record, err error) {
+		fields, err := reader.Read()
+
+		if err != nil {
+			return record, err
+		}
+
+		text := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return fields[i]
+			}
+
+			return ""
+		}
+
+		if record.Timestamp, err = strconv.ParseInt(text("timestamp"), 10, 64); err != nil {
+			return record, fmt.Errorf("%w timestamp: %v", errInvalidField, err)
+		}
+
+		for name, count := range map[string]*uint64{"sent": &record.Sent, "received": &record.Received, "total": &record.Total} {
+			if *count, err = strconv.ParseUint(text(name), 10, 64); err != nil {
+				return record, fmt.Errorf("%w %s: %v", errInvalidField, name, err)
+			}
+		}
+
+		record.Source, record.Interface, record.Host = text("source"), text("interface"), text("host")
+
+		return record, nil
+	}, nil
+}
@@ -0,0 +1,300 @@
+// Package transfer moves snapshots in and out of the database as files.
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+)
+
+// The formats snapshots are exported and imported in.
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"   // a single array
+	FormatNDJSON = "ndjson" // an object per line
+)
+
+var Formats = []string{FormatCSV, FormatJSON, FormatNDJSON}
+
+// FormatOf guesses the format of a file by its extension, NDJSON if unknown.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatNDJSON
+	}
+}
+
+// A Record is a raw snapshot as exported.
+type Record struct {
+	Timestamp int64  `json:"timestamp"`
+	Source    string `json:"source"`
+	Interface string `json:"interface"`
+	Host      string `json:"host"`
+	Sent      uint64 `json:"sent"`
+	Received  uint64 `json:"received"`
+	Total     uint64 `json:"total"`
+}
+
+var recordHeader = []string{"timestamp", "source", "interface", "host", "sent", "received", "total"}
+
+func newRecord(s model.Snapshot) Record {
+	return Record{
+		Timestamp: s.Timestamp,
+		Source:    s.Source,
+		Interface: s.Interface,
+		Host:      s.Host,
+		Sent:      s.Sent,
+		Received:  s.Received,
+		Total:     s.Total,
+	}
+}
+
+func (r Record) row() []string {
+	return []string{
+		strconv.FormatInt(r.Timestamp, 10),
+		r.Source,
+		r.Interface,
+		r.Host,
+		strconv.FormatUint(r.Sent, 10),
+		strconv.FormatUint(r.Received, 10),
+		strconv.FormatUint(r.Total, 10),
+	}
+}
+
+func (r Record) snapshot() model.Snapshot {
+	return model.Snapshot{
+		Timestamp: r.Timestamp,
+		Source:    r.Source,
+		Interface: r.Interface,
+		Host:      r.Host,
+		Stat:      model.Stat{Sent: r.Sent, Received: r.Received, Total: r.Total},
+	}
+}
+
+// Check a record could have been captured, SQLite storing signed integers.
+func (r Record) validate() error {
+	switch {
+	case r.Timestamp <= 0:
+		return errors.New("timestamp must be positive")
+	case r.Sent > math.MaxInt64 || r.Received > math.MaxInt64 || r.Total > math.MaxInt64:
+		return errors.New("counts must fit in 63 bits")
+	case r.Total != r.Sent+r.Received:
+		return fmt.Errorf("total %d is not sent %d plus received %d", r.Total, r.Sent, r.Received)
+	}
+
+	return nil
+}
+
+// A Bucket sums up the snapshots of one hour, day or month as exported.
+type Bucket struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Snapshots int       `json:"snapshots"`
+	Sent      uint64    `json:"sent"`
+	Received  uint64    `json:"received"`
+	Total     uint64    `json:"total"`
+}
+
+var bucketHeader = []string{"start", "end", "snapshots", "sent", "received", "total"}
+
+func (b Bucket) row() []string {
+	return []string{
+		b.Start.Format(time.RFC3339),
+		b.End.Format(time.RFC3339),
+		strconv.Itoa(b.Snapshots),
+		strconv.FormatUint(b.Sent, 10),
+		strconv.FormatUint(b.Received, 10),
+		strconv.FormatUint(b.Total, 10),
+	}
+}
+
+// Options select what is exported and how.
+type Options struct {
+	Format string
+	Bucket string // model.PeriodHour, PeriodDay or PeriodMonth, empty for raw snapshots
+	Filter model.SnapshotFilter
+}
+
+// Export writes the snapshots, or their buckets, matching the filter,
+// returning how many rows were written.
+func Export(ctx context.Context, snapshots *model.SnapshotModel, w io.Writer, opts Options) (int, error) {
+	if opts.Bucket != "" {
+		return exportBuckets(ctx, snapshots, w, opts)
+	}
+
+	e, err := newEncoder(w, opts.Format, recordHeader)
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = snapshots.EachSnapshot(ctx, opts.Filter, func(s model.Snapshot) error {
+		record := newRecord(s)
+
+		return e.encode(record, record.row())
+	})
+
+	if err != nil {
+		return e.n, fmt.Errorf("failed to export snapshots: %w", err)
+	}
+
+	return e.n, e.close()
+}
+
+func exportBuckets(ctx context.Context, snapshots *model.SnapshotModel, w io.Writer, opts Options) (int, error) {
+	layout, ok := model.PeriodLayout(opts.Bucket)
+
+	if !ok {
+		return 0, fmt.Errorf("unknown bucket %q, must be hour, day or month", opts.Bucket)
+	}
+
+	e, err := newEncoder(w, opts.Format, bucketHeader)
+
+	if err != nil {
+		return 0, err
+	}
+
+	to := opts.Filter.To
+
+	if to == 0 {
+		to = math.MaxInt64
+	}
+
+	stats, err := snapshots.GetPeriodStats(ctx, opts.Filter.From, to, opts.Bucket, opts.Filter.Interface)
+
+	if err != nil && !errors.Is(err, model.ErrNoRows) {
+		return 0, fmt.Errorf("failed to sum up snapshots: %w", err)
+	}
+
+	for _, s := range stats {
+		start, err := time.ParseInLocation(layout, s.Period, snapshots.Location())
+
+		if err != nil {
+			return e.n, fmt.Errorf("failed to parse period %q: %w", s.Period, err)
+		}
+
+		bucket := Bucket{
+			Start:     start,
+			End:       bucketEnd(start, opts.Bucket),
+			Snapshots: s.Snapshots,
+			Sent:      s.Sent,
+			Received:  s.Received,
+			Total:     s.Total,
+		}
+
+		if err = e.encode(bucket, bucket.row()); err != nil {
+			return e.n, fmt.Errorf("failed to export buckets: %w", err)
+		}
+	}
+
+	return e.n, e.close()
+}
+
+func bucketEnd(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case model.PeriodHour:
+		return start.Add(time.Hour)
+	case model.PeriodDay:
+		return start.AddDate(0, 0, 1)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// An encoder writes rows in one of the formats, counting them.
+type encoder struct {
+	format string
+	w      *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+	n      int
+}
+
+func newEncoder(w io.Writer, format string, header []string) (*encoder, error) {
+	buffered := bufio.NewWriter(w)
+	e := &encoder{format: format, w: buffered}
+
+	switch format {
+	case FormatCSV:
+		e.csv = csv.NewWriter(buffered)
+
+		return e, e.csv.Write(header)
+	case FormatJSON:
+		return e, nil
+	case FormatNDJSON:
+		e.json = json.NewEncoder(buffered)
+
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Write a row, as v in JSON and as its fields in CSV.
+func (e *encoder) encode(v interface{}, fields []string) error {
+	var err error
+
+	switch e.format {
+	case FormatCSV:
+		err = e.csv.Write(fields)
+	case FormatJSON:
+		var body []byte
+
+		separator := ",\n"
+
+		if e.n == 0 {
+			separator = "[\n"
+		}
+
+		if body, err = json.Marshal(v); err == nil {
+			e.w.WriteString(separator)
+			_, err = e.w.Write(body)
+		}
+	default:
+		err = e.json.Encode(v)
+	}
+
+	if err == nil {
+		e.n++
+	}
+
+	return err
+}
+
+func (e *encoder) close() error {
+	switch e.format {
+	case FormatCSV:
+		e.csv.Flush()
+
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	case FormatJSON:
+		end := "\n]\n"
+
+		if e.n == 0 {
+			end = "[]\n"
+		}
+
+		if _, err := e.w.WriteString(end); err != nil {
+			return err
+		}
+	}
+
+	return e.w.Flush()
+}
@@ -0,0 +1,199 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omarabdelaz1z/go-monitor/internal/model"
+)
+
+func newTestModel(t *testing.T) *model.SnapshotModel {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = model.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return model.NewSnapshotModel(db)
+}
+
+var day = time.Date(2022, 8, 1, 0, 0, 0, 0, time.Local)
+
+func seed(t *testing.T, snapshots *model.SnapshotModel) {
+	t.Helper()
+
+	err := snapshots.InsertSnapshots(context.Background(), []model.Snapshot{
+		{Timestamp: day.Add(time.Hour).Unix(), Stat: model.Stat{Sent: 1, Received: 2, Total: 3}},
+		{Timestamp: day.Add(time.Hour).Unix(), Host: "web-1", Stat: model.Stat{Sent: 4, Received: 5, Total: 9}},
+		{Timestamp: day.Add(2 * time.Hour).Unix(), Source: "snmp:core", Interface: "Gi0/1", Stat: model.Stat{Sent: 100, Received: 100, Total: 200}},
+		{Timestamp: day.Add(2 * time.Hour).Unix(), Source: "snmp:core", Interface: `Gi0/2, "uplink"`, Stat: model.Stat{Sent: 7, Received: 0, Total: 7}},
+		{Timestamp: day.AddDate(0, 0, 1).Unix(), Source: "office", Stat: model.Stat{Sent: math.MaxInt64 - 1, Received: 1, Total: math.MaxInt64}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func export(t *testing.T, snapshots *model.SnapshotModel, opts Options) (string, int) {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	n, err := Export(context.Background(), snapshots, &out, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out.String(), n
+}
+
+func TestRoundTrip(t *testing.T) {
+	source := newTestModel(t)
+	seed(t, source)
+
+	for _, format := range Formats {
+		exported, n := export(t, source, Options{Format: format})
+
+		if n != 5 {
+			t.Fatalf("%s: got %d rows, expected 5", format, n)
+		}
+
+		target := newTestModel(t)
+
+		result, err := Import(context.Background(), target, strings.NewReader(exported), format)
+
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if result.Inserted != 5 || result.Duplicates != 0 || len(result.Conflicts) != 0 || len(result.Rejected) != 0 {
+			t.Errorf("%s: got %+v, expected every snapshot to be inserted", format, result)
+		}
+
+		if reexported, _ := export(t, target, Options{Format: format}); reexported != exported {
+			t.Errorf("%s: got\n%s\nexpected the import to export as\n%s", format, reexported, exported)
+		}
+
+		// Importing an export again changes nothing.
+		if result, err = Import(context.Background(), target, strings.NewReader(exported), format); err != nil || result.Inserted != 0 || result.Duplicates != 5 {
+			t.Errorf("%s: got %+v, %v, expected only duplicates", format, result, err)
+		}
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	snapshots := newTestModel(t)
+	seed(t, snapshots)
+
+	at := day.Add(time.Hour).Unix()
+
+	input := strings.Join([]string{
+		`{"timestamp": ` + strconv.FormatInt(at, 10) + `, "source": "local", "sent": 1, "received": 2, "total": 3}`,
+		`{"timestamp": ` + strconv.FormatInt(at, 10) + `, "source": "local", "sent": 2, "received": 2, "total": 4}`,
+		`{"timestamp": ` + strconv.FormatInt(at, 10) + `, "source": "other", "sent": 2, "received": 2, "total": 4}`,
+		`{"timestamp": ` + strconv.FormatInt(at, 10) + `, "source": "other", "sent": 2, "received": 2, "total": 4}`,
+		`{"timestamp": 0, "sent": 1, "received": 1, "total": 2}`,
+		`{"timestamp": ` + strconv.FormatInt(at, 10) + `, "sent": 1, "received": 1, "total": 3}`,
+		`{"timestamp": ` + strconv.FormatInt(at, 10) + `, "sent": -1, "received": 1, "total": 0}`,
+		`{"timestamp": ` + strconv.FormatInt(at, 10) + `, "sent": 9223372036854775808, "received": 0, "total": 9223372036854775808}`,
+	}, "\n")
+
+	result, err := Import(context.Background(), snapshots, strings.NewReader(input), FormatNDJSON)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Inserted != 1 || result.Duplicates != 2 {
+		t.Errorf("got %+v, expected the new source to be inserted once", result)
+	}
+
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Row != 2 || result.Conflicts[0].Existing.Total != 3 {
+		t.Errorf("got %+v, expected row 2 to conflict with the stored 3 bytes", result.Conflicts)
+	}
+
+	rejected := []int{}
+
+	for _, r := range result.Rejected {
+		rejected = append(rejected, r.Row)
+	}
+
+	if len(rejected) != 4 || rejected[0] != 5 || rejected[3] != 8 {
+		t.Errorf("got %v, expected rows 5 to 8 to be rejected", result.Rejected)
+	}
+
+	if _, err = Import(context.Background(), snapshots, strings.NewReader(input+"\n{"), FormatNDJSON); err == nil {
+		t.Error("expected a truncated file to fail")
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	snapshots := newTestModel(t)
+
+	input := "total,received,sent,timestamp,comment\n3,2,1,1659312000,first\n4,2,2,x,second\n"
+
+	result, err := Import(context.Background(), snapshots, strings.NewReader(input), FormatCSV)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Inserted != 1 || len(result.Rejected) != 1 || result.Rejected[0].Row != 2 {
+		t.Errorf("got %+v, expected the columns to be read by name and the second row rejected", result)
+	}
+
+	buckets, _ := export(t, snapshots, Options{Format: FormatCSV, Bucket: model.PeriodDay})
+
+	if _, err = Import(context.Background(), snapshots, strings.NewReader(buckets), FormatCSV); err == nil {
+		t.Error("expected a bucket export not to be imported")
+	}
+}
+
+func TestExportFilters(t *testing.T) {
+	snapshots := newTestModel(t)
+	seed(t, snapshots)
+
+	all := ""
+	exported, n := export(t, snapshots, Options{
+		Format: FormatCSV,
+		Filter: model.SnapshotFilter{From: day.Unix(), To: day.AddDate(0, 0, 1).Unix(), Interface: &all},
+	})
+
+	if n != 2 || strings.Contains(exported, "Gi0") || strings.Contains(exported, "office") {
+		t.Errorf("got\n%s\nexpected the sums of the first day only", exported)
+	}
+
+	exported, n = export(t, snapshots, Options{Format: FormatJSON, Bucket: model.PeriodDay})
+
+	var buckets []Bucket
+
+	if err := json.Unmarshal([]byte(exported), &buckets); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 || len(buckets) != 2 || buckets[0].Snapshots != 2 || buckets[0].Total != 12 || !buckets[0].Start.Equal(day) || !buckets[0].End.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("got %+v, expected two days of the sums", buckets)
+	}
+
+	if exported, _ = export(t, newTestModel(t), Options{Format: FormatJSON}); exported != "[]\n" {
+		t.Errorf("got %q, expected an empty array", exported)
+	}
+}
@@ -0,0 +1,185 @@
+package monitoor
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultCgroupRoot = "/sys/fs/cgroup"
+
+var unitSuffixes = []string{".service", ".scope", ".socket", ".mount", ".swap"}
+
+// Cgroup parents used by container runtimes that do not go through systemd.
+var containerParents = []string{"docker", "libpod", "lxc", "machine"}
+
+// A CgroupStat represents the network usage of a cgroup v2 and the unit it belongs to.
+type CgroupStat struct {
+	Path string // relative to the cgroup root, e.g. /system.slice/nginx.service
+	Unit string // e.g. nginx.service or docker-<id>.scope
+	NetStat
+}
+
+// A CgroupCollector groups the usage of processes by their cgroup v2.
+type CgroupCollector struct {
+	root      string
+	processes *ProcessCollector
+}
+
+func NewCgroupCollector(root string, processes *ProcessCollector) *CgroupCollector {
+	return &CgroupCollector{root: root, processes: processes}
+}
+
+// The usage of every cgroup since the previous call.
+func (c *CgroupCollector) Collect() ([]CgroupStat, error) {
+	stats, err := c.processes.Collect()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Group(stats)
+}
+
+// Group already collected process stats by the cgroup holding each process.
+// Processes that left the hierarchy are accounted to the root cgroup.
+func (c *CgroupCollector) Group(stats []ProcessStat) ([]CgroupStat, error) {
+	members, err := CgroupMembers(c.root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := map[string]*CgroupStat{}
+
+	for _, stat := range stats {
+		p, ok := members[stat.PID]
+
+		if !ok {
+			p = "/"
+		}
+
+		if _, ok = byPath[p]; !ok {
+			byPath[p] = &CgroupStat{Path: p, Unit: UnitOf(p)}
+		}
+
+		byPath[p].BytesSent += stat.BytesSent
+		byPath[p].BytesRecv += stat.BytesRecv
+		byPath[p].BytesTotal += stat.BytesTotal
+	}
+
+	groups := make([]CgroupStat, 0, len(byPath))
+
+	for _, group := range byPath {
+		groups = append(groups, *group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Path < groups[j].Path })
+
+	return groups, nil
+}
+
+// Map every pid under a cgroup v2 root to the cgroup holding it.
+func CgroupMembers(root string) (map[int32]string, error) {
+	members := map[int32]string{}
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+
+			return nil // cgroups come and go while walking
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+
+		if err != nil {
+			return err
+		}
+
+		pids, err := readCgroupProcs(filepath.Join(p, "cgroup.procs"))
+
+		if err != nil {
+			return nil
+		}
+
+		for _, pid := range pids {
+			members[pid] = path.Clean("/" + filepath.ToSlash(rel))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk cgroup root %s: %w", root, err)
+	}
+
+	return members, nil
+}
+
+func readCgroupProcs(name string) ([]int32, error) {
+	file, err := os.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var pids []int32
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		pid, err := strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 32)
+
+		if err != nil {
+			continue
+		}
+
+		pids = append(pids, int32(pid))
+	}
+
+	return pids, scanner.Err()
+}
+
+// The systemd unit or container a cgroup path belongs to, found by walking up
+// from the leaf. Paths outside of any unit fall back to their closest slice.
+func UnitOf(cgroup string) string {
+	parts := strings.Split(strings.Trim(cgroup, "/"), "/")
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		for _, suffix := range unitSuffixes {
+			if strings.HasSuffix(parts[i], suffix) {
+				return parts[i]
+			}
+		}
+
+		if i > 0 {
+			for _, parent := range containerParents {
+				if parts[i-1] == parent {
+					return parent + "/" + parts[i]
+				}
+			}
+		}
+	}
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		if strings.HasSuffix(parts[i], ".slice") {
+			return parts[i]
+		}
+	}
+
+	return "-.slice"
+}
@@ -0,0 +1,83 @@
+package monitoor
+
+import "testing"
+
+func TestCgroupMembers(t *testing.T) {
+	members, err := CgroupMembers("testdata/cgroup")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int32]string{
+		1:    "/",
+		120:  "/system.slice/nginx.service",
+		121:  "/system.slice/nginx.service",
+		300:  "/system.slice/docker-4f1c2a9e.scope/init",
+		400:  "/docker/9b8e7d",
+		1000: "/user.slice/user-1000.slice/session-2.scope",
+	}
+
+	if len(members) != len(expected) {
+		t.Errorf("got %d members, expected %d", len(members), len(expected))
+	}
+
+	for pid, cgroup := range expected {
+		if members[pid] != cgroup {
+			t.Errorf("pid %d: got %q, expected %q", pid, members[pid], cgroup)
+		}
+	}
+}
+
+func TestUnitOf(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"/", "-.slice"},
+		{"/system.slice/nginx.service", "nginx.service"},
+		{"/system.slice/docker-4f1c2a9e.scope/init", "docker-4f1c2a9e.scope"},
+		{"/docker/9b8e7d", "docker/9b8e7d"},
+		{"/user.slice/user-1000.slice", "user-1000.slice"},
+		{"/user.slice/user-1000.slice/session-2.scope", "session-2.scope"},
+	}
+
+	for _, v := range table {
+		if out := UnitOf(v.in); out != v.out {
+			t.Errorf("UnitOf(%q) = %q, want %q", v.in, out, v.out)
+		}
+	}
+}
+
+func TestCgroupCollectorGroup(t *testing.T) {
+	collector := NewCgroupCollector("testdata/cgroup", nil)
+
+	groups, err := collector.Group([]ProcessStat{
+		{PID: 120, NetStat: NetStat{BytesSent: 10, BytesRecv: 20, BytesTotal: 30}},
+		{PID: 121, NetStat: NetStat{BytesSent: 1, BytesRecv: 2, BytesTotal: 3}},
+		{PID: 300, NetStat: NetStat{BytesSent: 5, BytesRecv: 5, BytesTotal: 10}},
+		{PID: 9999, NetStat: NetStat{BytesSent: 7, BytesRecv: 0, BytesTotal: 7}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byUnit := map[string]NetStat{}
+
+	for _, group := range groups {
+		byUnit[group.Unit] = group.NetStat
+	}
+
+	if stat := byUnit["nginx.service"]; stat.BytesSent != 11 || stat.BytesRecv != 22 || stat.BytesTotal != 33 {
+		t.Errorf("nginx.service: got %+v, expected 11 22 33", stat)
+	}
+
+	if stat := byUnit["docker-4f1c2a9e.scope"]; stat.BytesTotal != 10 {
+		t.Errorf("docker-4f1c2a9e.scope: got %d, expected 10", stat.BytesTotal)
+	}
+
+	if stat := byUnit["-.slice"]; stat.BytesTotal != 7 {
+		t.Errorf("-.slice: got %d, expected 7", stat.BytesTotal)
+	}
+}
@@ -0,0 +1,213 @@
+package monitoor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EndpointSourceConntrack = "conntrack"
+	EndpointSourceSockDiag  = "sockdiag"
+)
+
+var ErrNoAccounting = errors.New("conntrack accounting is disabled, set net.netfilter.nf_conntrack_acct=1")
+
+// An Endpoint identifies a remote peer.
+type Endpoint struct {
+	Protocol string
+	Address  string
+	Port     uint16
+}
+
+func (e Endpoint) String() string {
+	return e.Protocol + "/" + net.JoinHostPort(e.Address, strconv.Itoa(int(e.Port)))
+}
+
+// An EndpointStat represents the usage exchanged with a remote endpoint.
+type EndpointStat struct {
+	Endpoint
+	NetStat
+}
+
+// The n endpoints with the highest total usage, busiest first.
+func TopEndpoints(stats map[Endpoint]NetStat, n int) []EndpointStat {
+	top := make([]EndpointStat, 0, len(stats))
+
+	for endpoint, stat := range stats {
+		if stat.BytesTotal > 0 {
+			top = append(top, EndpointStat{Endpoint: endpoint, NetStat: stat})
+		}
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].BytesTotal == top[j].BytesTotal {
+			return top[i].Endpoint.String() < top[j].Endpoint.String()
+		}
+
+		return top[i].BytesTotal > top[j].BytesTotal
+	})
+
+	if n >= 0 && len(top) > n {
+		top = top[:n]
+	}
+
+	return top
+}
+
+// A connection tracked by the kernel, with its counters since it was created.
+type conntrackEntry struct {
+	key string
+	Endpoint
+	NetStat
+}
+
+// Parse /proc/net/nf_conntrack. The side of a connection that is not local is
+// the remote endpoint; bytes flowing towards it are counted as sent.
+func parseConntrack(r io.Reader, isLocal func(net.IP) bool) ([]conntrackEntry, error) {
+	var entries []conntrackEntry
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 4 {
+			continue
+		}
+
+		var tuples [2]map[string]string
+		tuple := -1
+
+		for _, field := range fields[3:] {
+			key, value, ok := strings.Cut(field, "=")
+
+			if !ok {
+				continue
+			}
+
+			if key == "src" && tuple < 1 {
+				tuple++
+				tuples[tuple] = map[string]string{}
+			}
+
+			if tuple >= 0 {
+				if _, ok = tuples[tuple][key]; !ok {
+					tuples[tuple][key] = value
+				}
+			}
+		}
+
+		if tuple < 1 {
+			continue
+		}
+
+		orig, reply := tuples[0], tuples[1]
+
+		if _, ok := orig["bytes"]; !ok {
+			return nil, ErrNoAccounting
+		}
+
+		origBytes, err := strconv.ParseUint(orig["bytes"], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid conntrack bytes %q: %w", orig["bytes"], err)
+		}
+
+		replyBytes, err := strconv.ParseUint(reply["bytes"], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid conntrack bytes %q: %w", reply["bytes"], err)
+		}
+
+		remote, port, sent, recv := orig["dst"], orig["dport"], origBytes, replyBytes
+
+		// Inbound connections are originated by the remote side.
+		if src := net.ParseIP(orig["src"]); src != nil && !isLocal(src) && isLocal(net.ParseIP(orig["dst"])) {
+			remote, port, sent, recv = orig["src"], orig["sport"], replyBytes, origBytes
+		}
+
+		portNumber, _ := strconv.ParseUint(port, 10, 16)
+
+		entries = append(entries, conntrackEntry{
+			key: strings.Join([]string{fields[2], orig["src"], orig["sport"], orig["dst"], orig["dport"]}, " "),
+			Endpoint: Endpoint{
+				Protocol: fields[2],
+				Address:  remote,
+				Port:     uint16(portNumber),
+			},
+			NetStat: NetStat{BytesSent: sent, BytesRecv: recv, BytesTotal: sent + recv},
+		})
+	}
+
+	return entries, scanner.Err()
+}
+
+// A Resolver looks up host names of addresses and caches the answers. Failed
+// lookups are cached shortly, those cut off by the caller not at all.
+type Resolver struct {
+	ttl      time.Duration
+	negative time.Duration // how long failed lookups are cached
+	lookup   func(ctx context.Context, addr string) ([]string, error)
+
+	mu    sync.Mutex
+	cache map[string]resolved
+}
+
+type resolved struct {
+	name    string
+	expires time.Time
+}
+
+func NewResolver(ttl time.Duration) *Resolver {
+	negative := time.Minute
+
+	if ttl < negative {
+		negative = ttl
+	}
+
+	return &Resolver{
+		ttl:      ttl,
+		negative: negative,
+		lookup:   net.DefaultResolver.LookupAddr,
+		cache:    map[string]resolved{},
+	}
+}
+
+// The host name of addr, or an empty string if it has none.
+func (r *Resolver) Lookup(ctx context.Context, addr string) string {
+	r.mu.Lock()
+	entry, ok := r.cache[addr]
+	r.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.name
+	}
+
+	names, err := r.lookup(ctx, addr)
+
+	// The deadline or cancellation of the caller says nothing about addr.
+	if err != nil && ctx.Err() != nil {
+		return ""
+	}
+
+	entry = resolved{expires: time.Now().Add(r.negative)}
+
+	if err == nil && len(names) > 0 {
+		entry = resolved{name: strings.TrimSuffix(names[0], "."), expires: time.Now().Add(r.ttl)}
+	}
+
+	r.mu.Lock()
+	r.cache[addr] = entry
+	r.mu.Unlock()
+
+	return entry.name
+}
@@ -0,0 +1,131 @@
+//go:build linux
+
+package monitoor
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// An EndpointCollector attributes usage to remote endpoints, either from
+// conntrack accounting or from the TCP counters of socket diagnostics.
+type EndpointCollector struct {
+	source   string
+	procRoot string
+
+	primed   bool
+	previous map[string]NetStat // connection -> cumulative counters
+}
+
+func NewEndpointCollector(source string) (*EndpointCollector, error) {
+	if source != EndpointSourceConntrack && source != EndpointSourceSockDiag {
+		return nil, fmt.Errorf("unknown endpoint source %q", source)
+	}
+
+	return &EndpointCollector{
+		source:   source,
+		procRoot: "/proc",
+		previous: map[string]NetStat{},
+	}, nil
+}
+
+// The usage of every remote endpoint since the previous call.
+// The first call only records a baseline and reports no traffic.
+func (c *EndpointCollector) Collect() (map[Endpoint]NetStat, error) {
+	entries, err := c.connections()
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[Endpoint]NetStat{}
+	current := make(map[string]NetStat, len(entries))
+
+	for _, entry := range entries {
+		current[entry.key] = entry.NetStat
+
+		if !c.primed {
+			continue
+		}
+
+		delta := socketDelta(entry.NetStat, c.previous[entry.key])
+
+		if delta.BytesTotal == 0 {
+			continue
+		}
+
+		stat := stats[entry.Endpoint]
+		stat.BytesSent += delta.BytesSent
+		stat.BytesRecv += delta.BytesRecv
+		stat.BytesTotal += delta.BytesTotal
+		stats[entry.Endpoint] = stat
+	}
+
+	c.previous = current
+	c.primed = true
+
+	return stats, nil
+}
+
+func (c *EndpointCollector) connections() ([]conntrackEntry, error) {
+	if c.source == EndpointSourceConntrack {
+		file, err := os.Open(filepath.Join(c.procRoot, "net", "nf_conntrack"))
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to open conntrack table: %w", err)
+		}
+
+		defer file.Close()
+
+		local, err := localAddresses()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return parseConntrack(file, func(ip net.IP) bool { return ip != nil && local[ip.String()] })
+	}
+
+	sockets, err := dumpTCPSockets()
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]conntrackEntry, 0, len(sockets))
+
+	for _, s := range sockets {
+		if s.Remote.IsUnspecified() || s.Inode == 0 {
+			continue // listening or timing out
+		}
+
+		entries = append(entries, conntrackEntry{
+			key:      strconv.FormatUint(s.Inode, 10),
+			Endpoint: Endpoint{Protocol: "tcp", Address: s.Remote.String(), Port: s.RemotePort},
+			NetStat:  NetStat{BytesSent: s.Sent, BytesRecv: s.Recv, BytesTotal: s.Sent + s.Recv},
+		})
+	}
+
+	return entries, nil
+}
+
+func localAddresses() (map[string]bool, error) {
+	addrs, err := net.InterfaceAddrs()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local addresses: %w", err)
+	}
+
+	local := map[string]bool{}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			local[ipNet.IP.String()] = true
+		}
+	}
+
+	return local, nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package monitoor
+
+import "fmt"
+
+// An EndpointCollector attributes usage to remote endpoints.
+type EndpointCollector struct{}
+
+func NewEndpointCollector(source string) (*EndpointCollector, error) {
+	if source != EndpointSourceConntrack && source != EndpointSourceSockDiag {
+		return nil, fmt.Errorf("unknown endpoint source %q", source)
+	}
+
+	return &EndpointCollector{}, nil
+}
+
+func (c *EndpointCollector) Collect() (map[Endpoint]NetStat, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,136 @@
+package monitoor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const conntrackTable = `ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.2 dst=93.184.216.34 sport=51234 dport=443 packets=10 bytes=1200 src=93.184.216.34 dst=10.0.0.2 sport=443 dport=51234 packets=12 bytes=15000 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 tcp      6 431999 ESTABLISHED src=203.0.113.9 dst=10.0.0.2 sport=40000 dport=22 packets=30 bytes=3000 src=10.0.0.2 dst=203.0.113.9 sport=22 dport=40000 packets=25 bytes=9000 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 udp      17 29 src=10.0.0.2 dst=1.1.1.1 sport=53000 dport=53 packets=1 bytes=60 src=1.1.1.1 dst=10.0.0.2 sport=53 dport=53000 packets=1 bytes=120 mark=0 zone=0 use=2
+`
+
+func isTestLocal(ip net.IP) bool {
+	return ip != nil && ip.String() == "10.0.0.2"
+}
+
+func TestParseConntrack(t *testing.T) {
+	entries, err := parseConntrack(strings.NewReader(conntrackTable), isTestLocal)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []EndpointStat{
+		{Endpoint{"tcp", "93.184.216.34", 443}, NetStat{BytesSent: 1200, BytesRecv: 15000, BytesTotal: 16200}},
+		{Endpoint{"tcp", "203.0.113.9", 40000}, NetStat{BytesSent: 9000, BytesRecv: 3000, BytesTotal: 12000}},
+		{Endpoint{"udp", "1.1.1.1", 53}, NetStat{BytesSent: 60, BytesRecv: 120, BytesTotal: 180}},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("got %d entries, expected %d", len(entries), len(expected))
+	}
+
+	for i, e := range expected {
+		if entries[i].Endpoint != e.Endpoint || entries[i].NetStat != e.NetStat {
+			t.Errorf("entry %d: got %v %+v, expected %v %+v", i, entries[i].Endpoint, entries[i].NetStat, e.Endpoint, e.NetStat)
+		}
+	}
+}
+
+func TestParseConntrackWithoutAccounting(t *testing.T) {
+	line := "ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.2 dst=93.184.216.34 sport=51234 dport=443 src=93.184.216.34 dst=10.0.0.2 sport=443 dport=51234 [ASSURED] mark=0 use=1\n"
+
+	if _, err := parseConntrack(strings.NewReader(line), isTestLocal); !errors.Is(err, ErrNoAccounting) {
+		t.Errorf("got %v, expected ErrNoAccounting", err)
+	}
+}
+
+func TestTopEndpoints(t *testing.T) {
+	stats := map[Endpoint]NetStat{
+		{"tcp", "93.184.216.34", 443}: {BytesTotal: 500},
+		{"tcp", "140.82.112.3", 443}:  {BytesTotal: 900},
+		{"udp", "1.1.1.1", 53}:        {BytesTotal: 10},
+	}
+
+	top := TopEndpoints(stats, 2)
+
+	if len(top) != 2 || top[0].Address != "140.82.112.3" || top[1].Address != "93.184.216.34" {
+		t.Errorf("got %+v, expected 140.82.112.3 then 93.184.216.34", top)
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	lookups := 0
+
+	resolver := NewResolver(time.Minute)
+	resolver.lookup = func(ctx context.Context, addr string) ([]string, error) {
+		lookups++
+
+		if addr == "192.0.2.1" {
+			return nil, errors.New("no such host")
+		}
+
+		return []string{"example.com."}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if name := resolver.Lookup(context.Background(), "93.184.216.34"); name != "example.com" {
+			t.Errorf("got %q, expected example.com", name)
+		}
+
+		if name := resolver.Lookup(context.Background(), "192.0.2.1"); name != "" {
+			t.Errorf("got %q, expected no name", name)
+		}
+	}
+
+	if lookups != 2 {
+		t.Errorf("got %d lookups, expected answers to be cached", lookups)
+	}
+}
+
+func TestResolverFailures(t *testing.T) {
+	lookups := 0
+
+	resolver := NewResolver(time.Hour)
+	resolver.lookup = func(ctx context.Context, addr string) ([]string, error) {
+		lookups++
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, errors.New("no such host")
+	}
+
+	// Lookups cut off by the caller are not cached.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resolver.Lookup(canceled, "192.0.2.1")
+
+	if _, ok := resolver.cache["192.0.2.1"]; ok {
+		t.Error("expected a canceled lookup not to be cached")
+	}
+
+	resolver.Lookup(context.Background(), "192.0.2.1")
+
+	if entry := resolver.cache["192.0.2.1"]; time.Until(entry.expires) > time.Minute {
+		t.Errorf("got a failure cached until %v, expected a minute at most", entry.expires)
+	}
+
+	// Failures are looked up again once their short ttl passed.
+	resolver.negative = 0
+	resolver.cache = map[string]resolved{}
+
+	resolver.Lookup(context.Background(), "192.0.2.1")
+	resolver.Lookup(context.Background(), "192.0.2.1")
+
+	if lookups != 4 {
+		t.Errorf("got %d lookups, expected 4", lookups)
+	}
+}
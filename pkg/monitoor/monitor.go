@@ -24,3 +24,24 @@ func Brief() (*NetStat, error) {
 		BytesTotal: stats[0].BytesSent + stats[0].BytesRecv,
 	}, nil
 }
+
+// The network statistics of every interface at the current time, by name.
+func Interfaces() (map[string]NetStat, error) {
+	stats, err := net.IOCounters(true)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture interface stats: %v", err)
+	}
+
+	out := make(map[string]NetStat, len(stats))
+
+	for _, s := range stats {
+		out[s.Name] = NetStat{
+			BytesSent:  s.BytesSent,
+			BytesRecv:  s.BytesRecv,
+			BytesTotal: s.BytesSent + s.BytesRecv,
+		}
+	}
+
+	return out, nil
+}
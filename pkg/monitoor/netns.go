@@ -0,0 +1,142 @@
+package monitoor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const DefaultNetnsRunRoot = "/var/run/netns"
+
+// An InterfaceStat represents the counters of a single network interface.
+type InterfaceStat struct {
+	Name string
+	NetStat
+}
+
+// A Netns identifies a network namespace and who it belongs to.
+type Netns struct {
+	Inode     uint64
+	Name      string // the bind mount name under /var/run/netns, if any
+	PID       int32  // the lowest pid living in the namespace, if any
+	Process   string
+	Container string // e.g. docker:4f1c2a9e0b7d, empty for non-container processes
+}
+
+// A label identifying a namespace to a human, the most specific first.
+func (n Netns) Label() string {
+	switch {
+	case n.Container != "":
+		return n.Container
+	case n.Name != "":
+		return n.Name
+	case n.PID != 0:
+		return fmt.Sprintf("%s[%d]", n.Process, n.PID)
+	default:
+		return fmt.Sprintf("net:[%d]", n.Inode)
+	}
+}
+
+// A NetnsStat represents the usage of every interface inside a network namespace.
+type NetnsStat struct {
+	Netns
+	Interfaces []InterfaceStat
+}
+
+// The usage of every interface in the namespace but the loopback.
+func (n NetnsStat) Sum() NetStat {
+	var sum NetStat
+
+	for _, iface := range n.Interfaces {
+		if iface.Name == "lo" {
+			continue
+		}
+
+		sum.BytesSent += iface.BytesSent
+		sum.BytesRecv += iface.BytesRecv
+		sum.BytesTotal += iface.BytesTotal
+	}
+
+	return sum
+}
+
+// The interface counters of a /proc/net/dev table.
+func parseNetDev(r io.Reader) ([]InterfaceStat, error) {
+	var stats []InterfaceStat
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		name, counters, ok := strings.Cut(scanner.Text(), ":")
+
+		if !ok {
+			continue // headers
+		}
+
+		fields := strings.Fields(counters)
+
+		if len(fields) < 9 {
+			return nil, fmt.Errorf("malformed net/dev line for %s", strings.TrimSpace(name))
+		}
+
+		recv, err := strconv.ParseUint(fields[0], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid received bytes %q: %w", fields[0], err)
+		}
+
+		sent, err := strconv.ParseUint(fields[8], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid sent bytes %q: %w", fields[8], err)
+		}
+
+		stats = append(stats, InterfaceStat{
+			Name:    strings.TrimSpace(name),
+			NetStat: NetStat{BytesSent: sent, BytesRecv: recv, BytesTotal: sent + recv},
+		})
+	}
+
+	return stats, scanner.Err()
+}
+
+// Scope prefixes used by container runtimes that go through systemd.
+var containerScopes = []struct{ prefix, runtime string }{
+	{"docker-", "docker"},
+	{"libpod-", "podman"},
+	{"crio-", "cri-o"},
+	{"cri-containerd-", "containerd"},
+}
+
+// The container a cgroup path belongs to, as runtime:short-id.
+func ContainerOf(cgroup string) string {
+	parts := strings.Split(strings.Trim(cgroup, "/"), "/")
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		if strings.HasSuffix(parts[i], ".scope") && !strings.Contains(parts[i], "-conmon-") {
+			id := strings.TrimSuffix(parts[i], ".scope")
+
+			for _, scope := range containerScopes {
+				if strings.HasPrefix(id, scope.prefix) {
+					return scope.runtime + ":" + shortID(strings.TrimPrefix(id, scope.prefix))
+				}
+			}
+		}
+
+		if i > 0 && parts[i-1] == "docker" {
+			return "docker:" + shortID(parts[i])
+		}
+	}
+
+	return ""
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+
+	return id
+}
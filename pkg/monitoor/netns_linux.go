@@ -0,0 +1,238 @@
+//go:build linux
+
+package monitoor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// A NetnsCollector reads interface counters inside every network namespace.
+//
+// Namespaces holding a process are read through /proc/<pid>/net/dev, which
+// needs no privileges. Named namespaces without any process are entered with
+// setns(2), which requires CAP_SYS_ADMIN.
+type NetnsCollector struct {
+	procRoot, runRoot string
+
+	previous map[uint64]map[string]NetStat // namespace inode -> interface -> counters
+}
+
+func NewNetnsCollector() *NetnsCollector {
+	return &NetnsCollector{
+		procRoot: "/proc",
+		runRoot:  DefaultNetnsRunRoot,
+		previous: map[uint64]map[string]NetStat{},
+	}
+}
+
+// The usage of every interface of every namespace since the previous call.
+// Namespaces seen for the first time only record a baseline.
+func (c *NetnsCollector) Collect() ([]NetnsStat, error) {
+	namespaces, err := ListNetns(c.procRoot, c.runRoot)
+
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[uint64]map[string]NetStat, len(namespaces))
+	stats := make([]NetnsStat, 0, len(namespaces))
+
+	for _, ns := range namespaces {
+		ifaces, err := c.readInterfaces(ns)
+
+		if err != nil {
+			continue // the namespace vanished or cannot be entered
+		}
+
+		previous, seen := c.previous[ns.Inode]
+		current[ns.Inode] = map[string]NetStat{}
+
+		stat := NetnsStat{Netns: ns}
+
+		for _, iface := range ifaces {
+			current[ns.Inode][iface.Name] = iface.NetStat
+
+			delta := NetStat{}
+
+			if seen {
+				delta = socketDelta(iface.NetStat, previous[iface.Name])
+			}
+
+			stat.Interfaces = append(stat.Interfaces, InterfaceStat{Name: iface.Name, NetStat: delta})
+		}
+
+		stats = append(stats, stat)
+	}
+
+	c.previous = current
+
+	return stats, nil
+}
+
+func (c *NetnsCollector) readInterfaces(ns Netns) ([]InterfaceStat, error) {
+	if ns.PID != 0 {
+		return readNetDev(filepath.Join(c.procRoot, strconv.Itoa(int(ns.PID)), "net", "dev"))
+	}
+
+	return readNetDevIn(filepath.Join(c.runRoot, ns.Name))
+}
+
+func readNetDev(path string) ([]InterfaceStat, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return parseNetDev(file)
+}
+
+// Read /proc/net/dev from inside the namespace bound at path.
+func readNetDevIn(path string) ([]InterfaceStat, error) {
+	target, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer target.Close()
+
+	runtime.LockOSThread()
+
+	origin, err := os.Open("/proc/thread-self/ns/net")
+
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+
+	defer origin.Close()
+
+	if err = unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("failed to enter namespace %s: %w", path, err)
+	}
+
+	stats, readErr := readNetDev("/proc/thread-self/net/dev")
+
+	// A thread that cannot go back stays locked, so the runtime discards it.
+	if err = unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET); err != nil {
+		return nil, fmt.Errorf("failed to restore network namespace: %w", err)
+	}
+
+	runtime.UnlockOSThread()
+
+	return stats, readErr
+}
+
+// Enumerate the network namespaces of every process and every named namespace.
+func ListNetns(procRoot, runRoot string) ([]Netns, error) {
+	entries, err := os.ReadDir(procRoot)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", procRoot, err)
+	}
+
+	byInode := map[uint64]*Netns{}
+
+	for _, entry := range entries {
+		pid, err := strconv.ParseInt(entry.Name(), 10, 32)
+
+		if err != nil {
+			continue
+		}
+
+		link, err := os.Readlink(filepath.Join(procRoot, entry.Name(), "ns", "net"))
+
+		if err != nil || !strings.HasPrefix(link, "net:[") {
+			continue
+		}
+
+		inode, err := strconv.ParseUint(strings.TrimSuffix(link[len("net:["):], "]"), 10, 64)
+
+		if err != nil {
+			continue
+		}
+
+		if ns, ok := byInode[inode]; ok && ns.PID < int32(pid) {
+			continue
+		}
+
+		byInode[inode] = &Netns{
+			Inode:     inode,
+			PID:       int32(pid),
+			Process:   processName(procRoot, int32(pid)),
+			Container: ContainerOf(processCgroup(procRoot, int32(pid))),
+		}
+	}
+
+	named, err := os.ReadDir(runRoot)
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", runRoot, err)
+	}
+
+	for _, entry := range named {
+		info, err := os.Stat(filepath.Join(runRoot, entry.Name()))
+
+		if err != nil {
+			continue
+		}
+
+		st, ok := info.Sys().(*syscall.Stat_t)
+
+		if !ok {
+			continue
+		}
+
+		if ns, ok := byInode[st.Ino]; ok {
+			ns.Name = entry.Name()
+			continue
+		}
+
+		byInode[st.Ino] = &Netns{Inode: st.Ino, Name: entry.Name()}
+	}
+
+	namespaces := make([]Netns, 0, len(byInode))
+
+	for _, ns := range byInode {
+		namespaces = append(namespaces, *ns)
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Inode < namespaces[j].Inode })
+
+	return namespaces, nil
+}
+
+// The cgroup v2 path of a process, from /proc/<pid>/cgroup.
+func processCgroup(procRoot string, pid int32) string {
+	file, err := os.Open(filepath.Join(procRoot, strconv.Itoa(int(pid)), "cgroup"))
+
+	if err != nil {
+		return ""
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::")
+		}
+	}
+
+	return ""
+}
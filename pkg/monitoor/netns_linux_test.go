@@ -0,0 +1,141 @@
+//go:build linux
+
+package monitoor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: %d     739    0    0    0     0          0         0  %[1]d     739    0    0    0     0       0          0
+  eth0: %d     240    0    0    0     0          0         0    %d     384    0    0    0     0       0          0
+`
+
+type fakeProcess struct {
+	pid    int
+	comm   string
+	cgroup string
+	netns  uint64
+}
+
+func writeFakeProc(t *testing.T, root string, p fakeProcess, lo, recv, sent uint64) {
+	t.Helper()
+
+	dir := filepath.Join(root, strconv.Itoa(p.pid))
+
+	for _, sub := range []string{"ns", "net"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{
+		"comm":    p.comm + "\n",
+		"cgroup":  "0::" + p.cgroup + "\n",
+		"net/dev": fmt.Sprintf(netDev, lo, recv, sent),
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	link := filepath.Join(dir, "ns", "net")
+	os.Remove(link)
+
+	if err := os.Symlink("net:["+strconv.FormatUint(p.netns, 10)+"]", link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseNetDev(t *testing.T) {
+	stats, err := parseNetDev(strings.NewReader(fmt.Sprintf(netDev, 100, 3012088, 37552)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 || stats[1].Name != "eth0" || stats[1].BytesRecv != 3012088 || stats[1].BytesSent != 37552 {
+		t.Errorf("got %+v, expected lo and eth0 with 3012088 received and 37552 sent", stats)
+	}
+
+	ns := NetnsStat{Interfaces: stats}
+
+	if sum := ns.Sum(); sum.BytesTotal != 3012088+37552 {
+		t.Errorf("got %d, expected loopback to be left out of the sum", sum.BytesTotal)
+	}
+}
+
+func TestContainerOf(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"/init.scope", ""},
+		{"/system.slice/docker-4f1c2a9e0b7d5c3a.scope", "docker:4f1c2a9e0b7d"},
+		{"/machine.slice/libpod-9b8e7d6c5b4a3f2e.scope/container", "podman:9b8e7d6c5b4a"},
+		{"/machine.slice/libpod-conmon-9b8e7d6c5b4a3f2e.scope", ""},
+		{"/docker/0123456789abcdef", "docker:0123456789ab"},
+	}
+
+	for _, v := range table {
+		if out := ContainerOf(v.in); out != v.out {
+			t.Errorf("ContainerOf(%q) = %q, want %q", v.in, out, v.out)
+		}
+	}
+}
+
+func TestNetnsCollector(t *testing.T) {
+	procRoot, runRoot := t.TempDir(), t.TempDir()
+
+	host := fakeProcess{pid: 1, comm: "systemd", cgroup: "/init.scope", netns: 4026531833}
+	shell := fakeProcess{pid: 42, comm: "bash", cgroup: "/user.slice", netns: 4026531833}
+	nginx := fakeProcess{pid: 300, comm: "nginx", cgroup: "/system.slice/docker-4f1c2a9e0b7d5c3a.scope", netns: 4026532500}
+
+	writeFakeProc(t, procRoot, host, 10, 1000, 500)
+	writeFakeProc(t, procRoot, shell, 10, 1000, 500)
+	writeFakeProc(t, procRoot, nginx, 0, 2000, 100)
+
+	collector := &NetnsCollector{procRoot: procRoot, runRoot: runRoot, previous: map[uint64]map[string]NetStat{}}
+
+	baseline, err := collector.Collect()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(baseline) != 2 {
+		t.Fatalf("got %d namespaces, expected 2", len(baseline))
+	}
+
+	if baseline[0].PID != 1 || baseline[0].Label() != "systemd[1]" {
+		t.Errorf("got %+v, expected host namespace to be owned by pid 1", baseline[0].Netns)
+	}
+
+	if baseline[1].Label() != "docker:4f1c2a9e0b7d" {
+		t.Errorf("got label %q, expected docker:4f1c2a9e0b7d", baseline[1].Label())
+	}
+
+	if sum := baseline[1].Sum(); sum.BytesTotal != 0 {
+		t.Errorf("got %d, expected the first collection to be a baseline", sum.BytesTotal)
+	}
+
+	writeFakeProc(t, procRoot, nginx, 0, 2600, 150)
+
+	stats, err := collector.Collect()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum := stats[1].Sum(); sum.BytesRecv != 600 || sum.BytesSent != 50 {
+		t.Errorf("got %+v, expected 600 received and 50 sent", sum)
+	}
+}
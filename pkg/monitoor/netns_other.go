@@ -0,0 +1,14 @@
+//go:build !linux
+
+package monitoor
+
+// A NetnsCollector reads interface counters inside every network namespace.
+type NetnsCollector struct{}
+
+func NewNetnsCollector() *NetnsCollector {
+	return &NetnsCollector{}
+}
+
+func (c *NetnsCollector) Collect() ([]NetnsStat, error) {
+	return nil, ErrUnsupported
+}
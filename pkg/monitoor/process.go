@@ -0,0 +1,42 @@
+package monitoor
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrUnsupported = errors.New("collector is not supported on this platform")
+
+// A ProcessStat represents the network usage attributed to a single process.
+type ProcessStat struct {
+	PID     int32
+	Name    string
+	Sockets int
+	NetStat
+}
+
+// The n processes with the highest total usage, busiest first.
+// Processes without any traffic are left out.
+func TopTalkers(stats []ProcessStat, n int) []ProcessStat {
+	var top []ProcessStat
+
+	for _, stat := range stats {
+		if stat.BytesTotal > 0 {
+			top = append(top, stat)
+		}
+	}
+
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].BytesTotal == top[j].BytesTotal {
+			return top[i].PID < top[j].PID
+		}
+
+		return top[i].BytesTotal > top[j].BytesTotal
+	})
+
+	if n >= 0 && len(top) > n {
+		top = top[:n]
+	}
+
+	return top
+}
@@ -0,0 +1,225 @@
+//go:build linux
+
+package monitoor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var procNetFiles = []string{"tcp", "tcp6", "udp", "udp6"}
+
+// A ProcessCollector attributes network usage to processes.
+//
+// Sockets listed in /proc/net/{tcp,tcp6,udp,udp6} are mapped to their owning
+// processes through the inodes found in /proc/<pid>/fd. Byte counters are read
+// through sock_diag, which only exposes them for TCP sockets, so UDP sockets
+// are counted but carry no traffic.
+type ProcessCollector struct {
+	procRoot string
+
+	primed   bool
+	previous map[uint64]NetStat // socket inode -> cumulative counters
+}
+
+func NewProcessCollector() *ProcessCollector {
+	return &ProcessCollector{
+		procRoot: "/proc",
+		previous: map[uint64]NetStat{},
+	}
+}
+
+// The usage of every process that owns a socket since the previous call.
+// The first call only records a baseline and reports no traffic.
+func (c *ProcessCollector) Collect() ([]ProcessStat, error) {
+	sockets := map[uint64]string{}
+
+	for _, name := range procNetFiles {
+		found, err := readProcNet(filepath.Join(c.procRoot, "net", name))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, inode := range found {
+			sockets[inode] = name
+		}
+	}
+
+	owners, err := socketOwners(c.procRoot, sockets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dumped, err := dumpTCPSockets()
+
+	if err != nil {
+		return nil, err
+	}
+
+	byPID := map[int32]*ProcessStat{}
+
+	for inode := range sockets {
+		pid, ok := owners[inode]
+
+		if !ok {
+			continue
+		}
+
+		if _, ok = byPID[pid]; !ok {
+			byPID[pid] = &ProcessStat{PID: pid, Name: processName(c.procRoot, pid)}
+		}
+
+		byPID[pid].Sockets++
+	}
+
+	current := make(map[uint64]NetStat, len(dumped))
+
+	for _, s := range dumped {
+		counters := NetStat{BytesSent: s.Sent, BytesRecv: s.Recv, BytesTotal: s.Sent + s.Recv}
+		current[s.Inode] = counters
+
+		stat, ok := byPID[owners[s.Inode]]
+
+		if !ok || !c.primed {
+			continue
+		}
+
+		delta := socketDelta(counters, c.previous[s.Inode])
+
+		stat.BytesSent += delta.BytesSent
+		stat.BytesRecv += delta.BytesRecv
+		stat.BytesTotal += delta.BytesTotal
+	}
+
+	c.previous = current
+	c.primed = true
+
+	stats := make([]ProcessStat, 0, len(byPID))
+
+	for _, stat := range byPID {
+		stats = append(stats, *stat)
+	}
+
+	return stats, nil
+}
+
+// Counters of a socket never go backwards, unless its inode got reused.
+func socketDelta(current, previous NetStat) NetStat {
+	if current.BytesSent < previous.BytesSent || current.BytesRecv < previous.BytesRecv {
+		return current
+	}
+
+	return NetStat{
+		BytesSent:  current.BytesSent - previous.BytesSent,
+		BytesRecv:  current.BytesRecv - previous.BytesRecv,
+		BytesTotal: current.BytesTotal - previous.BytesTotal,
+	}
+}
+
+func readProcNet(path string) ([]uint64, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // e.g. ipv6 disabled
+		}
+
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	defer file.Close()
+
+	return parseProcNet(file)
+}
+
+// The socket inodes of a /proc/net/{tcp,udp}[6] table.
+func parseProcNet(r io.Reader) ([]uint64, error) {
+	var inodes []uint64
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 10 {
+			continue
+		}
+
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid inode %q: %w", fields[9], err)
+		}
+
+		if inode != 0 {
+			inodes = append(inodes, inode)
+		}
+	}
+
+	return inodes, scanner.Err()
+}
+
+// Map each of the given socket inodes to the pid holding a descriptor to it.
+// Processes that cannot be inspected are skipped.
+func socketOwners(procRoot string, sockets map[uint64]string) (map[uint64]int32, error) {
+	entries, err := os.ReadDir(procRoot)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", procRoot, err)
+	}
+
+	owners := map[uint64]int32{}
+
+	for _, entry := range entries {
+		pid, err := strconv.ParseInt(entry.Name(), 10, 32)
+
+		if err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join(procRoot, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+
+		if err != nil {
+			continue
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+
+			inode, err := strconv.ParseUint(strings.TrimSuffix(link[len("socket:["):], "]"), 10, 64)
+
+			if err != nil {
+				continue
+			}
+
+			if _, ok := sockets[inode]; ok {
+				owners[inode] = int32(pid)
+			}
+		}
+	}
+
+	return owners, nil
+}
+
+func processName(procRoot string, pid int32) string {
+	comm, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(int(pid)), "comm"))
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(comm))
+}
@@ -0,0 +1,99 @@
+//go:build linux
+
+package monitoor
+
+import (
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+const procNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:BC8F 00000000:0000 0A 00000000:00000000 00:00000000 00000000 65534        0 926 1 00000000b2495ab8 100 0 0 10 0
+   1: 0100007F:0050 0100007F:D2F0 01 00000000:00000000 00:00000000 00000000  1000        0 31337 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:D2F0 0100007F:0050 06 00000000:00000000 03:00000F2A 00000000     0        0 0 3 0000000000000000
+`
+
+func TestParseProcNet(t *testing.T) {
+	inodes, err := parseProcNet(strings.NewReader(procNetTCP))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inodes) != 2 || inodes[0] != 926 || inodes[1] != 31337 {
+		t.Errorf("got: %v. expected: [926 31337]", inodes)
+	}
+}
+
+func TestProcessCollectorLoopback(t *testing.T) {
+	const payload = 256 * 1024
+
+	collector := NewProcessCollector()
+
+	if _, err := collector.Collect(); err != nil {
+		t.Skipf("process collector unavailable: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	received := make(chan int64)
+
+	go func() {
+		conn, err := listener.Accept()
+
+		if err != nil {
+			received <- -1
+			return
+		}
+
+		n, _ := io.Copy(io.Discard, conn)
+		received <- n
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = conn.Write(make([]byte, payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.(*net.TCPConn).CloseWrite()
+
+	if n := <-received; n != payload {
+		t.Fatalf("server received %d bytes, expected %d", n, payload)
+	}
+
+	defer conn.Close()
+
+	stats, err := collector.Collect()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stat := range stats {
+		if stat.PID != int32(os.Getpid()) {
+			continue
+		}
+
+		if stat.BytesSent < payload || stat.BytesRecv < payload {
+			t.Errorf("got: sent %d received %d. expected at least %d each", stat.BytesSent, stat.BytesRecv, payload)
+		}
+
+		return
+	}
+
+	t.Errorf("test process %d not found among %d processes", os.Getpid(), len(stats))
+}
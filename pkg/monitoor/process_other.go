@@ -0,0 +1,14 @@
+//go:build !linux
+
+package monitoor
+
+// A ProcessCollector attributes network usage to processes.
+type ProcessCollector struct{}
+
+func NewProcessCollector() *ProcessCollector {
+	return &ProcessCollector{}
+}
+
+func (c *ProcessCollector) Collect() ([]ProcessStat, error) {
+	return nil, ErrUnsupported
+}
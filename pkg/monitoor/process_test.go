@@ -0,0 +1,26 @@
+package monitoor
+
+import "testing"
+
+func TestTopTalkers(t *testing.T) {
+	stats := []ProcessStat{
+		{PID: 1, Name: "idle", NetStat: NetStat{}},
+		{PID: 2, Name: "curl", NetStat: NetStat{BytesSent: 10, BytesRecv: 90, BytesTotal: 100}},
+		{PID: 3, Name: "sshd", NetStat: NetStat{BytesSent: 5, BytesRecv: 5, BytesTotal: 10}},
+		{PID: 4, Name: "firefox", NetStat: NetStat{BytesSent: 100, BytesRecv: 900, BytesTotal: 1000}},
+	}
+
+	top := TopTalkers(stats, 2)
+
+	if len(top) != 2 {
+		t.Fatalf("got %d processes, expected 2", len(top))
+	}
+
+	if top[0].PID != 4 || top[1].PID != 2 {
+		t.Errorf("got: %d %d. expected: 4 2", top[0].PID, top[1].PID)
+	}
+
+	if all := TopTalkers(stats, 10); len(all) != 3 {
+		t.Errorf("got %d processes, expected idle process to be left out", len(all))
+	}
+}
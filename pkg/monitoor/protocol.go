@@ -0,0 +1,157 @@
+package monitoor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// A TCPStat represents TCP segment and health counters, for IPv4 and IPv6.
+type TCPStat struct {
+	InSegs, OutSegs         uint64
+	RetransSegs, SynRetrans uint64
+	Timeouts                uint64
+	EstabResets, OutRsts    uint64
+	InErrs                  uint64
+}
+
+// A UDPStat represents UDP datagram and health counters, for IPv4 and IPv6.
+type UDPStat struct {
+	InDatagrams, OutDatagrams  uint64
+	NoPorts, InErrors          uint64
+	RcvbufErrors, SndbufErrors uint64
+}
+
+// An ICMPStat represents ICMP message counters, for IPv4 and IPv6.
+type ICMPStat struct {
+	InMsgs, OutMsgs, InErrors uint64
+}
+
+// A ProtoStat represents the per-protocol counters of the kernel MIBs.
+// The kernel counts packets per protocol, not bytes.
+type ProtoStat struct {
+	TCP  TCPStat
+	UDP  UDPStat
+	ICMP ICMPStat
+}
+
+// The protocol counters at the current time, from /proc/net/{snmp,snmp6,netstat}.
+func Protocols() (*ProtoStat, error) {
+	if runtime.GOOS != "linux" {
+		return nil, ErrUnsupported
+	}
+
+	return readProtocols("/proc")
+}
+
+func readProtocols(procRoot string) (*ProtoStat, error) {
+	counters := map[string]uint64{}
+
+	for _, name := range []string{"snmp", "netstat", "snmp6"} {
+		file, err := os.Open(filepath.Join(procRoot, "net", name))
+
+		if err != nil {
+			if name == "snmp6" && os.IsNotExist(err) {
+				continue // ipv6 disabled
+			}
+
+			return nil, fmt.Errorf("failed to capture protocol stat: %w", err)
+		}
+
+		if name == "snmp6" {
+			err = parseSnmp6(file, counters)
+		} else {
+			err = parseSnmp(file, counters)
+		}
+
+		file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+	}
+
+	return &ProtoStat{
+		TCP: TCPStat{
+			InSegs:      counters["Tcp.InSegs"],
+			OutSegs:     counters["Tcp.OutSegs"],
+			RetransSegs: counters["Tcp.RetransSegs"],
+			SynRetrans:  counters["TcpExt.TCPSynRetrans"],
+			Timeouts:    counters["TcpExt.TCPTimeouts"],
+			EstabResets: counters["Tcp.EstabResets"],
+			OutRsts:     counters["Tcp.OutRsts"],
+			InErrs:      counters["Tcp.InErrs"],
+		},
+		UDP: UDPStat{
+			InDatagrams:  counters["Udp.InDatagrams"] + counters["Udp6.InDatagrams"],
+			OutDatagrams: counters["Udp.OutDatagrams"] + counters["Udp6.OutDatagrams"],
+			NoPorts:      counters["Udp.NoPorts"] + counters["Udp6.NoPorts"],
+			InErrors:     counters["Udp.InErrors"] + counters["Udp6.InErrors"],
+			RcvbufErrors: counters["Udp.RcvbufErrors"] + counters["Udp6.RcvbufErrors"],
+			SndbufErrors: counters["Udp.SndbufErrors"] + counters["Udp6.SndbufErrors"],
+		},
+		ICMP: ICMPStat{
+			InMsgs:   counters["Icmp.InMsgs"] + counters["Icmp6.InMsgs"],
+			OutMsgs:  counters["Icmp.OutMsgs"] + counters["Icmp6.OutMsgs"],
+			InErrors: counters["Icmp.InErrors"] + counters["Icmp6.InErrors"],
+		},
+	}, nil
+}
+
+// Parse the header/value line pairs of /proc/net/snmp and /proc/net/netstat
+// into Section.Name keys. Negative values, such as Tcp.MaxConn, are skipped.
+func parseSnmp(r io.Reader, counters map[string]uint64) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		header := strings.Fields(scanner.Text())
+
+		if !scanner.Scan() {
+			break
+		}
+
+		values := strings.Fields(scanner.Text())
+
+		if len(header) != len(values) || len(header) == 0 || header[0] != values[0] {
+			return fmt.Errorf("mismatched header and values for %q", header)
+		}
+
+		section := strings.TrimSuffix(header[0], ":")
+
+		for i := 1; i < len(header); i++ {
+			if value, err := strconv.ParseUint(values[i], 10, 64); err == nil {
+				counters[section+"."+header[i]] = value
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Parse the name/value lines of /proc/net/snmp6, e.g. Udp6InDatagrams 0.
+func parseSnmp6(r io.Reader, counters map[string]uint64) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) != 2 {
+			continue
+		}
+
+		for _, section := range []string{"Icmp6", "Udp6", "Ip6"} {
+			if strings.HasPrefix(fields[0], section) {
+				if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+					counters[section+"."+strings.TrimPrefix(fields[0], section)] = value
+				}
+			}
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,37 @@
+package monitoor
+
+import "testing"
+
+func TestReadProtocols(t *testing.T) {
+	stat, err := readProtocols("testdata/proc")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ProtoStat{
+		TCP: TCPStat{
+			InSegs:      1492,
+			OutSegs:     1641,
+			RetransSegs: 7,
+			SynRetrans:  5,
+			Timeouts:    6,
+			EstabResets: 1,
+			OutRsts:     2,
+			InErrs:      3,
+		},
+		UDP: UDPStat{
+			InDatagrams:  100,
+			OutDatagrams: 61,
+			RcvbufErrors: 4,
+		},
+		ICMP: ICMPStat{
+			InMsgs:  9,
+			OutMsgs: 13,
+		},
+	}
+
+	if *stat != expected {
+		t.Errorf("got: %+v. expected: %+v", *stat, expected)
+	}
+}
@@ -0,0 +1,336 @@
+package monitoor
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	oidSysUpTime     = ".1.3.6.1.2.1.1.3.0"
+	oidIfName        = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHCInOctets  = ".1.3.6.1.2.1.31.1.1.1.6"
+	oidIfHCOutOctets = ".1.3.6.1.2.1.31.1.1.1.10"
+)
+
+// sysUpTime counts hundredths of a second in 32 bits, it wraps after ~497 days.
+const upTimeWrap = uint64(math.MaxUint32) + 1
+
+// SNMPCredentials are shared by the devices polled with them.
+type SNMPCredentials struct {
+	Version   string // "2c" or "3"
+	Community string
+
+	// SNMPv3 user based security, authentication and privacy are optional.
+	User           string
+	AuthProtocol   string // MD5, SHA, SHA224, SHA256, SHA384 or SHA512
+	AuthPassphrase string
+	PrivProtocol   string // DES, AES, AES192, AES256, AES192C or AES256C
+	PrivPassphrase string
+}
+
+// An SNMPDevice is a switch or router whose interfaces are polled.
+type SNMPDevice struct {
+	Name    string
+	Address string // host:port
+	SNMPCredentials
+}
+
+// Parse a device given as [name=]host[:port], named after its host by default.
+func ParseSNMPDevice(spec string, credentials SNMPCredentials) (SNMPDevice, error) {
+	name, address, ok := strings.Cut(spec, "=")
+
+	if !ok {
+		name, address = "", spec
+	}
+
+	host, port, err := net.SplitHostPort(address)
+
+	if err != nil {
+		host, port = strings.Trim(address, "[]"), "161"
+	}
+
+	if host == "" {
+		return SNMPDevice{}, fmt.Errorf("invalid snmp device %q", spec)
+	}
+
+	if _, err = strconv.ParseUint(port, 10, 16); err != nil {
+		return SNMPDevice{}, fmt.Errorf("invalid port of snmp device %q", spec)
+	}
+
+	if name == "" {
+		name = host
+	}
+
+	return SNMPDevice{Name: name, Address: net.JoinHostPort(host, port), SNMPCredentials: credentials}, nil
+}
+
+type ifCounters struct {
+	in, out uint64
+}
+
+// An SNMPPoller collects the usage of every interface of a device from the
+// 64-bit IF-MIB counters.
+type SNMPPoller struct {
+	device SNMPDevice
+	client *gosnmp.GoSNMP
+
+	mu       sync.Mutex
+	names    map[int]string
+	previous map[int]ifCounters
+	upTime   uint32
+	polledAt time.Time
+}
+
+func NewSNMPPoller(device SNMPDevice, timeout time.Duration) (*SNMPPoller, error) {
+	host, port, err := net.SplitHostPort(device.Address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+
+	if err != nil {
+		return nil, err
+	}
+
+	client := &gosnmp.GoSNMP{
+		Target:             host,
+		Port:               uint16(portNumber),
+		Transport:          "udp",
+		Timeout:            timeout,
+		Retries:            1,
+		MaxOids:            gosnmp.MaxOids,
+		MaxRepetitions:     50,
+		ExponentialTimeout: false,
+	}
+
+	switch device.Version {
+	case "", "2c":
+		client.Version = gosnmp.Version2c
+		client.Community = device.Community
+	case "3":
+		if err = configureUSM(client, device.SNMPCredentials); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported snmp version %q", device.Version)
+	}
+
+	return &SNMPPoller{device: device, client: client}, nil
+}
+
+func configureUSM(client *gosnmp.GoSNMP, c SNMPCredentials) error {
+	auth := map[string]gosnmp.SnmpV3AuthProtocol{
+		"": gosnmp.NoAuth, "MD5": gosnmp.MD5, "SHA": gosnmp.SHA, "SHA224": gosnmp.SHA224,
+		"SHA256": gosnmp.SHA256, "SHA384": gosnmp.SHA384, "SHA512": gosnmp.SHA512,
+	}
+
+	priv := map[string]gosnmp.SnmpV3PrivProtocol{
+		"": gosnmp.NoPriv, "DES": gosnmp.DES, "AES": gosnmp.AES, "AES192": gosnmp.AES192,
+		"AES256": gosnmp.AES256, "AES192C": gosnmp.AES192C, "AES256C": gosnmp.AES256C,
+	}
+
+	authProtocol, ok := auth[strings.ToUpper(c.AuthProtocol)]
+
+	if !ok {
+		return fmt.Errorf("unsupported snmp auth protocol %q", c.AuthProtocol)
+	}
+
+	privProtocol, ok := priv[strings.ToUpper(c.PrivProtocol)]
+
+	if !ok {
+		return fmt.Errorf("unsupported snmp privacy protocol %q", c.PrivProtocol)
+	}
+
+	flags := gosnmp.NoAuthNoPriv
+
+	switch {
+	case privProtocol != gosnmp.NoPriv && authProtocol == gosnmp.NoAuth:
+		return fmt.Errorf("snmp privacy requires an auth protocol")
+	case privProtocol != gosnmp.NoPriv:
+		flags = gosnmp.AuthPriv
+	case authProtocol != gosnmp.NoAuth:
+		flags = gosnmp.AuthNoPriv
+	}
+
+	client.Version = gosnmp.Version3
+	client.SecurityModel = gosnmp.UserSecurityModel
+	client.MsgFlags = flags
+	client.SecurityParameters = &gosnmp.UsmSecurityParameters{
+		UserName:                 c.User,
+		AuthenticationProtocol:   authProtocol,
+		AuthenticationPassphrase: c.AuthPassphrase,
+		PrivacyProtocol:          privProtocol,
+		PrivacyPassphrase:        c.PrivPassphrase,
+	}
+
+	return nil
+}
+
+func (p *SNMPPoller) Device() SNMPDevice {
+	return p.device
+}
+
+// Poll the device, returning the usage of each interface since the previous
+// poll. The first poll, and the first after a reboot, only sets the baseline.
+func (p *SNMPPoller) Poll() ([]InterfaceStat, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client.Conn == nil {
+		if err := p.client.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", p.device.Name, err)
+		}
+	}
+
+	now := time.Now()
+
+	result, err := p.client.Get([]string{oidSysUpTime})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sysUpTime of %s: %w", p.device.Name, err)
+	}
+
+	if len(result.Variables) != 1 || result.Variables[0].Type != gosnmp.TimeTicks {
+		return nil, fmt.Errorf("unexpected sysUpTime of %s", p.device.Name)
+	}
+
+	upTime := result.Variables[0].Value.(uint32)
+
+	counters := map[int]ifCounters{}
+
+	if err = p.walk(oidIfHCInOctets, func(index int, pdu gosnmp.SnmpPDU) {
+		c := counters[index]
+		c.in = gosnmp.ToBigInt(pdu.Value).Uint64()
+		counters[index] = c
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = p.walk(oidIfHCOutOctets, func(index int, pdu gosnmp.SnmpPDU) {
+		c := counters[index]
+		c.out = gosnmp.ToBigInt(pdu.Value).Uint64()
+		counters[index] = c
+	}); err != nil {
+		return nil, err
+	}
+
+	rebooted := p.polledAt.IsZero() || restarted(p.upTime, upTime, now.Sub(p.polledAt))
+
+	if rebooted || !p.named(counters) {
+		names := map[int]string{}
+
+		if err = p.walk(oidIfName, func(index int, pdu gosnmp.SnmpPDU) {
+			if b, ok := pdu.Value.([]byte); ok {
+				names[index] = string(b)
+			}
+		}); err != nil {
+			return nil, err
+		}
+
+		p.names = names
+	}
+
+	var stats []InterfaceStat
+
+	if !rebooted {
+		stats = p.deltas(counters)
+	}
+
+	p.previous, p.upTime, p.polledAt = counters, upTime, now
+
+	return stats, nil
+}
+
+// Walk a column of the interface table, handing each row to fn by ifIndex.
+func (p *SNMPPoller) walk(oid string, fn func(index int, pdu gosnmp.SnmpPDU)) error {
+	pdus, err := p.client.BulkWalkAll(oid)
+
+	if err != nil {
+		return fmt.Errorf("failed to walk %s of %s: %w", oid, p.device.Name, err)
+	}
+
+	for _, pdu := range pdus {
+		index, err := strconv.Atoi(strings.TrimPrefix(pdu.Name, oid+"."))
+
+		if err != nil {
+			continue
+		}
+
+		fn(index, pdu)
+	}
+
+	return nil
+}
+
+func (p *SNMPPoller) named(counters map[int]ifCounters) bool {
+	for index := range counters {
+		if _, ok := p.names[index]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *SNMPPoller) deltas(counters map[int]ifCounters) []InterfaceStat {
+	var stats []InterfaceStat
+
+	for index, current := range counters {
+		previous, ok := p.previous[index]
+
+		if !ok {
+			continue // a new interface, e.g. a module inserted
+		}
+
+		name, ok := p.names[index]
+
+		if !ok {
+			name = "if" + strconv.Itoa(index)
+		}
+
+		in, out := counterDelta(previous.in, current.in), counterDelta(previous.out, current.out)
+
+		stats = append(stats, InterfaceStat{
+			Name: name,
+			NetStat: NetStat{
+				BytesSent:  out,
+				BytesRecv:  in,
+				BytesTotal: in + out,
+			},
+		})
+	}
+
+	return stats
+}
+
+// The increase of a 64-bit counter. It takes centuries for one to wrap, so a
+// decrease is a reset, e.g. of the interface, or a discontinuity of the agent:
+// what was transferred is unknown, and current becomes the baseline.
+func counterDelta(previous, current uint64) uint64 {
+	if current >= previous {
+		return current - previous
+	}
+
+	return 0
+}
+
+// Whether an agent restarted between two polls, i.e. its sysUpTime went back
+// further than a wrap of the 32-bit counter explains.
+func restarted(previous, current uint32, elapsed time.Duration) bool {
+	if current >= previous {
+		return false
+	}
+
+	expected := uint64(previous) + uint64(elapsed/(10*time.Millisecond))
+
+	return expected < upTimeWrap
+}
@@ -0,0 +1,311 @@
+package monitoor
+
+import (
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// A fakeAgent is an SNMPv2c agent simulator serving a mutable MIB.
+type fakeAgent struct {
+	conn net.PacketConn
+
+	mu  sync.Mutex
+	mib map[string]gosnmp.SnmpPDU
+}
+
+func newFakeAgent(t *testing.T) *fakeAgent {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &fakeAgent{conn: conn, mib: map[string]gosnmp.SnmpPDU{}}
+
+	t.Cleanup(func() { conn.Close() })
+
+	go a.serve()
+
+	return a
+}
+
+func (a *fakeAgent) set(oid string, typ gosnmp.Asn1BER, value interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.mib[oid] = gosnmp.SnmpPDU{Name: oid, Type: typ, Value: value}
+}
+
+// Boot the agent with the given interfaces and counters.
+func (a *fakeAgent) boot(upTime uint32, names []string, in, out []uint64) {
+	a.set(oidSysUpTime, gosnmp.TimeTicks, upTime)
+
+	for i, name := range names {
+		index := "." + strconv.Itoa(i+1)
+
+		a.set(oidIfName+index, gosnmp.OctetString, []byte(name))
+		a.set(oidIfHCInOctets+index, gosnmp.Counter64, in[i])
+		a.set(oidIfHCOutOctets+index, gosnmp.Counter64, out[i])
+	}
+}
+
+func (a *fakeAgent) serve() {
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c}
+	buf := make([]byte, 65535)
+
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+
+		if err != nil {
+			return
+		}
+
+		request, err := decoder.SnmpDecodePacket(buf[:n])
+
+		if err != nil {
+			continue
+		}
+
+		response := &gosnmp.SnmpPacket{
+			Version:   gosnmp.Version2c,
+			Community: request.Community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: request.RequestID,
+			Variables: a.answer(request),
+		}
+
+		out, err := response.MarshalMsg()
+
+		if err != nil {
+			continue
+		}
+
+		a.conn.WriteTo(out, addr)
+	}
+}
+
+func (a *fakeAgent) answer(request *gosnmp.SnmpPacket) []gosnmp.SnmpPDU {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	oids := make([]string, 0, len(a.mib))
+
+	for oid := range a.mib {
+		oids = append(oids, oid)
+	}
+
+	sort.Slice(oids, func(i, j int) bool { return oidLess(oids[i], oids[j]) })
+
+	next := func(oid string) gosnmp.SnmpPDU {
+		i := sort.Search(len(oids), func(i int) bool { return oidLess(oid, oids[i]) })
+
+		if i == len(oids) {
+			return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView}
+		}
+
+		return a.mib[oids[i]]
+	}
+
+	var variables []gosnmp.SnmpPDU
+
+	for _, v := range request.Variables {
+		switch request.PDUType {
+		case gosnmp.GetRequest:
+			pdu, ok := a.mib[v.Name]
+
+			if !ok {
+				pdu = gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchObject}
+			}
+
+			variables = append(variables, pdu)
+		case gosnmp.GetNextRequest:
+			variables = append(variables, next(v.Name))
+		case gosnmp.GetBulkRequest:
+			oid := v.Name
+
+			// gosnmp decodes max-repetitions of requests as zero.
+			repetitions := int(request.MaxRepetitions)
+
+			if repetitions == 0 {
+				repetitions = 10
+			}
+
+			for i := 0; i < repetitions; i++ {
+				pdu := next(oid)
+				variables = append(variables, pdu)
+
+				if pdu.Type == gosnmp.EndOfMibView {
+					break
+				}
+
+				oid = pdu.Name
+			}
+		}
+	}
+
+	return variables
+}
+
+func oidLess(a, b string) bool {
+	as, bs := strings.Split(strings.Trim(a, "."), "."), strings.Split(strings.Trim(b, "."), ".")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+
+		if x != y {
+			return x < y
+		}
+	}
+
+	return len(as) < len(bs)
+}
+
+func newTestPoller(t *testing.T, a *fakeAgent) *SNMPPoller {
+	t.Helper()
+
+	device, err := ParseSNMPDevice("core="+a.conn.LocalAddr().String(), SNMPCredentials{Community: "public"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewSNMPPoller(device, time.Second)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func byName(stats []InterfaceStat) map[string]NetStat {
+	out := map[string]NetStat{}
+
+	for _, s := range stats {
+		out[s.Name] = s.NetStat
+	}
+
+	return out
+}
+
+func TestSNMPPoller(t *testing.T) {
+	agent := newFakeAgent(t)
+	agent.boot(1000, []string{"Gi0/1", "Gi0/2"}, []uint64{100, math.MaxUint64 - 9}, []uint64{1000, 0})
+
+	p := newTestPoller(t, agent)
+
+	stats, err := p.Poll()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 0 {
+		t.Errorf("got %+v, expected the first poll to set the baseline", stats)
+	}
+
+	// Gi0/2's in counter is reset without the device restarting.
+	agent.boot(1100, []string{"Gi0/1", "Gi0/2"}, []uint64{150, 20}, []uint64{1300, 5})
+
+	stats, err = p.Poll()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := byName(stats)
+
+	if s := got["Gi0/1"]; s.BytesRecv != 50 || s.BytesSent != 300 || s.BytesTotal != 350 {
+		t.Errorf("got %+v for Gi0/1, expected 50 received and 300 sent", s)
+	}
+
+	if s := got["Gi0/2"]; s.BytesRecv != 0 || s.BytesSent != 5 || s.BytesTotal != 5 {
+		t.Errorf("got %+v for Gi0/2, expected nothing received across the reset", s)
+	}
+
+	agent.boot(1200, []string{"Gi0/1", "Gi0/2"}, []uint64{150, 50}, []uint64{1300, 5})
+
+	if stats, err = p.Poll(); err != nil || byName(stats)["Gi0/2"].BytesRecv != 30 {
+		t.Errorf("got %+v, %v, expected 30 received on Gi0/2 from the reset counter on", stats, err)
+	}
+
+	// The device reboots, its counters start over.
+	agent.boot(50, []string{"Gi0/1", "Gi0/2"}, []uint64{10, 10}, []uint64{10, 10})
+
+	if stats, err = p.Poll(); err != nil || len(stats) != 0 {
+		t.Errorf("got %+v, %v, expected a reboot to reset the baseline", stats, err)
+	}
+
+	agent.boot(150, []string{"Gi0/1", "Gi0/2"}, []uint64{20, 10}, []uint64{10, 10})
+
+	if stats, err = p.Poll(); err != nil || byName(stats)["Gi0/1"].BytesRecv != 10 {
+		t.Errorf("got %+v, %v, expected 10 received on Gi0/1 after the reboot", stats, err)
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	for _, test := range []struct {
+		previous, current, expected uint64
+	}{
+		{100, 150, 50},
+		{100, 100, 0},
+		{5000, 100, 0},              // reset
+		{math.MaxUint64 - 9, 20, 0}, // seemingly a wrap, which takes centuries
+	} {
+		if got := counterDelta(test.previous, test.current); got != test.expected {
+			t.Errorf("counterDelta(%d, %d) = %d, expected %d", test.previous, test.current, got, test.expected)
+		}
+	}
+}
+
+func TestSNMPRestarted(t *testing.T) {
+	for _, test := range []struct {
+		previous, current uint32
+		elapsed           time.Duration
+		expected          bool
+	}{
+		{1000, 1100, time.Second, false},
+		{1000, 10, time.Second, true},
+		{math.MaxUint32 - 50, 50, time.Second, false}, // sysUpTime wrapped after ~497 days
+		{math.MaxUint32 - 500, 50, time.Second, true},
+	} {
+		if got := restarted(test.previous, test.current, test.elapsed); got != test.expected {
+			t.Errorf("restarted(%d, %d, %v) = %v, expected %v", test.previous, test.current, test.elapsed, got, test.expected)
+		}
+	}
+}
+
+func TestParseSNMPDevice(t *testing.T) {
+	for spec, expected := range map[string]SNMPDevice{
+		"10.0.0.1":               {Name: "10.0.0.1", Address: "10.0.0.1:161"},
+		"core=10.0.0.1:1161":     {Name: "core", Address: "10.0.0.1:1161"},
+		"edge=[2001:db8::1]:161": {Name: "edge", Address: "[2001:db8::1]:161"},
+		"2001:db8::1":            {Name: "2001:db8::1", Address: "[2001:db8::1]:161"},
+	} {
+		got, err := ParseSNMPDevice(spec, SNMPCredentials{})
+
+		if err != nil {
+			t.Errorf("%s: %v", spec, err)
+			continue
+		}
+
+		if got != expected {
+			t.Errorf("%s: got %+v, expected %+v", spec, got, expected)
+		}
+	}
+
+	if _, err := ParseSNMPDevice("core=", SNMPCredentials{}); err == nil {
+		t.Error("expected an error for a device without host")
+	}
+}
@@ -0,0 +1,181 @@
+//go:build linux
+
+package monitoor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sockDiagByFamily = 20 // SOCK_DIAG_BY_FAMILY
+	inetDiagInfo     = 2  // INET_DIAG_INFO
+
+	inetDiagReqLen = 56
+	inetDiagMsgLen = 72
+
+	// Offsets of bytes_acked and bytes_received inside struct tcp_info.
+	tcpInfoBytesAcked    = 120
+	tcpInfoBytesReceived = 128
+)
+
+// A diagSocket is a socket as reported by the kernel's socket diagnostics.
+type diagSocket struct {
+	Inode      uint64
+	Local      net.IP
+	LocalPort  uint16
+	Remote     net.IP
+	RemotePort uint16
+
+	// Cumulative counters of the socket since it was created.
+	Sent, Recv uint64
+}
+
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+
+	return binary.BigEndian
+}()
+
+// Dump every TCP socket of both address families with its byte counters.
+func dumpTCPSockets() ([]diagSocket, error) {
+	var sockets []diagSocket
+
+	for _, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		found, err := sockDiagDump(family, syscall.IPPROTO_TCP)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sockets = append(sockets, found...)
+	}
+
+	return sockets, nil
+}
+
+func sockDiagDump(family, protocol uint8) ([]diagSocket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_INET_DIAG)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sock_diag socket: %w", err)
+	}
+
+	defer syscall.Close(fd)
+
+	req := make([]byte, syscall.NLMSG_HDRLEN+inetDiagReqLen)
+
+	nativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	nativeEndian.PutUint16(req[4:6], sockDiagByFamily)
+	nativeEndian.PutUint16(req[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	nativeEndian.PutUint32(req[8:12], 1)
+
+	body := req[syscall.NLMSG_HDRLEN:]
+	body[0] = family
+	body[1] = protocol
+	body[2] = 1 << (inetDiagInfo - 1)
+	nativeEndian.PutUint32(body[4:8], 0xffffffff) // every TCP state
+
+	if err = syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to send sock_diag request: %w", err)
+	}
+
+	var sockets []diagSocket
+
+	buf := make([]byte, os.Getpagesize()*8)
+
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive sock_diag response: %w", err)
+		}
+
+		messages, err := syscall.ParseNetlinkMessage(buf[:n])
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sock_diag response: %w", err)
+		}
+
+		for _, msg := range messages {
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return sockets, nil
+			case syscall.NLMSG_ERROR:
+				if len(msg.Data) >= 4 {
+					if errno := int32(nativeEndian.Uint32(msg.Data[0:4])); errno != 0 {
+						return nil, fmt.Errorf("sock_diag request failed: %w", syscall.Errno(-errno))
+					}
+				}
+
+				return sockets, nil
+			}
+
+			if s, ok := parseInetDiagMsg(msg.Data); ok {
+				sockets = append(sockets, s)
+			}
+		}
+	}
+}
+
+func parseInetDiagMsg(data []byte) (diagSocket, bool) {
+	var s diagSocket
+
+	if len(data) < inetDiagMsgLen {
+		return s, false
+	}
+
+	family := data[0]
+	id := data[4:52]
+
+	s.LocalPort = binary.BigEndian.Uint16(id[0:2])
+	s.RemotePort = binary.BigEndian.Uint16(id[2:4])
+
+	if family == syscall.AF_INET {
+		s.Local = net.IP(append([]byte(nil), id[4:8]...))
+		s.Remote = net.IP(append([]byte(nil), id[20:24]...))
+	} else {
+		s.Local = net.IP(append([]byte(nil), id[4:20]...))
+		s.Remote = net.IP(append([]byte(nil), id[20:36]...))
+	}
+
+	s.Inode = uint64(nativeEndian.Uint32(data[68:72]))
+
+	attrs := data[inetDiagMsgLen:]
+
+	for len(attrs) >= syscall.SizeofRtAttr {
+		length := int(nativeEndian.Uint16(attrs[0:2]))
+		kind := nativeEndian.Uint16(attrs[2:4])
+
+		if length < syscall.SizeofRtAttr || length > len(attrs) {
+			break
+		}
+
+		if kind == inetDiagInfo {
+			info := attrs[syscall.SizeofRtAttr:length]
+
+			if len(info) >= tcpInfoBytesReceived+8 {
+				s.Sent = nativeEndian.Uint64(info[tcpInfoBytesAcked:])
+				s.Recv = nativeEndian.Uint64(info[tcpInfoBytesReceived:])
+			}
+		}
+
+		aligned := (length + syscall.RTA_ALIGNTO - 1) & ^(syscall.RTA_ALIGNTO - 1)
+
+		if aligned > len(attrs) {
+			break
+		}
+
+		attrs = attrs[aligned:]
+	}
+
+	return s, true
+}
@@ -0,0 +1,380 @@
+// Package netflow decodes NetFlow v5, NetFlow v9 and IPFIX export packets.
+package netflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Information elements shared by NetFlow v9 and IPFIX.
+const (
+	fieldBytes          = 1
+	fieldPackets        = 2
+	fieldProtocol       = 4
+	fieldSrcPort        = 7
+	fieldSrcIPv4        = 8
+	fieldDstPort        = 11
+	fieldDstIPv4        = 12
+	fieldLastSwitched   = 21
+	fieldSrcIPv6        = 27
+	fieldDstIPv6        = 28
+	fieldTotalBytes     = 85
+	fieldEndSeconds     = 151
+	fieldEndMillis      = 153
+	variableLength      = 65535
+	v5HeaderLength      = 24
+	v5RecordLength      = 48
+	v9HeaderLength      = 20
+	ipfixHeaderLength   = 16
+	v9TemplateSet       = 0
+	v9OptionsSet        = 1
+	ipfixTemplateSet    = 2
+	ipfixOptionsSet     = 3
+	firstDataSetID      = 256
+	enterpriseBit       = 0x8000
+	samplingIntervalBit = 0x3fff
+)
+
+var ErrUnknownVersion = errors.New("unknown flow export version")
+
+// A Flow is a unidirectional flow record, normalized across export versions.
+type Flow struct {
+	Exporter         string
+	Time             time.Time // when the flow was last seen
+	Src, Dst         net.IP
+	Protocol         uint8
+	SrcPort, DstPort uint16
+	Bytes, Packets   uint64
+}
+
+type field struct {
+	id, length uint16
+}
+
+type template struct {
+	fields  []field
+	options bool
+}
+
+type templateKey struct {
+	exporter string
+	domain   uint32
+	id       uint16
+}
+
+// A Decoder decodes export packets, remembering the templates announced by
+// every exporter. It is safe for concurrent use.
+type Decoder struct {
+	mu        sync.Mutex
+	templates map[templateKey]template
+
+	// Data sets dropped because their template was not announced yet.
+	MissingTemplates uint64
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{templates: map[templateKey]template{}}
+}
+
+// Decode the flows of a packet sent by exporter.
+func (d *Decoder) Decode(exporter string, packet []byte) ([]Flow, error) {
+	if len(packet) < 2 {
+		return nil, fmt.Errorf("packet of %d bytes is too short", len(packet))
+	}
+
+	switch version := binary.BigEndian.Uint16(packet[0:2]); version {
+	case 5:
+		return decodeV5(exporter, packet)
+	case 9:
+		return d.decodeV9(exporter, packet)
+	case 10:
+		return d.decodeIPFIX(exporter, packet)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+}
+
+func decodeV5(exporter string, packet []byte) ([]Flow, error) {
+	if len(packet) < v5HeaderLength {
+		return nil, fmt.Errorf("truncated netflow v5 header")
+	}
+
+	count := int(binary.BigEndian.Uint16(packet[2:4]))
+
+	if len(packet) < v5HeaderLength+count*v5RecordLength {
+		return nil, fmt.Errorf("netflow v5 packet announces %d records but holds %d bytes", count, len(packet))
+	}
+
+	uptime := binary.BigEndian.Uint32(packet[4:8])
+	exported := time.Unix(int64(binary.BigEndian.Uint32(packet[8:12])), int64(binary.BigEndian.Uint32(packet[12:16])))
+
+	sampling := uint64(binary.BigEndian.Uint16(packet[22:24]) & samplingIntervalBit)
+
+	if sampling == 0 {
+		sampling = 1
+	}
+
+	flows := make([]Flow, 0, count)
+
+	for i := 0; i < count; i++ {
+		r := packet[v5HeaderLength+i*v5RecordLength:]
+		last := binary.BigEndian.Uint32(r[28:32])
+
+		flows = append(flows, Flow{
+			Exporter: exporter,
+			Time:     exported.Add(-time.Duration(uptime-last) * time.Millisecond),
+			Src:      net.IP(append([]byte(nil), r[0:4]...)),
+			Dst:      net.IP(append([]byte(nil), r[4:8]...)),
+			Protocol: r[38],
+			SrcPort:  binary.BigEndian.Uint16(r[32:34]),
+			DstPort:  binary.BigEndian.Uint16(r[34:36]),
+			Bytes:    uint64(binary.BigEndian.Uint32(r[20:24])) * sampling,
+			Packets:  uint64(binary.BigEndian.Uint32(r[16:20])) * sampling,
+		})
+	}
+
+	return flows, nil
+}
+
+func (d *Decoder) decodeV9(exporter string, packet []byte) ([]Flow, error) {
+	if len(packet) < v9HeaderLength {
+		return nil, fmt.Errorf("truncated netflow v9 header")
+	}
+
+	uptime := binary.BigEndian.Uint32(packet[4:8])
+	exported := time.Unix(int64(binary.BigEndian.Uint32(packet[8:12])), 0)
+	domain := binary.BigEndian.Uint32(packet[16:20])
+
+	timeOf := func(values map[uint16][]byte) time.Time {
+		if last, ok := values[fieldLastSwitched]; ok {
+			return exported.Add(-time.Duration(uptime-uint32(uintOf(last))) * time.Millisecond)
+		}
+
+		return exported
+	}
+
+	return d.decodeSets(exporter, domain, packet[v9HeaderLength:], v9TemplateSet, v9OptionsSet, timeOf)
+}
+
+func (d *Decoder) decodeIPFIX(exporter string, packet []byte) ([]Flow, error) {
+	if len(packet) < ipfixHeaderLength {
+		return nil, fmt.Errorf("truncated ipfix header")
+	}
+
+	length := int(binary.BigEndian.Uint16(packet[2:4]))
+
+	if length < ipfixHeaderLength || length > len(packet) {
+		return nil, fmt.Errorf("ipfix message announces %d bytes but holds %d", length, len(packet))
+	}
+
+	exported := time.Unix(int64(binary.BigEndian.Uint32(packet[4:8])), 0)
+	domain := binary.BigEndian.Uint32(packet[12:16])
+
+	timeOf := func(values map[uint16][]byte) time.Time {
+		if end, ok := values[fieldEndMillis]; ok {
+			return time.UnixMilli(int64(uintOf(end)))
+		}
+
+		if end, ok := values[fieldEndSeconds]; ok {
+			return time.Unix(int64(uintOf(end)), 0)
+		}
+
+		return exported
+	}
+
+	return d.decodeSets(exporter, domain, packet[ipfixHeaderLength:length], ipfixTemplateSet, ipfixOptionsSet, timeOf)
+}
+
+// Walk the flow sets of a v9 or IPFIX packet, which only differ in the ids of
+// their template sets and in IPFIX enterprise fields.
+func (d *Decoder) decodeSets(exporter string, domain uint32, sets []byte, templateSet, optionsSet uint16, timeOf func(map[uint16][]byte) time.Time) ([]Flow, error) {
+	var flows []Flow
+
+	ipfix := templateSet == ipfixTemplateSet
+
+	for len(sets) >= 4 {
+		id := binary.BigEndian.Uint16(sets[0:2])
+		length := int(binary.BigEndian.Uint16(sets[2:4]))
+
+		if length < 4 || length > len(sets) {
+			return flows, fmt.Errorf("set %d announces %d bytes but holds %d", id, length, len(sets))
+		}
+
+		body := sets[4:length]
+		sets = sets[length:]
+
+		switch {
+		case id == templateSet || id == optionsSet:
+			if err := d.readTemplates(exporter, domain, body, id == optionsSet, ipfix); err != nil {
+				return flows, err
+			}
+		case id >= firstDataSetID:
+			d.mu.Lock()
+			t, ok := d.templates[templateKey{exporter, domain, id}]
+
+			if !ok {
+				d.MissingTemplates++
+			}
+			d.mu.Unlock()
+
+			if !ok || t.options {
+				continue
+			}
+
+			flows = append(flows, readRecords(exporter, t, body, timeOf)...)
+		}
+	}
+
+	return flows, nil
+}
+
+func (d *Decoder) readTemplates(exporter string, domain uint32, body []byte, options, ipfix bool) error {
+	for len(body) >= 4 {
+		id := binary.BigEndian.Uint16(body[0:2])
+		count := int(binary.BigEndian.Uint16(body[2:4]))
+		body = body[4:]
+
+		if id < firstDataSetID {
+			return nil // padding
+		}
+
+		if options && !ipfix {
+			// NetFlow v9 announces the byte lengths of scope and option fields.
+			if len(body) < 2 {
+				return fmt.Errorf("truncated options template %d", id)
+			}
+
+			count = (count + int(binary.BigEndian.Uint16(body[0:2]))) / 4
+			body = body[2:]
+		} else if options {
+			if len(body) < 2 {
+				return fmt.Errorf("truncated options template %d", id)
+			}
+
+			body = body[2:] // scope field count
+		}
+
+		t := template{options: options}
+
+		for i := 0; i < count; i++ {
+			if len(body) < 4 {
+				return fmt.Errorf("truncated template %d", id)
+			}
+
+			f := field{id: binary.BigEndian.Uint16(body[0:2]), length: binary.BigEndian.Uint16(body[2:4])}
+			body = body[4:]
+
+			if ipfix && f.id&enterpriseBit != 0 {
+				if len(body) < 4 {
+					return fmt.Errorf("truncated template %d", id)
+				}
+
+				body = body[4:]
+				f.id = 0 // not an element we understand
+			}
+
+			t.fields = append(t.fields, f)
+		}
+
+		d.mu.Lock()
+		d.templates[templateKey{exporter, domain, id}] = t
+		d.mu.Unlock()
+	}
+
+	return nil
+}
+
+func readRecords(exporter string, t template, body []byte, timeOf func(map[uint16][]byte) time.Time) []Flow {
+	var flows []Flow
+
+	for len(body) > 0 {
+		values := map[uint16][]byte{}
+		rest := body
+
+		for _, f := range t.fields {
+			length := int(f.length)
+
+			if f.length == variableLength {
+				if len(rest) < 1 {
+					return flows
+				}
+
+				length, rest = int(rest[0]), rest[1:]
+
+				if length == 255 {
+					if len(rest) < 2 {
+						return flows
+					}
+
+					length, rest = int(binary.BigEndian.Uint16(rest[0:2])), rest[2:]
+				}
+			}
+
+			if length > len(rest) {
+				return flows // padding
+			}
+
+			if f.id != 0 {
+				values[f.id] = rest[:length]
+			}
+
+			rest = rest[length:]
+		}
+
+		if len(rest) == len(body) {
+			return flows // a template without fields
+		}
+
+		body = rest
+
+		flow := Flow{
+			Exporter: exporter,
+			Time:     timeOf(values),
+			Protocol: uint8(uintOf(values[fieldProtocol])),
+			SrcPort:  uint16(uintOf(values[fieldSrcPort])),
+			DstPort:  uint16(uintOf(values[fieldDstPort])),
+			Bytes:    uintOf(values[fieldBytes]),
+			Packets:  uintOf(values[fieldPackets]),
+		}
+
+		if _, ok := values[fieldBytes]; !ok {
+			flow.Bytes = uintOf(values[fieldTotalBytes])
+		}
+
+		flow.Src, flow.Dst = addressOf(values[fieldSrcIPv4], values[fieldSrcIPv6]), addressOf(values[fieldDstIPv4], values[fieldDstIPv6])
+
+		if flow.Src == nil || flow.Dst == nil {
+			continue
+		}
+
+		flows = append(flows, flow)
+	}
+
+	return flows
+}
+
+// A big-endian unsigned integer of up to 8 bytes.
+func uintOf(b []byte) uint64 {
+	var v uint64
+
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
+
+func addressOf(v4, v6 []byte) net.IP {
+	switch {
+	case len(v4) == net.IPv4len:
+		return net.IP(append([]byte(nil), v4...))
+	case len(v6) == net.IPv6len:
+		return net.IP(append([]byte(nil), v6...))
+	default:
+		return nil
+	}
+}
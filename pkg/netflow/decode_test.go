@@ -0,0 +1,231 @@
+package netflow
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+var exportTime = time.Unix(1665000000, 0)
+
+func be16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+
+	return b
+}
+
+func be32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+
+	return b
+}
+
+func be64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+
+	return b
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+
+	return out
+}
+
+func set(id uint16, body []byte) []byte {
+	return concat(be16(id), be16(uint16(4+len(body))), body)
+}
+
+func v5Packet() []byte {
+	record := func(src, dst string, sport, dport uint16, proto uint8, packets, bytes, last uint32) []byte {
+		return concat(
+			net.ParseIP(src).To4(), net.ParseIP(dst).To4(), make([]byte, 4), // next hop
+			be16(1), be16(2), be32(packets), be32(bytes), be32(last-1000), be32(last),
+			be16(sport), be16(dport), []byte{0, 0x18, proto, 0}, be16(0), be16(0), []byte{24, 24}, be16(0),
+		)
+	}
+
+	header := concat(be16(5), be16(2), be32(60000), be32(uint32(exportTime.Unix())), be32(0), be32(1), []byte{0, 0}, be16(0))
+
+	return concat(header,
+		record("192.168.1.10", "93.184.216.34", 51000, 443, 6, 10, 1500, 59000),
+		record("1.1.1.1", "192.168.1.10", 53, 53000, 17, 1, 120, 60000),
+	)
+}
+
+func v9Packet(withTemplate bool) []byte {
+	template := set(0, concat(be16(256), be16(7),
+		be16(fieldSrcIPv4), be16(4), be16(fieldDstIPv4), be16(4),
+		be16(fieldSrcPort), be16(2), be16(fieldDstPort), be16(2),
+		be16(fieldProtocol), be16(1), be16(fieldBytes), be16(8), be16(fieldLastSwitched), be16(4),
+	))
+
+	data := set(256, concat(
+		net.ParseIP("10.0.0.5").To4(), net.ParseIP("8.8.8.8").To4(), be16(40000), be16(443), []byte{6},
+		be64(5000000000), be32(59500),
+		[]byte{0, 0, 0}, // padding
+	))
+
+	header := concat(be16(9), be16(2), be32(60000), be32(uint32(exportTime.Unix())), be32(1), be32(7))
+
+	if !withTemplate {
+		return concat(header, data)
+	}
+
+	return concat(header, template, data)
+}
+
+func ipfixPacket() []byte {
+	template := set(2, concat(be16(300), be16(6),
+		be16(fieldSrcIPv6), be16(16), be16(fieldDstIPv6), be16(16),
+		be16(fieldProtocol), be16(1), be16(fieldTotalBytes), be16(4),
+		be16(enterpriseBit|1), be16(2), be32(29305), // enterprise specific
+		be16(96), be16(variableLength), // applicationName
+	))
+
+	data := set(300, concat(
+		net.ParseIP("fd00::10"), net.ParseIP("2001:db8::1"), []byte{17}, be32(900),
+		be16(0xbeef), []byte{4}, []byte("quic"),
+	))
+
+	body := concat(template, data)
+
+	return concat(be16(10), be16(uint16(ipfixHeaderLength+len(body))), be32(uint32(exportTime.Unix())), be32(1), be32(0), body)
+}
+
+func TestDecodeV5(t *testing.T) {
+	flows, err := NewDecoder().Decode("192.0.2.1", v5Packet())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flows) != 2 {
+		t.Fatalf("got %d flows, expected 2", len(flows))
+	}
+
+	f := flows[0]
+
+	if f.Src.String() != "192.168.1.10" || f.Dst.String() != "93.184.216.34" || f.SrcPort != 51000 || f.DstPort != 443 || f.Protocol != 6 || f.Bytes != 1500 || f.Packets != 10 {
+		t.Errorf("got %+v, expected 192.168.1.10:51000 > 93.184.216.34:443 with 1500 bytes", f)
+	}
+
+	if !f.Time.Equal(exportTime.Add(-time.Second)) {
+		t.Errorf("got %v, expected the flow to end a second before export", f.Time)
+	}
+}
+
+func TestDecodeV9(t *testing.T) {
+	decoder := NewDecoder()
+
+	flows, err := decoder.Decode("192.0.2.1", v9Packet(false))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flows) != 0 || decoder.MissingTemplates != 1 {
+		t.Errorf("got %d flows and %d missing templates, expected data without template to be dropped", len(flows), decoder.MissingTemplates)
+	}
+
+	flows, err = decoder.Decode("192.0.2.1", v9Packet(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flows) != 1 {
+		t.Fatalf("got %d flows, expected 1", len(flows))
+	}
+
+	if f := flows[0]; f.Src.String() != "10.0.0.5" || f.Dst.String() != "8.8.8.8" || f.Bytes != 5000000000 || f.DstPort != 443 {
+		t.Errorf("got %+v, expected 10.0.0.5 > 8.8.8.8:443 with 5000000000 bytes", f)
+	}
+
+	// Templates are scoped to their exporter.
+	if flows, _ = decoder.Decode("192.0.2.2", v9Packet(false)); len(flows) != 0 {
+		t.Errorf("got %d flows, expected templates not to leak across exporters", len(flows))
+	}
+}
+
+func TestDecodeIPFIX(t *testing.T) {
+	flows, err := NewDecoder().Decode("192.0.2.1", ipfixPacket())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flows) != 1 {
+		t.Fatalf("got %d flows, expected 1", len(flows))
+	}
+
+	if f := flows[0]; f.Src.String() != "fd00::10" || f.Dst.String() != "2001:db8::1" || f.Protocol != 17 || f.Bytes != 900 || !f.Time.Equal(exportTime) {
+		t.Errorf("got %+v, expected fd00::10 > 2001:db8::1 over udp with 900 bytes", f)
+	}
+}
+
+func TestServe(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan []Flow, 4)
+	done := make(chan error)
+
+	go func() {
+		done <- Serve(ctx, conn, NewDecoder(), func(flows []Flow) { received <- flows }, nil)
+	}()
+
+	exporter, err := net.Dial("udp", conn.LocalAddr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer exporter.Close()
+
+	for _, packet := range [][]byte{v5Packet(), v9Packet(true), ipfixPacket()} {
+		if _, err = exporter.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var total uint64
+
+	for i := 0; i < 3; i++ {
+		select {
+		case flows := <-received:
+			for _, f := range flows {
+				if f.Exporter != "127.0.0.1" {
+					t.Errorf("got exporter %q, expected 127.0.0.1", f.Exporter)
+				}
+
+				total += f.Bytes
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for flows")
+		}
+	}
+
+	if total != 1500+120+5000000000+900 {
+		t.Errorf("got %d bytes, expected every flow to be received", total)
+	}
+
+	cancel()
+
+	if err = <-done; err != nil {
+		t.Errorf("got %v, expected Serve to stop cleanly", err)
+	}
+}
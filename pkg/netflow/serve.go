@@ -0,0 +1,49 @@
+package netflow
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+const maxPacketSize = 65535
+
+// Decode every packet received on conn until ctx is done, handing the flows
+// of each packet to handle. Packets that fail to decode are passed to
+// onError and otherwise ignored.
+func Serve(ctx context.Context, conn net.PacketConn, decoder *Decoder, handle func([]Flow), onError func(error)) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return err
+		}
+
+		exporter := addr.String()
+
+		if host, _, err := net.SplitHostPort(exporter); err == nil {
+			exporter = host
+		}
+
+		flows, err := decoder.Decode(exporter, buf[:n])
+
+		if err != nil && onError != nil {
+			onError(err)
+		}
+
+		if len(flows) > 0 {
+			handle(flows)
+		}
+	}
+}
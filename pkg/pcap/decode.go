@@ -0,0 +1,175 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+const (
+	etherTypeIPv4  = 0x0800
+	etherTypeIPv6  = 0x86dd
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88a8
+	protocolTCP    = 6
+	protocolUDP    = 17
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
+
+// A Flow is the network and transport summary of a packet.
+type Flow struct {
+	Src, Dst         net.IP
+	Protocol         uint8 // IANA protocol number
+	SrcPort, DstPort uint16
+}
+
+// Decode the IP and TCP/UDP headers of a packet. Packets that do not carry IP
+// are reported as not ok.
+func Decode(p Packet) (Flow, bool) {
+	data := p.Data
+
+	switch p.LinkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return Flow{}, false
+		}
+
+		etherType := binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+
+		for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return Flow{}, false
+		}
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return Flow{}, false
+		}
+
+		data = data[16:]
+	case LinkTypeSLL2:
+		if len(data) < 20 {
+			return Flow{}, false
+		}
+
+		data = data[20:]
+	case LinkTypeNull:
+		if len(data) < 4 {
+			return Flow{}, false
+		}
+
+		data = data[4:]
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+	default:
+		return Flow{}, false
+	}
+
+	return decodeIP(data)
+}
+
+func decodeIP(data []byte) (Flow, bool) {
+	var flow Flow
+
+	if len(data) < 1 {
+		return flow, false
+	}
+
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return flow, false
+		}
+
+		headerLength := int(data[0]&0x0f) * 4
+
+		if headerLength < 20 || len(data) < headerLength {
+			return flow, false
+		}
+
+		flow.Protocol = data[9]
+		flow.Src = net.IP(append([]byte(nil), data[12:16]...))
+		flow.Dst = net.IP(append([]byte(nil), data[16:20]...))
+
+		// Only the first fragment carries the transport header.
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff != 0 {
+			return flow, true
+		}
+
+		data = data[headerLength:]
+	case 6:
+		if len(data) < 40 {
+			return flow, false
+		}
+
+		next := data[6]
+		flow.Src = net.IP(append([]byte(nil), data[8:24]...))
+		flow.Dst = net.IP(append([]byte(nil), data[24:40]...))
+		data = data[40:]
+
+	extensions:
+		for {
+			switch next {
+			case 0, 43, 60: // hop-by-hop, routing, destination options
+				if len(data) < 8 {
+					return flow, true
+				}
+
+				next, data = data[0], data[min(len(data), (int(data[1])+1)*8):]
+			case 44: // fragment
+				if len(data) < 8 {
+					return flow, true
+				}
+
+				offset := binary.BigEndian.Uint16(data[2:4]) >> 3
+				next, data = data[0], data[8:]
+
+				if offset != 0 {
+					flow.Protocol = next
+					return flow, true
+				}
+			default:
+				break extensions
+			}
+		}
+
+		flow.Protocol = next
+	default:
+		return flow, false
+	}
+
+	if (flow.Protocol == protocolTCP || flow.Protocol == protocolUDP) && len(data) >= 4 {
+		flow.SrcPort = binary.BigEndian.Uint16(data[0:2])
+		flow.DstPort = binary.BigEndian.Uint16(data[2:4])
+	}
+
+	return flow, true
+}
+
+// The name of an IANA protocol number, as used by the snapshot store.
+func ProtocolName(protocol uint8) string {
+	switch protocol {
+	case protocolTCP:
+		return "tcp"
+	case protocolUDP:
+		return "udp"
+	case protocolICMP:
+		return "icmp"
+	case protocolICMPv6:
+		return "icmpv6"
+	default:
+		return "ip-" + strconv.Itoa(int(protocol))
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,302 @@
+// Package pcap reads packet captures in the pcap and pcapng formats.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Link types of the packets, see https://www.tcpdump.org/linktypes.html.
+const (
+	LinkTypeNull     = 0
+	LinkTypeEthernet = 1
+	LinkTypeRaw      = 101
+	LinkTypeLinuxSLL = 113
+	LinkTypeIPv4     = 228
+	LinkTypeIPv6     = 229
+	LinkTypeSLL2     = 276
+)
+
+const (
+	pcapMagicMicros = 0xa1b2c3d4
+	pcapMagicNanos  = 0xa1b23c4d
+
+	ngSectionHeader     = 0x0a0d0d0a
+	ngInterfaceDesc     = 0x00000001
+	ngPacket            = 0x00000002 // obsolete
+	ngSimplePacket      = 0x00000003
+	ngEnhancedPacket    = 0x00000006
+	ngByteOrderMagic    = 0x1a2b3c4d
+	ngOptionEnd         = 0
+	ngOptionTsResol     = 9
+	ngMaxBlockLength    = 16 * 1024 * 1024
+	defaultTsResolution = 6
+)
+
+var ErrUnknownFormat = errors.New("not a pcap or pcapng file")
+
+// A Packet is a captured frame and the link layer it was captured on.
+type Packet struct {
+	Timestamp time.Time // zero for simple packets read before any timestamped one
+	LinkType  uint32
+	Length    int // on the wire, the data may be truncated
+	Data      []byte
+}
+
+// A Reader reads packets from a pcap or a pcapng stream.
+type Reader struct {
+	r    *bufio.Reader
+	next func() (Packet, error)
+
+	// pcap
+	order    binary.ByteOrder
+	linkType uint32
+	nanos    bool
+
+	// pcapng
+	interfaces []ngInterface
+	last       time.Time // of the last packet, simple packets have none
+}
+
+type ngInterface struct {
+	linkType uint32
+	tsUnit   float64 // seconds per timestamp tick
+}
+
+// Detect the format of the stream and read its header.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+
+	magic, err := reader.r.Peek(4)
+
+	if err != nil {
+		return nil, ErrUnknownFormat
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic) == ngSectionHeader:
+		reader.next = reader.nextPcapng
+		return reader, nil
+	case binary.LittleEndian.Uint32(magic) == pcapMagicMicros, binary.LittleEndian.Uint32(magic) == pcapMagicNanos:
+		reader.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic) == pcapMagicMicros, binary.BigEndian.Uint32(magic) == pcapMagicNanos:
+		reader.order = binary.BigEndian
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	header := make([]byte, 24)
+
+	if _, err = io.ReadFull(reader.r, header); err != nil {
+		return nil, fmt.Errorf("failed to read pcap header: %w", err)
+	}
+
+	reader.nanos = reader.order.Uint32(header[0:4]) == pcapMagicNanos
+	reader.linkType = reader.order.Uint32(header[20:24]) & 0x0fffffff
+	reader.next = reader.nextPcap
+
+	return reader, nil
+}
+
+// The next packet of the capture, or io.EOF once there are none left.
+func (r *Reader) Next() (Packet, error) {
+	return r.next()
+}
+
+func (r *Reader) nextPcap() (Packet, error) {
+	header := make([]byte, 16)
+
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Packet{}, fmt.Errorf("truncated packet header: %w", err)
+		}
+
+		return Packet{}, err
+	}
+
+	seconds := int64(r.order.Uint32(header[0:4]))
+	fraction := int64(r.order.Uint32(header[4:8]))
+	captured := r.order.Uint32(header[8:12])
+
+	if captured > ngMaxBlockLength {
+		return Packet{}, fmt.Errorf("packet of %d bytes is too large", captured)
+	}
+
+	if !r.nanos {
+		fraction *= 1000
+	}
+
+	data := make([]byte, captured)
+
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Packet{}, fmt.Errorf("truncated packet: %w", err)
+	}
+
+	return Packet{
+		Timestamp: time.Unix(seconds, fraction),
+		LinkType:  r.linkType,
+		Length:    int(r.order.Uint32(header[12:16])),
+		Data:      data,
+	}, nil
+}
+
+func (r *Reader) nextPcapng() (Packet, error) {
+	for {
+		kind, body, err := r.readBlock()
+
+		if err != nil {
+			return Packet{}, err
+		}
+
+		switch kind {
+		case ngSectionHeader:
+			r.interfaces = nil
+		case ngInterfaceDesc:
+			if len(body) < 8 {
+				return Packet{}, fmt.Errorf("malformed interface description block")
+			}
+
+			r.interfaces = append(r.interfaces, ngInterface{
+				linkType: uint32(r.order.Uint16(body[0:2])),
+				tsUnit:   r.tsUnit(body[8:]),
+			})
+		case ngEnhancedPacket, ngPacket:
+			if len(body) < 20 {
+				return Packet{}, fmt.Errorf("malformed packet block")
+			}
+
+			var id uint32
+
+			if kind == ngEnhancedPacket {
+				id = r.order.Uint32(body[0:4])
+			} else {
+				id = uint32(r.order.Uint16(body[0:2]))
+			}
+
+			if int(id) >= len(r.interfaces) {
+				return Packet{}, fmt.Errorf("packet refers to unknown interface %d", id)
+			}
+
+			iface := r.interfaces[id]
+			ticks := uint64(r.order.Uint32(body[4:8]))<<32 | uint64(r.order.Uint32(body[8:12]))
+			captured := r.order.Uint32(body[12:16])
+
+			if int(captured) > len(body)-20 {
+				return Packet{}, fmt.Errorf("packet data exceeds its block")
+			}
+
+			r.last = ticksToTime(ticks, iface.tsUnit)
+
+			return Packet{
+				Timestamp: r.last,
+				LinkType:  iface.linkType,
+				Length:    int(r.order.Uint32(body[16:20])),
+				Data:      body[20 : 20+captured],
+			}, nil
+		case ngSimplePacket:
+			if len(r.interfaces) == 0 || len(body) < 4 {
+				return Packet{}, fmt.Errorf("malformed simple packet block")
+			}
+
+			length := int(r.order.Uint32(body[0:4]))
+			data := body[4:]
+
+			if length < len(data) {
+				data = data[:length]
+			}
+
+			// Simple packets carry no timestamp, they were captured after the
+			// previous packet at the earliest.
+			return Packet{Timestamp: r.last, LinkType: r.interfaces[0].linkType, Length: length, Data: data}, nil
+		}
+	}
+}
+
+// Read a pcapng block, switching byte order on every section header.
+func (r *Reader) readBlock() (uint32, []byte, error) {
+	header := make([]byte, 8)
+
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated block header: %w", err)
+		}
+
+		return 0, nil, err
+	}
+
+	kind := binary.LittleEndian.Uint32(header[0:4])
+
+	if kind == ngSectionHeader {
+		magic, err := r.r.Peek(4)
+
+		if err != nil {
+			return 0, nil, fmt.Errorf("truncated section header: %w", err)
+		}
+
+		switch {
+		case binary.LittleEndian.Uint32(magic) == ngByteOrderMagic:
+			r.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic) == ngByteOrderMagic:
+			r.order = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("invalid section byte order magic")
+		}
+	} else {
+		kind = r.order.Uint32(header[0:4])
+	}
+
+	length := r.order.Uint32(header[4:8])
+
+	if length < 12 || length%4 != 0 || length > ngMaxBlockLength {
+		return 0, nil, fmt.Errorf("invalid block length %d", length)
+	}
+
+	body := make([]byte, length-8)
+
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		return 0, nil, fmt.Errorf("truncated block: %w", err)
+	}
+
+	return kind, body[:len(body)-4], nil // trailing length
+}
+
+// The timestamp resolution of an interface, from its if_tsresol option.
+func (r *Reader) tsUnit(options []byte) float64 {
+	resolution := byte(defaultTsResolution)
+
+	for len(options) >= 4 {
+		code := r.order.Uint16(options[0:2])
+		length := int(r.order.Uint16(options[2:4]))
+
+		if code == ngOptionEnd || 4+length > len(options) {
+			break
+		}
+
+		if code == ngOptionTsResol && length >= 1 {
+			resolution = options[4]
+		}
+
+		options = options[4+(length+3)&^3:]
+	}
+
+	if resolution&0x80 != 0 {
+		return math.Pow(2, -float64(resolution&0x7f))
+	}
+
+	return math.Pow(10, -float64(resolution))
+}
+
+func ticksToTime(ticks uint64, unit float64) time.Time {
+	perSecond := uint64(math.Round(1 / unit))
+
+	if perSecond == 0 {
+		return time.Unix(int64(float64(ticks)*unit), 0)
+	}
+
+	return time.Unix(int64(ticks/perSecond), int64(float64(ticks%perSecond)*unit*1e9))
+}
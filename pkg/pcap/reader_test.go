@@ -0,0 +1,121 @@
+package pcap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+type expectedPacket struct {
+	timestamp time.Time
+	linkType  uint32
+	length    int
+	flow      string
+	ok        bool
+}
+
+func readAll(t *testing.T, name string) []Packet {
+	t.Helper()
+
+	file, err := os.Open(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	reader, err := NewReader(file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packets []Packet
+
+	for {
+		p, err := reader.Next()
+
+		if err == io.EOF {
+			return packets
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		packets = append(packets, p)
+	}
+}
+
+func describe(flow Flow) string {
+	return fmt.Sprintf("%s %s > %s", ProtocolName(flow.Protocol),
+		net.JoinHostPort(flow.Src.String(), fmt.Sprint(flow.SrcPort)),
+		net.JoinHostPort(flow.Dst.String(), fmt.Sprint(flow.DstPort)))
+}
+
+func TestReader(t *testing.T) {
+	table := []struct {
+		name    string
+		packets []expectedPacket
+	}{{
+		name: "testdata/capture.pcap",
+		packets: []expectedPacket{
+			{time.Unix(1665000000, 500000000), LinkTypeEthernet, 1514, "tcp 192.168.1.10:51000 > 93.184.216.34:443", true},
+			{time.Unix(1665000001, 0), LinkTypeEthernet, 60, "tcp 93.184.216.34:443 > 192.168.1.10:51000", true},
+			{time.Unix(1665003700, 0), LinkTypeEthernet, 74, "udp 192.168.1.10:53000 > 1.1.1.1:53", true},
+			{time.Unix(1665003701, 0), LinkTypeEthernet, 42, "", false},
+		},
+	}, {
+		name: "testdata/capture.pcapng",
+		packets: []expectedPacket{
+			{time.Unix(1665000000, 250000000), LinkTypeEthernet, 80, "tcp [fd00::10]:40000 > [2001:db8::1]:80", true},
+			{time.Unix(1665000002, 0), LinkTypeRaw, 84, "icmp 10.0.0.5:0 > 8.8.8.8:0", true},
+		},
+	}, {
+		// Simple packets take the timestamp of the packet before them.
+		name: "testdata/simple.pcapng",
+		packets: []expectedPacket{
+			{time.Time{}, LinkTypeEthernet, 42, "udp 192.168.1.10:53000 > 1.1.1.1:53", true},
+			{time.Unix(1665000000, 500000000), LinkTypeEthernet, 42, "udp 192.168.1.10:53000 > 1.1.1.1:53", true},
+			{time.Unix(1665000000, 500000000), LinkTypeEthernet, 42, "udp 192.168.1.10:53000 > 1.1.1.1:53", true},
+		},
+	}}
+
+	for _, v := range table {
+		packets := readAll(t, v.name)
+
+		if len(packets) != len(v.packets) {
+			t.Fatalf("%s: got %d packets, expected %d", v.name, len(packets), len(v.packets))
+		}
+
+		for i, expected := range v.packets {
+			p := packets[i]
+
+			if !p.Timestamp.Equal(expected.timestamp) || p.LinkType != expected.linkType || p.Length != expected.length {
+				t.Errorf("%s #%d: got %v %d %d, expected %v %d %d", v.name, i, p.Timestamp, p.LinkType, p.Length, expected.timestamp, expected.linkType, expected.length)
+			}
+
+			flow, ok := Decode(p)
+
+			if ok != expected.ok {
+				t.Errorf("%s #%d: got ok %v, expected %v", v.name, i, ok, expected.ok)
+				continue
+			}
+
+			if ok && describe(flow) != expected.flow {
+				t.Errorf("%s #%d: got %q, expected %q", v.name, i, describe(flow), expected.flow)
+			}
+		}
+	}
+}
+
+func TestReaderUnknownFormat(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("GIF89a"))); err != ErrUnknownFormat {
+		t.Errorf("got %v, expected ErrUnknownFormat", err)
+	}
+}